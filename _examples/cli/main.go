@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	realtimepkg "github.com/bridge-packages/go-openai-realtime"
 	"github.com/bridge-packages/go-openai-realtime/agents"
+	"github.com/bridge-packages/go-openai-realtime/metrics"
 	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/bridge-packages/go-openai-realtime/tools"
 	"github.com/openai/openai-go/v3/packages/param"
 	"github.com/openai/openai-go/v3/realtime"
 	"go.uber.org/zap"
@@ -16,7 +22,23 @@ import (
 
 // Environment variable keys
 const (
-	envKeyApiKey string = "OPENAI_API_KEY"
+	envKeyApiKey         string = "OPENAI_API_KEY"
+	envKeyMCPServerLabel string = "MCP_SERVER_LABEL"
+	envKeyMCPServerURL   string = "MCP_SERVER_URL"
+	envKeyPushToTalk     string = "PUSH_TO_TALK"
+	envKeyPushToTalkMs   string = "PUSH_TO_TALK_RELEASE_MS"
+	envKeyLocalVAD       string = "LOCAL_VAD"
+	envKeyEcho           string = "ECHO_PROTECTION"
+	envKeyEchoCanceller  string = "ECHO_CANCELLER"
+	envKeyRecordWAV      string = "RECORD_ASSISTANT_WAV"
+	envKeyRecordDir      string = "RECORD_DIR"
+	envKeyRecordFormat   string = "RECORD_FORMAT"
+	envKeyRecordMix      string = "RECORD_MIX"
+	envKeyJournal        string = "JOURNAL_FILE"
+	envKeyMetricsAddr    string = "METRICS_ADDR"
+	envKeyBudgetDollars  string = "BUDGET_DOLLARS"
+	envKeyBudgetTokens   string = "BUDGET_TOKENS"
+	envKeyBudgetLimit    string = "BUDGET_OUTPUT_LIMIT"
 )
 
 // Log file configuration
@@ -38,6 +60,7 @@ Speak in Persian.
 // Agent configuration
 const (
 	agentPrinterIndentString string = "│  "
+	echoTail                        = 300 * time.Millisecond
 )
 
 // Session Config (4 October 2025)
@@ -78,7 +101,30 @@ const (
 	sessionMaxOutputTokens int64  = 1024
 )
 
+// Flags
+var (
+	flagListDevices  = flag.Bool("list-devices", false, "list the available input devices and exit")
+	flagInputDevice  = flag.String("input-device", "", "input device ID or label, the default microphone if empty")
+	flagOutputDevice = flag.String("output-device", "", "output device name (PulseAudio sink on Linux), the default output if empty")
+	flagReplay       = flag.String("replay", "", "replay the event journal at this path instead of starting a session")
+	flagReplaySpeed  = flag.Float64("replay-speed", 1, "replay speed, 0 replays without waiting")
+)
+
 func main() {
+	flag.Parse()
+	if *flagListDevices {
+		printer, err := shared.NewPrinter(agentPrinterIndentString, shared.NewWriteCloser(os.Stdout))
+		if err != nil {
+			fmt.Println("creating printer:", err)
+			os.Exit(1)
+		}
+		if err := agents.PrintDevices(printer); err != nil {
+			fmt.Println("listing devices:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize logger
 	logger := shared.NewFileLogger(
 		logFileAddress, logFileMaxSize, logFileMaxBackups, logFileMaxAge, logFileCompress,
@@ -87,6 +133,20 @@ func main() {
 		zap.String("version", shared.Version),
 	)
 
+	// Replaying a journal (optional), see JOURNAL_FILE
+	if *flagReplay != "" {
+		printer, err := shared.NewPrinter(agentPrinterIndentString, shared.NewWriteCloser(os.Stdout))
+		if err != nil {
+			logger.Error("creating printer", err)
+			os.Exit(1)
+		}
+		if err := new(agents.CLIAgent).Replay(context.Background(), logger, printer, *flagReplay, *flagReplaySpeed); err != nil {
+			logger.Error("replaying journal", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Loading API Key
 	apiKey, err := shared.Getenv(shared.GetenvString, envKeyApiKey, false, "")
 	if err != nil {
@@ -156,6 +216,29 @@ func main() {
 		},
 	}
 
+	// Adding MCP Server (optional)
+	mcpServerURL := shared.MustGetenv(shared.GetenvString, envKeyMCPServerURL, false, "")
+	if mcpServerURL != "" {
+		err = realtimepkg.AddMCPServers(session, realtimepkg.MCPServer{
+			Label:           shared.MustGetenv(shared.GetenvString, envKeyMCPServerLabel, false, "mcp"),
+			URL:             mcpServerURL,
+			RequireApproval: "always",
+		})
+		if err != nil {
+			logger.Error("adding MCP server", err)
+			os.Exit(1)
+		}
+	}
+
+	// Push-to-talk (optional), hold space to talk
+	pushToTalk := shared.MustGetenv(shared.GetenvBool, envKeyPushToTalk, false, "false")
+	// Local VAD (optional): "suppress" or "dtx" gate the microphone,
+	// "turns" also detects turns locally
+	localVAD := shared.MustGetenv(shared.GetenvString, envKeyLocalVAD, false, "")
+	if pushToTalk || localVAD == "turns" {
+		session.Audio.Input.TurnDetection = realtime.RealtimeAudioInputTurnDetectionUnionParam{}
+	}
+
 	// Loading Base URL
 	baseUrl := shared.MustGetenv(
 		shared.GetenvString,
@@ -180,6 +263,84 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	agent := new(agents.CLIAgent)
+	if pushToTalk {
+		// Raise the release delay if turns end while the key is held
+		releaseMs := shared.MustGetenv(shared.GetenvInt, envKeyPushToTalkMs, false, "0")
+		agent.SetPushToTalk(' ', time.Duration(releaseMs)*time.Millisecond)
+	}
+	// Recording the assistant audio (optional)
+	if path := shared.MustGetenv(shared.GetenvString, envKeyRecordWAV, false, ""); path != "" {
+		sink, err := tools.CreateWAVSink(path)
+		if err != nil {
+			logger.Error("creating WAV sink", err)
+			os.Exit(1)
+		}
+		agent.AddAudioSink(sink)
+	}
+	// Session recording (optional): "ogg" keeps the Opus frames, "wav" decodes
+	// them, RECORD_MIX adds a stereo WAV of both sides
+	if dir := shared.MustGetenv(shared.GetenvString, envKeyRecordDir, false, ""); dir != "" {
+		opts := tools.RecorderOptions{
+			Mix: shared.MustGetenv(shared.GetenvBool, envKeyRecordMix, false, "false"),
+		}
+		if shared.MustGetenv(shared.GetenvString, envKeyRecordFormat, false, "ogg") == "wav" {
+			opts.Format = tools.RecordingWAV
+		}
+		agent.SetRecording(dir, opts)
+	}
+	// Event journal (optional), replayed with --replay
+	if path := shared.MustGetenv(shared.GetenvString, envKeyJournal, false, ""); path != "" {
+		agent.SetJournal(path)
+	}
+	// Prometheus metrics (optional), served at /metrics
+	if addr := shared.MustGetenv(shared.GetenvString, envKeyMetricsAddr, false, ""); addr != "" {
+		m, err := metrics.New(nil)
+		if err != nil {
+			logger.Error("creating metrics", err)
+			os.Exit(1)
+		}
+		agent.SetMetrics(m)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				logger.Error("serving metrics", err)
+			}
+		}()
+	}
+	// Session budget (optional), warned at 80%, then the answers are limited to
+	// BUDGET_OUTPUT_LIMIT tokens or the session is ended
+	budget := realtimepkg.Budget{
+		MaxCost:     shared.MustGetenv(shared.GetenvFloat64, envKeyBudgetDollars, false, "0"),
+		MaxTokens:   shared.MustGetenv(shared.GetenvInt, envKeyBudgetTokens, false, "0"),
+		OutputLimit: shared.MustGetenv(shared.GetenvInt, envKeyBudgetLimit, false, "0"),
+		WarnAt:      0.8,
+	}
+	agent.SetBudget(nil, budget)
+	if *flagInputDevice != "" {
+		agent.SetInputDevice(*flagInputDevice)
+	}
+	if *flagOutputDevice != "" {
+		agent.SetOutputDevice(*flagOutputDevice)
+	}
+	switch localVAD {
+	case "suppress":
+		agent.SetLocalVAD(tools.VADModeSuppress, false)
+	case "dtx":
+		agent.SetLocalVAD(tools.VADModeDTX, false)
+	case "turns":
+		agent.SetLocalVAD(tools.VADModeSuppress, true)
+	}
+	// Echo protection (optional): "mute", "duck" or "flag"
+	echoCanceller := shared.MustGetenv(shared.GetenvBool, envKeyEchoCanceller, false, "false")
+	switch shared.MustGetenv(shared.GetenvString, envKeyEcho, false, "") {
+	case "mute":
+		agent.SetEchoProtection(tools.EchoModeMute, echoTail, echoCanceller)
+	case "duck":
+		agent.SetEchoProtection(tools.EchoModeDuck, echoTail, echoCanceller)
+	case "flag":
+		agent.SetEchoProtection(tools.EchoModeFlag, echoTail, echoCanceller)
+	}
 	err = agent.Spawn(ctx, logger, apiKey, session, greeting, printer, baseUrl)
 	if err != nil {
 		logger.Error("spawning CLI agent", err)
@@ -193,6 +354,9 @@ func main() {
 	select {
 	case <-agent.Done():
 		logger.Info("session ended")
+		if err = agent.Close(); err != nil {
+			logger.Error("closing CLI agent", err)
+		}
 		return
 	case <-sig:
 		logger.Info("shutting down...")
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "time"
+
+// cpuTime is not measured on this platform, the report shows no CPU usage.
+func cpuTime() time.Duration {
+	return 0
+}
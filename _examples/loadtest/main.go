@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/bridge-packages/go-openai-realtime/tools"
+	"go.uber.org/zap"
+)
+
+// Environment variable keys
+const (
+	envKeyApiKey  string = "OPENAI_API_KEY"
+	envKeyBaseUrl string = "OPENAI_BASE_URL"
+)
+
+// Log file configuration
+const (
+	logFileAddress    string = "loadtest/loadtest.log"
+	logFileMaxSize    int    = 100 * 1 << 20 // 100 MB
+	logFileMaxBackups int    = 2             // keep 2 backups
+	logFileMaxAge     int    = 3             // max age 3 days
+	logFileCompress   bool   = false         // no compression
+)
+
+// Synthetic audio
+const (
+	toneFrequency float64 = 220
+	toneAmplitude float64 = 0.2
+	toneDuration          = 2 * time.Second
+	frameDuration         = 20 * time.Millisecond
+)
+
+// Flags
+var (
+	flagSessions      = flag.Int("sessions", 10, "number of sessions")
+	flagConcurrency   = flag.Int("concurrency", 0, "maximum number of concurrent sessions, all of them if 0")
+	flagRamp          = flag.Duration("ramp", 5*time.Second, "time over which the sessions are started")
+	flagDuration      = flag.Duration("duration", 30*time.Second, "duration of each session")
+	flagSetupTimeout  = flag.Duration("setup-timeout", 15*time.Second, "maximum time to connect")
+	flagModel         = flag.String("model", "gpt-realtime", "model of the sessions")
+	flagLocal         = flag.Bool("local", false, "run the sessions against an in-process fake server instead of OPENAI_BASE_URL, its CPU and memory are counted too")
+	flagLocalDeltas   = flag.Int("local-deltas", 100, "text deltas the fake server sends per response")
+	flagLocalInterval = flag.Duration("local-interval", 10*time.Millisecond, "delay between the events of the fake server")
+	flagResponses     = flag.Duration("response-interval", toneDuration, "delay between the responses asked by each session, after each synthetic utterance by default, none if 0")
+	flagReport        = flag.String("report", "", "write the JSON report to this path")
+)
+
+func main() {
+	flag.Parse()
+	if *flagSessions <= 0 {
+		fmt.Println("sessions must be positive")
+		os.Exit(2)
+	}
+
+	// Initialize logger
+	logger := shared.NewFileLogger(
+		logFileAddress, logFileMaxSize, logFileMaxBackups, logFileMaxAge, logFileCompress,
+	).With(
+		zap.String("component", "loadtest"),
+		zap.String("version", shared.Version),
+	)
+
+	// Choosing the endpoint
+	apiKey := shared.MustGetenv(shared.GetenvString, envKeyApiKey, false, "")
+	baseUrl := shared.MustGetenv(shared.GetenvString, envKeyBaseUrl, false, "https://api.openai.com/v1")
+	if *flagLocal {
+		server := realtimetest.NewServer(localOptions(*flagLocalDeltas, *flagLocalInterval))
+		defer server.Close()
+		apiKey, baseUrl = "sk-local", server.URL
+	}
+	if apiKey == "" {
+		fmt.Println(envKeyApiKey, "is required without --local")
+		os.Exit(2)
+	}
+
+	// Encoding the synthetic audio once, the sessions loop over its frames
+	frames, err := tools.EncodeOpusFrames(
+		tools.NewToneSource(toneFrequency, toneAmplitude, toneDuration),
+		frameDuration,
+	)
+	if err != nil {
+		logger.Error("encoding synthetic audio", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		fmt.Println("stopping the sessions...")
+		cancel()
+	}()
+
+	cfg := runConfig{
+		apiKey:       apiKey,
+		baseUrl:      baseUrl,
+		model:        *flagModel,
+		duration:     *flagDuration,
+		setupTimeout: *flagSetupTimeout,
+		frames:       frames,
+		responses:    *flagResponses,
+	}
+	fmt.Printf("running %d sessions against %s\n", *flagSessions, baseUrl)
+	sampler := startSampler(250 * time.Millisecond)
+	start := time.Now()
+	results := make([]sessionResult, *flagSessions)
+	limit := *flagConcurrency
+	if limit <= 0 {
+		limit = *flagSessions
+	}
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range results {
+		// Spreading the starts over the ramp
+		at := start.Add(*flagRamp * time.Duration(i) / time.Duration(*flagSessions))
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Until(at)):
+		}
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			results[i] = sessionResult{Stage: stageCanceled, Err: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = runSession(ctx, logger.With(zap.Int("session", i)), cfg)
+		}()
+	}
+	wg.Wait()
+	used := sampler.stop()
+
+	report := newReport(results, used, time.Since(start))
+	report.print(os.Stdout)
+	if *flagReport != "" {
+		if err := report.write(*flagReport); err != nil {
+			logger.Error("writing report", err)
+			os.Exit(1)
+		}
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// usage is what the process used while the sessions ran.
+type usage struct {
+	cpu        time.Duration
+	peakActive int64
+	// At the peak of connected sessions, above the baseline
+	heap       uint64
+	goroutines int
+}
+
+type sampler struct {
+	stopC chan struct{}
+	done  chan usage
+}
+
+// startSampler samples the heap and the goroutines until stop.
+func startSampler(interval time.Duration) *sampler {
+	s := &sampler{stopC: make(chan struct{}), done: make(chan usage, 1)}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	baseHeap, baseGoroutines, baseCPU := ms.HeapInuse, runtime.NumGoroutine(), cpuTime()
+	go func() {
+		var u usage
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopC:
+				u.cpu = cpuTime() - baseCPU
+				s.done <- u
+				return
+			case <-ticker.C:
+			}
+			n := active.Load()
+			if n == 0 || n < u.peakActive {
+				continue
+			}
+			runtime.ReadMemStats(&ms)
+			u.peakActive = n
+			u.heap = max(u.heap, ms.HeapInuse-min(ms.HeapInuse, baseHeap))
+			u.goroutines = max(u.goroutines, runtime.NumGoroutine()-baseGoroutines)
+		}
+	}()
+	return s
+}
+
+func (s *sampler) stop() usage {
+	close(s.stopC)
+	return <-s.done
+}
+
+type percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+func newPercentiles(values []float64) percentiles {
+	if len(values) == 0 {
+		return percentiles{}
+	}
+	slices.Sort(values)
+	at := func(p float64) float64 {
+		return values[min(len(values)-1, int(p*float64(len(values))))]
+	}
+	return percentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: values[len(values)-1]}
+}
+
+type report struct {
+	Sessions    int            `json:"sessions"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	FailureRate float64        `json:"failure_rate"`
+	Failures    map[string]int `json:"failures"` // by stage
+	Errors      map[string]int `json:"errors"`
+
+	SetupMs      percentiles `json:"setup_ms"`
+	FirstAudioMs percentiles `json:"first_audio_ms"`
+	FirstEventMs percentiles `json:"first_event_ms"`
+	NoAudio      int         `json:"no_audio"` // connected sessions without audio from the server
+
+	Events          int64   `json:"events"`
+	ErrorEvents     int64   `json:"error_events"`
+	EventsPerSecond float64 `json:"events_per_second"` // per connected session, while connected
+	Responses       int64   `json:"responses"`
+	AudioPackets    int64   `json:"audio_packets"`
+
+	WallMs               float64 `json:"wall_ms"`
+	CPUMsPerSession      float64 `json:"cpu_ms_per_session"`
+	CPUPercentPerSession float64 `json:"cpu_percent_per_session"` // of one core, while connected
+	PeakSessions         int64   `json:"peak_sessions"`
+	HeapPerSession       uint64  `json:"heap_per_session"`
+	GoroutinesPerSession float64 `json:"goroutines_per_session"`
+
+	Results []sessionResult `json:"results"`
+}
+
+func newReport(results []sessionResult, u usage, wall time.Duration) *report {
+	r := &report{
+		Sessions: len(results),
+		Failures: map[string]int{},
+		Errors:   map[string]int{},
+		WallMs:   ms(wall),
+		Results:  results,
+	}
+	var setup, firstAudio, firstEvent []float64
+	var connectedMs float64
+	var liveEvents int64
+	for _, result := range results {
+		if result.Stage != "" {
+			r.Failed++
+			r.Failures[result.Stage]++
+			r.Errors[result.Err]++
+		} else {
+			r.Succeeded++
+		}
+		if result.SetupMs > 0 {
+			setup = append(setup, result.SetupMs)
+			if result.FirstAudioMs == 0 {
+				r.NoAudio++
+			}
+		}
+		if result.FirstAudioMs > 0 {
+			firstAudio = append(firstAudio, result.FirstAudioMs)
+		}
+		if result.FirstEventMs > 0 {
+			firstEvent = append(firstEvent, result.FirstEventMs)
+		}
+		r.Events += result.Events
+		r.ErrorEvents += result.ErrorEvents
+		r.Responses += result.Responses
+		liveEvents += result.LiveEvents
+		r.AudioPackets += result.AudioPackets
+		connectedMs += result.ConnectedMs
+	}
+	r.FailureRate = float64(r.Failed) / float64(r.Sessions)
+	r.SetupMs = newPercentiles(setup)
+	r.FirstAudioMs = newPercentiles(firstAudio)
+	r.FirstEventMs = newPercentiles(firstEvent)
+	if connectedMs > 0 {
+		r.EventsPerSecond = float64(liveEvents) / (connectedMs / 1000)
+		r.CPUPercentPerSession = 100 * ms(u.cpu) / connectedMs
+	}
+	r.CPUMsPerSession = ms(u.cpu) / float64(r.Sessions)
+	r.PeakSessions = u.peakActive
+	if u.peakActive > 0 {
+		r.HeapPerSession = u.heap / uint64(u.peakActive)
+		r.GoroutinesPerSession = float64(u.goroutines) / float64(u.peakActive)
+	}
+	return r
+}
+
+func (r *report) print(w io.Writer) {
+	fmt.Fprintf(w, "sessions:        %d, %d failed (%.1f%%)\n", r.Sessions, r.Failed, 100*r.FailureRate)
+	for stage, n := range r.Failures {
+		fmt.Fprintf(w, "  %-14s %d\n", stage+":", n)
+	}
+	for msg, n := range r.Errors {
+		fmt.Fprintf(w, "  %dx %s\n", n, msg)
+	}
+	line := func(name string, p percentiles) {
+		fmt.Fprintf(w, "%-16s p50 %.0fms, p90 %.0fms, p99 %.0fms, max %.0fms\n", name+":", p.P50, p.P90, p.P99, p.Max)
+	}
+	line("setup", r.SetupMs)
+	line("first audio", r.FirstAudioMs)
+	line("first event", r.FirstEventMs)
+	if r.NoAudio > 0 {
+		fmt.Fprintf(w, "  %d connected sessions received no audio\n", r.NoAudio)
+	}
+	fmt.Fprintf(w, "events:          %d (%d errors), %.1f/s per session, %d responses\n", r.Events, r.ErrorEvents, r.EventsPerSecond, r.Responses)
+	fmt.Fprintf(w, "audio packets:   %d\n", r.AudioPackets)
+	fmt.Fprintf(w, "cpu:             %.0fms per session, %.1f%% of a core per connected session\n", r.CPUMsPerSession, r.CPUPercentPerSession)
+	fmt.Fprintf(w, "memory:          %.1f KiB heap, %.1f goroutines per session (%d at peak)\n", float64(r.HeapPerSession)/1024, r.GoroutinesPerSession, r.PeakSessions)
+	fmt.Fprintf(w, "wall time:       %v\n", time.Duration(r.WallMs*float64(time.Millisecond)).Round(time.Millisecond))
+}
+
+func (r *report) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	realtimepkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/openai/openai-go/v3/realtime"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// Stages where a session can fail
+const (
+	stageStart    string = "start"
+	stageConnect  string = "connect"
+	stageSession  string = "session"
+	stageCanceled string = "canceled"
+)
+
+// active counts the connected sessions, see the sampler
+var active atomic.Int64
+
+type runConfig struct {
+	apiKey       string
+	baseUrl      string
+	model        string
+	duration     time.Duration
+	setupTimeout time.Duration
+	frames       [][]byte
+	responses    time.Duration // between the response.create of a session, none if 0
+}
+
+type sessionResult struct {
+	Stage        string  `json:"stage,omitempty"` // where the session failed, empty on success
+	Err          string  `json:"error,omitempty"`
+	SetupMs      float64 `json:"setup_ms"`       // from NewClient until connected
+	FirstAudioMs float64 `json:"first_audio_ms"` // from NewClient until the first packet of the server, 0 if none
+	FirstEventMs float64 `json:"first_event_ms"` // from NewClient until the first event, 0 if none
+	Events       int64   `json:"events"`
+	LiveEvents   int64   `json:"live_events"` // received while connected
+	ErrorEvents  int64   `json:"error_events"`
+	Responses    int64   `json:"responses"` // response.create sent
+	AudioPackets int64   `json:"audio_packets"`
+	ConnectedMs  float64 `json:"connected_ms"`
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// runSession connects a client, streams the synthetic audio for the duration,
+// asks for a response after each utterance and counts what it receives.
+func runSession(ctx context.Context, logger shared.LoggerAdapter, cfg runConfig) (result sessionResult) {
+	began := time.Now()
+	fail := func(stage string, err error) sessionResult {
+		if ctx.Err() != nil {
+			stage, err = stageCanceled, ctx.Err()
+		}
+		logger.Error("session failed", err)
+		result.Stage, result.Err = stage, err.Error()
+		return result
+	}
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c, err := realtimepkg.NewClient(sctx, logger, cfg.apiKey, "", cfg.baseUrl)
+	if err != nil {
+		return fail(stageStart, err)
+	}
+	defer func() { _ = c.Close() }()
+	if err := c.SetConfig(&realtime.RealtimeSessionCreateRequestParam{Model: cfg.model}); err != nil {
+		return fail(stageStart, err)
+	}
+	var events, errorEvents, packets, firstEvent, firstAudio, responses atomic.Int64
+	var responding atomic.Bool // a response is in progress, it can not be asked for again
+	err = c.RegisterEventHandler(func(event *realtimepkg.ServerEvent) {
+		if events.Add(1) == 1 {
+			firstEvent.Store(int64(time.Since(began)))
+		}
+		switch event.Type {
+		case realtimepkg.ServerEventTypeError:
+			errorEvents.Add(1)
+		case realtimepkg.ServerEventTypeResponseCreated:
+			responding.Store(true)
+		case realtimepkg.ServerEventTypeResponseDone:
+			responding.Store(false)
+		}
+	})
+	if err != nil {
+		return fail(stageStart, err)
+	}
+	err = c.RegisterTrackLocalHandler(func(track *webrtc.TrackLocalStaticSample) {
+		ticker := time.NewTicker(frameDuration)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-sctx.Done():
+				return
+			case <-ticker.C:
+			}
+			sample := media.Sample{Data: cfg.frames[i%len(cfg.frames)], Duration: frameDuration}
+			if err := track.WriteSample(sample); err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return fail(stageStart, err)
+	}
+	err = c.RegisterTrackRemoteHandler(func(track *webrtc.TrackRemote) {
+		for {
+			if _, _, err := track.ReadRTP(); err != nil {
+				return
+			}
+			if packets.Add(1) == 1 {
+				firstAudio.Store(int64(time.Since(began)))
+			}
+		}
+	})
+	if err != nil {
+		return fail(stageStart, err)
+	}
+	var connectEvents int64 // received before the session was connected
+	collect := func() {
+		result.Events = events.Load()
+		if result.ConnectedMs > 0 {
+			result.LiveEvents = result.Events - connectEvents
+		}
+		result.ErrorEvents = errorEvents.Load()
+		result.Responses = responses.Load()
+		result.AudioPackets = packets.Load()
+		result.FirstEventMs = ms(time.Duration(firstEvent.Load()))
+		result.FirstAudioMs = ms(time.Duration(firstAudio.Load()))
+	}
+	defer collect()
+
+	if err := c.Start(); err != nil {
+		return fail(stageStart, err)
+	}
+	select {
+	case <-c.Connected():
+	case <-time.After(cfg.setupTimeout):
+		return fail(stageConnect, fmt.Errorf("not connected after %v", cfg.setupTimeout))
+	}
+	if state := c.State(); state != webrtc.PeerConnectionStateConnected {
+		return fail(stageConnect, fmt.Errorf("peer connection is %s", state))
+	}
+	connectedAt := time.Now()
+	connectEvents = events.Load()
+	result.SetupMs = ms(connectedAt.Sub(began))
+	active.Add(1)
+	defer active.Add(-1)
+
+	// Asking for responses, so that events flow as in a conversation
+	if cfg.responses > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.responses)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-sctx.Done():
+					return
+				case <-ticker.C:
+				}
+				if responding.Load() {
+					continue
+				}
+				err := c.SendEvent(&realtimepkg.ClientEvent{
+					Type:  realtimepkg.ClientEventTypeResponseCreate,
+					Param: &realtimepkg.ClientEventParamResponseCreate{},
+				})
+				if err != nil {
+					logger.Error("asking for a response", err)
+					return
+				}
+				responses.Add(1)
+			}
+		}()
+	}
+
+	select {
+	case <-time.After(cfg.duration):
+	case <-c.Done():
+		result.ConnectedMs = ms(time.Since(connectedAt))
+		return fail(stageSession, fmt.Errorf("disconnected, peer connection is %s", c.State()))
+	}
+	result.ConnectedMs = ms(time.Since(connectedAt))
+	return result
+}
+
+// localOptions make the fake server echo the audio and answer each
+// response.create with text deltas.
+func localOptions(deltas int, interval time.Duration) realtimetest.Options {
+	created := &realtimepkg.ServerEvent{
+		Type:  realtimepkg.ServerEventTypeSessionCreated,
+		Param: &realtimepkg.ServerEventParamSessionCreated{Session: map[string]any{"id": "sess_local"}},
+	}
+	return realtimetest.Options{
+		Greeting: []*realtimepkg.ServerEvent{created},
+		Audio:    realtimetest.AudioEcho,
+		Handler: func(call *realtimetest.Call, msg realtimetest.ClientMessage) {
+			if msg.Type != realtimepkg.ClientEventTypeResponseCreate {
+				return
+			}
+			go func() {
+				for i := 0; i < deltas; i++ {
+					time.Sleep(interval)
+					err := call.Send(&realtimepkg.ServerEvent{
+						Type: realtimepkg.ServerEventTypeResponseOutputTextDelta,
+						Param: &realtimepkg.ServerEventParamResponseOutputTextDelta{
+							ResponseId: "resp_local",
+							ItemId:     "item_local",
+							Delta:      "lorem ",
+						},
+					})
+					if err != nil {
+						return
+					}
+				}
+			}()
+		},
+	}
+}
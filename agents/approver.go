@@ -0,0 +1,77 @@
+package agents
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+// CLIApprover asks the user on the terminal whether an MCP tool call may run.
+type CLIApprover struct {
+	printer *shared.Printer
+	lines   <-chan string
+	keys    *pushToTalkKeyboard // suspended during the prompts, nil without push-to-talk
+
+	mu sync.Mutex
+}
+
+var _ pkg.Approver = (*CLIApprover)(nil)
+
+func NewCLIApprover(printer *shared.Printer, in io.Reader) (*CLIApprover, error) {
+	if printer == nil {
+		return nil, errors.New("no printer provided")
+	}
+	if in == nil {
+		return nil, errors.New("no input provided")
+	}
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return &CLIApprover{printer: printer, lines: lines}, nil
+}
+
+func (a *CLIApprover) Approve(ctx context.Context, req pkg.MCPApprovalRequest) (bool, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys != nil {
+		// The answer may contain the talk key
+		a.keys.Suspend()
+		defer a.keys.Resume()
+	}
+	prompt := fmt.Sprintf(
+		"🔐 MCP Approval Requested\ntool: %s\nserver: %s\narguments: %s\n",
+		req.Name, req.ServerLabel, req.Arguments,
+	)
+	if err := a.printer.Writeln(prompt, 0); err != nil {
+		return false, "", fmt.Errorf("printing approval prompt: %w", err)
+	}
+	if err := a.printer.Write("Allow this tool call? [y/N]: ", 0); err != nil {
+		return false, "", fmt.Errorf("printing approval question: %w", err)
+	}
+	select {
+	case <-ctx.Done():
+		return false, "", ctx.Err()
+	case line, ok := <-a.lines:
+		if !ok {
+			return false, "", io.EOF
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, "approved by user", nil
+		default:
+			return false, "denied by user", nil
+		}
+	}
+}
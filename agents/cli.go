@@ -3,6 +3,10 @@ package agents
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,16 +23,380 @@ import (
 	"go.uber.org/zap"
 )
 
+// rateLimitWarning is the fraction of a rate limit left when the user is warned.
+const rateLimitWarning = 0.1
+
+// qualityInterval is how often the connection quality is sampled.
+const qualityInterval = 2 * time.Second
+
 type CLIAgent struct {
-	logger   shared.LoggerAdapter
-	printer  *shared.Printer
-	client   *pkg.Client
-	state    *CLIState
-	micTrack mediadevices.Track
+	logger    shared.LoggerAdapter
+	printer   *shared.Printer
+	client    *pkg.Client
+	state     *CLIState
+	micTrack  mediadevices.Track
+	mcp       *pkg.MCPManager
+	approver  pkg.Approver
+	playback  *tools.Playback
+	playout   sync.WaitGroup // of the remote tracks, joined by Close
+	bargeIn   *pkg.BargeIn
+	pttKey    byte
+	pttDelay  time.Duration
+	keyboard  *pushToTalkKeyboard
+	vadMode   *tools.VADMode
+	vadTurns  bool
+	vad       *tools.VADGate
+	echoCfg   *echoConfig
+	echo      *tools.EchoProtection
+	echoGuard *pkg.EchoGuard
+	sources   *tools.SourceSwitch
+	inputDev  string
+	outputDev string
+	sinks     []tools.AudioSink
+	recordDir string
+	recordOpt tools.RecorderOptions
+	recorder  *tools.Recorder
+	journalTo string
+	journal   *pkg.Journal
+	latency   *pkg.LatencyTracker
+	onLatency func(turn pkg.LatencyTurn)
+	metrics   pkg.LatencyMetrics
+	exporter  MetricsExporter
+	untrack   func() // stops reading the audio statistics
+	prices    pkg.PriceTable
+	budget    pkg.Budget
+	usage     *pkg.UsageAccountant
+	limits    *pkg.RateLimits
+	unwatch   func() // removes the rate limit warning handler
+	quality   *pkg.QualityMonitor
 
 	mu sync.Mutex
 }
 
+// SetMCPApprover sets the approver used for MCP tool calls. It must be called
+// before Spawn, by default the user is asked on the terminal.
+func (a *CLIAgent) SetMCPApprover(approver pkg.Approver) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.approver = approver
+}
+
+// SetPushToTalk enables push-to-talk mode: the microphone is only sent while
+// key is held down. As terminals do not report key releases, the turn ends
+// once the key did not repeat for release, which must be longer than the
+// auto-repeat delay of the keyboard, 600ms if zero. It must be called before
+// Spawn and the session config must not configure a turn detection.
+func (a *CLIAgent) SetPushToTalk(key byte, release time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pttKey = key
+	a.pttDelay = release
+}
+
+// SetLocalVAD gates the microphone with a local voice activity detector. With
+// turnDetection the end of speech also ends the turn, in which case the session
+// config must not configure a turn detection. It must be called before Spawn.
+func (a *CLIAgent) SetLocalVAD(mode tools.VADMode, turnDetection bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.vadMode = &mode
+	a.vadTurns = turnDetection
+}
+
+// echoCancellerTaps covers about 10 ms of echo path at 48 kHz once aligned on
+// the output latency, the speakers of a laptop or a headset. A filter as long as the tail would cost too much CPU
+// per sample.
+const echoCancellerTaps = 512
+
+type echoConfig struct {
+	mode      tools.EchoMode
+	tail      time.Duration
+	canceller bool
+}
+
+// SetEchoProtection protects the microphone from the assistant voice while it
+// is played and for tail afterwards. With canceller an acoustic echo canceller
+// is also run on the microphone, it only covers echoCancellerTaps of echo path
+// and the tail handles longer reverberation. It must be called before Spawn.
+func (a *CLIAgent) SetEchoProtection(mode tools.EchoMode, tail time.Duration, canceller bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.echoCfg = &echoConfig{mode: mode, tail: tail, canceller: canceller}
+}
+
+// SetLatencyMetrics hands the latency of each turn to onTurn and metrics,
+// both optional. onTurn must not call the agent. It must be called before
+// Spawn.
+func (a *CLIAgent) SetLatencyMetrics(onTurn func(turn pkg.LatencyTurn), metrics pkg.LatencyMetrics) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onLatency = onTurn
+	a.metrics = metrics
+}
+
+// MetricsExporter exports the sessions of an agent, e.g. metrics.Metrics,
+// without tying the agents to a metrics backend.
+type MetricsExporter interface {
+	pkg.LatencyMetrics
+	// Track follows the client until it is done, it is called before Start.
+	Track(client *pkg.Client) error
+	// TrackAudio reads the playback statistics until untrack is called.
+	TrackAudio(stats func() pkg.AudioStats) (untrack func())
+	PipeEvent(event *pkg.ServerEvent)
+}
+
+// SetMetrics exports the session to m: connection states, events, tokens,
+// RTP and audio statistics, and the latency unless SetLatencyMetrics sets
+// other metrics. It must be called before Spawn.
+func (a *CLIAgent) SetMetrics(m MetricsExporter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.exporter = m
+}
+
+// SetBudget sets the prices used for the cost of the session, DefaultPrices
+// if nil, and caps its usage. It must be called before Spawn.
+func (a *CLIAgent) SetBudget(prices pkg.PriceTable, budget pkg.Budget) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.prices = prices
+	a.budget = budget
+}
+
+// SetRateLimits shares the rate limits with other sessions, see
+// pkg.RateLimitPool. It must be called before Spawn, by default the session
+// keeps its own and warns when 10% of a limit remain.
+func (a *CLIAgent) SetRateLimits(limits *pkg.RateLimits) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limits = limits
+}
+
+// Usage returns the token usage and cost of the session so far.
+func (a *CLIAgent) Usage() (pkg.TokenUsage, float64) {
+	a.mu.Lock()
+	usage := a.usage
+	a.mu.Unlock()
+	if usage == nil {
+		return pkg.TokenUsage{}, 0
+	}
+	return usage.Usage()
+}
+
+// Quality returns the last sample of the connection quality.
+func (a *CLIAgent) Quality() pkg.QualitySample {
+	a.mu.Lock()
+	quality := a.quality
+	a.mu.Unlock()
+	if quality == nil {
+		return pkg.QualitySample{}
+	}
+	return quality.Last()
+}
+
+// LatencyStats returns the latency percentiles of the turns so far.
+func (a *CLIAgent) LatencyStats() map[pkg.LatencyStage]pkg.LatencySummary {
+	a.mu.Lock()
+	latency := a.latency
+	a.mu.Unlock()
+	if latency == nil {
+		return nil
+	}
+	return latency.Stats()
+}
+
+// SetInputDevice selects the microphone by ID or label, see
+// tools.FindInputDevice. It must be called before Spawn.
+func (a *CLIAgent) SetInputDevice(idOrLabel string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inputDev = idOrLabel
+}
+
+// SetOutputDevice selects the output device by name, see
+// tools.SelectOutputDevice, which changes it for the whole process. It must be
+// called before Spawn.
+func (a *CLIAgent) SetOutputDevice(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.outputDev = name
+}
+
+// AddAudioSink makes the remote audio also go to sink, besides the speakers.
+// The sink is kept across reconnects and is not closed by the agent, the
+// caller closes it after Close, which returns once the remote audio is played
+// out. It must be called before Spawn.
+func (a *CLIAgent) AddAudioSink(sink tools.AudioSink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sinks = append(a.sinks, sink)
+}
+
+// SetRecording records both directions of the session in dir, with a JSON
+// sidecar listing the events. It must be called before Spawn.
+func (a *CLIAgent) SetRecording(dir string, opts tools.RecorderOptions) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recordDir = dir
+	a.recordOpt = opts
+}
+
+// SetJournal records the events of the session as JSON lines in the file at
+// path, see Replay. It must be called before Spawn.
+func (a *CLIAgent) SetJournal(path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.journalTo = path
+}
+
+// Replay prints a session recorded with SetJournal without connecting, the
+// events go through the same handlers as in a live session. MCP approval
+// requests are denied. speed divides the original delays, 0 replays at once.
+func (a *CLIAgent) Replay(ctx context.Context, logger shared.LoggerAdapter, printer *shared.Printer, path string, speed float64) error {
+	if logger == nil {
+		return shared.ErrNoLogger
+	}
+	if printer == nil {
+		return errors.New("no printer provided")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+	a.mu.Lock()
+	err = a.setupReplay(ctx, logger, printer)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := a.printer.Writeln(fmt.Sprintf("⏪ Replaying %s...\n", path), 0); err != nil {
+		a.logger.Error("printing replay message", err)
+	}
+	if err := a.client.Replay(ctx, f, speed); err != nil {
+		a.logger.Error("replaying journal", err)
+		return err
+	}
+	if err := a.printer.Writeln("⏹️ Replay finished.\n", 0); err != nil {
+		a.logger.Error("printing replay finished message", err)
+	}
+	return nil
+}
+
+// setupReplay creates the client and the components fed by the journal, it is
+// called with a.mu held.
+func (a *CLIAgent) setupReplay(ctx context.Context, logger shared.LoggerAdapter, printer *shared.Printer) error {
+	a.logger = logger
+	a.printer = printer
+	a.state = NewCLIState()
+	var err error
+	a.client, err = pkg.NewClient(ctx, logger, "replay", "", "")
+	if err != nil {
+		a.logger.Error("creating client", err)
+		return err
+	}
+	a.playback = tools.NewPlayback()
+	a.bargeIn, err = pkg.NewBargeIn(logger, a.client, a.playback)
+	if err != nil {
+		a.logger.Error("creating barge-in handler", err)
+		return err
+	}
+	// Nothing is played, the latencies follow the journal
+	a.latency = pkg.NewLatencyTracker(nil, a.latencyTurn, a.metrics)
+	// The budget is reported but not enforced
+	a.usage, err = pkg.NewUsageAccountant(logger, nil, a.prices, a.budget, a.budgetReached)
+	if err != nil {
+		a.logger.Error("creating usage accountant", err)
+		return err
+	}
+	a.mcp, err = pkg.NewMCPManager(ctx, logger, a.client, pkg.NewAllowListApprover(nil))
+	if err != nil {
+		a.logger.Error("creating MCP manager", err)
+		return err
+	}
+	if err := a.client.RegisterEventHandler(a.eventHandler); err != nil {
+		a.logger.Error("registering event handler", err)
+		return err
+	}
+	return nil
+}
+
+// PrintDevices prints the available microphones.
+func PrintDevices(printer *shared.Printer) error {
+	devices := tools.ListInputDevices()
+	if err := printer.Writeln("🎤 Input devices\n", 0); err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return printer.Writeln("No input device found.", 1)
+	}
+	for _, d := range devices {
+		if err := printer.Writeln(fmt.Sprintf("%s (%s)", d.Label, d.ID), 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *CLIAgent) Mute() {
+	a.setMuted(true)
+}
+
+func (a *CLIAgent) Unmute() {
+	a.setMuted(false)
+}
+
+func (a *CLIAgent) setMuted(muted bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client == nil {
+		return
+	}
+	a.client.SetMuted(muted)
+	if muted {
+		a.printHelper("🔇 Microphone muted\n\n", 0)
+	} else {
+		a.printHelper("🎤 Microphone unmuted\n\n", 0)
+	}
+}
+
+// SwapMicrophone streams track instead of the current microphone, without
+// renegotiating the session. The previous microphone track is closed.
+func (a *CLIAgent) SwapMicrophone(track mediadevices.Track) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sources == nil {
+		return shared.ErrClientNotInitialized
+	}
+	if err := a.sources.SetTrack(track); err != nil {
+		return err
+	}
+	prev := a.micTrack
+	a.micTrack = track
+	if prev != nil && prev != track {
+		if err := prev.Close(); err != nil {
+			a.logger.Error("closing previous microphone track", err)
+		}
+	}
+	a.printHelper("🎧 Microphone swapped\n\n", 0)
+	return nil
+}
+
+// SwapAudioSource streams source instead of the microphone, e.g. a
+// tools.WAVSource. The previous source is closed, the microphone track is not.
+func (a *CLIAgent) SwapAudioSource(source tools.AudioSource) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sources == nil {
+		return shared.ErrClientNotInitialized
+	}
+	if err := a.sources.Set(source); err != nil {
+		return err
+	}
+	a.printHelper("🎧 Audio source swapped\n\n", 0)
+	return nil
+}
+
 func (a *CLIAgent) Done() <-chan struct{} {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -79,6 +447,161 @@ func (a *CLIAgent) Spawn(
 		return err
 	}
 	a.logger.Info("client created successfully")
+	if a.exporter != nil {
+		if err := a.exporter.Track(a.client); err != nil {
+			a.logger.Error("tracking client metrics", err)
+			return err
+		}
+	}
+
+	// Setting up barge-in handling
+	a.playback = tools.NewPlayback()
+	a.bargeIn, err = pkg.NewBargeIn(a.logger, a.client, a.playback)
+	if err != nil {
+		a.logger.Error("creating barge-in handler", err)
+		return err
+	}
+
+	// Setting up metrics
+	latencyMetrics := a.metrics
+	if a.exporter != nil {
+		playback := a.playback
+		a.untrack = a.exporter.TrackAudio(func() pkg.AudioStats {
+			buffer := playback.BufferStats()
+			return pkg.AudioStats{
+				DroppedBytes: buffer.Dropped,
+				Underruns:    buffer.Underruns,
+				DecodeErrors: playback.JitterStats().Undecoded,
+			}
+		})
+		if latencyMetrics == nil {
+			latencyMetrics = a.exporter
+		}
+	}
+
+	// Setting up latency tracking
+	a.latency = pkg.NewLatencyTracker(a.playback, a.latencyTurn, latencyMetrics)
+
+	// Setting up rate limits
+	if a.limits == nil {
+		a.limits = pkg.NewRateLimits(nil, rateLimitWarning)
+	}
+	if err := a.client.SetRateLimits(a.limits); err != nil {
+		a.logger.Error("setting rate limits", err)
+		return err
+	}
+	a.unwatch = a.limits.OnWarning(a.rateLimitLow)
+
+	// Setting up usage accounting
+	a.usage, err = pkg.NewUsageAccountant(a.logger, a.client, a.prices, a.budget, a.budgetReached)
+	if err != nil {
+		a.logger.Error("creating usage accountant", err)
+		return err
+	}
+
+	// Setting up connection quality monitoring
+	a.quality, err = pkg.NewQualityMonitor(a.logger, a.client, qualityInterval, nil, a.qualityChanged)
+	if err != nil {
+		a.logger.Error("creating quality monitor", err)
+		return err
+	}
+
+	// Setting up the event journal
+	if a.journalTo != "" {
+		a.journal, err = pkg.CreateJournal(a.journalTo)
+		if err != nil {
+			a.logger.Error("creating journal", err)
+			return err
+		}
+		if err := a.client.SetJournal(a.journal); err != nil {
+			a.logger.Error("setting journal", err)
+			return err
+		}
+		a.logger.Info("journaling events", zap.String("path", a.journalTo))
+	}
+
+	// Setting up recording
+	sinks := a.sinks
+	if a.recordDir != "" {
+		a.recorder, err = tools.NewRecorder(a.recordDir, "", a.recordOpt)
+		if err != nil {
+			a.logger.Error("creating recorder", err)
+			if err := a.printer.Writeln("❌ Unable to create the recording files.\n", 0); err != nil {
+				a.logger.Error("printing recording failure message", err)
+			}
+			return err
+		}
+		sinks = append(sinks[:len(sinks):len(sinks)], a.recorder.Assistant())
+		a.logger.Info("recording session", zap.String("dir", a.recordDir))
+		if err := a.printer.Writeln(fmt.Sprintf("⏺️ Recording to %s\n", a.recordDir), 0); err != nil {
+			a.logger.Error("printing recording message", err)
+		}
+	}
+
+	// Setting up push-to-talk
+	var input io.Reader = os.Stdin
+	if a.pttKey != 0 {
+		if err := a.client.SetPushToTalk(true); err != nil {
+			a.logger.Error("enabling push-to-talk", err)
+			return err
+		}
+		a.keyboard, err = newPushToTalkKeyboard(ctx, a.logger, a.printer, a.pttKey, a.pttDelay, a.beginTurn, a.endTurn)
+		if err != nil {
+			a.logger.Error("setting up push-to-talk keyboard", err)
+			return err
+		}
+		input = a.keyboard.Input()
+		if err := a.printer.Writeln(fmt.Sprintf("🎙️ Hold %q to talk.\n", a.pttKey), 0); err != nil {
+			a.logger.Error("printing push-to-talk message", err)
+		}
+	}
+
+	// Setting up echo protection
+	if a.echoCfg != nil {
+		a.echoGuard = pkg.NewEchoGuard(a.playback, a.echoCfg.tail)
+		var canceller *tools.EchoCanceller
+		if a.echoCfg.canceller {
+			canceller = tools.NewEchoCanceller(echoCancellerTaps)
+			a.playback.SetEchoReference(canceller)
+		}
+		a.echo = tools.NewEchoProtection(a.echoCfg.mode, a.echoGuard.Active, canceller)
+	}
+
+	// Setting up local voice activity detection
+	if a.vadMode != nil {
+		a.vad = tools.NewVADGate(nil, *a.vadMode, 300*time.Millisecond, 600*time.Millisecond)
+		if a.vadTurns {
+			if a.pttKey != 0 {
+				return errors.New("local turn detection can not be used with push-to-talk")
+			}
+			if err := a.client.SetPushToTalk(true); err != nil {
+				a.logger.Error("enabling local turn detection", err)
+				return err
+			}
+			a.vad.OnSpeech(a.beginTurn, a.endTurn)
+		}
+	}
+
+	// Setting up MCP manager
+	switch {
+	case a.approver != nil:
+	case hasMCPServers(cfg):
+		approver, err := NewCLIApprover(a.printer, input)
+		if err != nil {
+			a.logger.Error("creating CLI approver", err)
+			return err
+		}
+		approver.keys = a.keyboard
+		a.approver = approver
+	default:
+		// Nothing to approve, stdin is left to push-to-talk
+		a.approver = pkg.NewAllowListApprover(nil)
+	}
+	a.mcp, err = pkg.NewMCPManager(ctx, a.logger, a.client, a.approver)
+	if err != nil {
+		a.logger.Error("creating MCP manager", err)
+		return err
+	}
 
 	// Setting up session config
 	if err := a.client.SetConfig(cfg); err != nil {
@@ -107,8 +630,24 @@ func (a *CLIAgent) Spawn(
 		a.logger.Error("creating opus params", err)
 		return err
 	}
+	var deviceId string
+	if a.inputDev != "" {
+		device, err := tools.FindInputDevice(a.inputDev)
+		if err != nil {
+			a.logger.Error("finding input device", err)
+			if err := a.printer.Writeln(fmt.Sprintf("❌ Unable to find input device %q.\n", a.inputDev), 0); err != nil {
+				a.logger.Error("printing input device failure message", err)
+			}
+			return err
+		}
+		deviceId = device.ID
+		a.logger.Info("using input device", zap.String("id", device.ID), zap.String("label", device.Label))
+	}
 	micStream, err := mediadevices.GetUserMedia(mediadevices.MediaStreamConstraints{
 		Audio: func(c *mediadevices.MediaTrackConstraints) {
+			if deviceId != "" {
+				c.DeviceID = prop.StringExact(deviceId)
+			}
 			c.SampleRate = prop.Int(48000)
 			c.ChannelCount = prop.Int(1)
 			c.SampleSize = prop.Int(16)
@@ -144,13 +683,22 @@ func (a *CLIAgent) Spawn(
 	if err := a.printer.Writeln("🔈 Setting up track remote handler...", 0); err != nil {
 		a.logger.Error("printing track remote handler setup message", err)
 	}
+	if a.outputDev != "" {
+		if err := tools.SelectOutputDevice(a.outputDev); err != nil {
+			a.logger.Error("selecting output device", err)
+			return err
+		}
+		a.logger.Info("using output device", zap.String("name", a.outputDev))
+	}
 	err = a.client.RegisterTrackRemoteHandler(func(track *webrtc.TrackRemote) {
 		a.logger.Info(
 			"received remote track",
 			zap.String("kind", track.Kind().String()),
 			zap.String("codec", track.Codec().MimeType),
 		)
-		tools.PlayRemoteAudio(ctx, a.logger, track, 200, 10)
+		a.playout.Add(1)
+		defer a.playout.Done()
+		tools.PlayRemoteAudio(ctx, a.logger, track, 200, 10, a.playback, sinks...)
 	})
 	if err != nil {
 		a.logger.Error("registering track remote handler", err)
@@ -169,8 +717,20 @@ func (a *CLIAgent) Spawn(
 	if err := a.printer.Writeln("🎧 Setting up track local handler...", 0); err != nil {
 		a.logger.Error("printing track local handler setup message", err)
 	}
+	a.sources = tools.NewSourceSwitch()
+	var localSink tools.AudioSink
+	if a.recorder != nil {
+		localSink = a.recorder.User()
+	}
 	err = a.client.RegisterTrackLocalHandler(func(track *webrtc.TrackLocalStaticSample) {
-		tools.StreamLocalAudio(ctx, a.logger, track, a.micTrack, time.Duration(opusParams.Latency))
+		tools.StreamLocalAudio(ctx, a.logger, track, tools.NewTrackSource(a.micTrack), time.Duration(opusParams.Latency), tools.LocalAudioOptions{
+			Gate:   a.client.MicOpen,
+			Muted:  a.client.Muted,
+			VAD:    a.vad,
+			Echo:   a.echo,
+			Switch: a.sources,
+			Sink:   localSink,
+		})
 	})
 	if err != nil {
 		a.logger.Error("registering track local handler", err)
@@ -212,7 +772,10 @@ func (a *CLIAgent) Spawn(
 		}
 		return err
 	}
-	a.logger.Info("session started successfully")
+	a.logger.Info("session started successfully", zap.String("call_id", a.client.CallID()))
+	if a.recorder != nil {
+		a.recorder.SetCallID(a.client.CallID())
+	}
 	if err := a.printer.Writeln("✅ Session started successfully.\n", 0); err != nil {
 		a.logger.Error("printing session started success message", err)
 	}
@@ -223,13 +786,160 @@ func (a *CLIAgent) Spawn(
 func (a *CLIAgent) Close() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	if a.client != nil {
-		if err := a.client.Close(); err != nil {
+	if a.keyboard != nil {
+		if err := a.keyboard.Close(); err != nil {
+			a.logger.Error("closing push-to-talk keyboard", err)
+		}
+	}
+	if a.latency != nil {
+		a.latency.Close()
+		for stage, summary := range a.latency.Stats() {
+			a.logger.Info(
+				"latency",
+				zap.String("stage", string(stage)),
+				zap.Int("turns", summary.Count),
+				zap.Duration("p50", summary.P50),
+				zap.Duration("p90", summary.P90),
+				zap.Duration("max", summary.Max),
+			)
+		}
+	}
+	if a.untrack != nil {
+		a.untrack()
+	}
+	if a.unwatch != nil {
+		a.unwatch()
+	}
+	if a.quality != nil {
+		a.quality.Close()
+	}
+	if a.usage != nil {
+		usage, cost := a.usage.Usage()
+		a.logger.Info(
+			"usage",
+			zap.Int("input_tokens", usage.Input()),
+			zap.Int("cached_tokens", usage.Cached()),
+			zap.Int("output_tokens", usage.Output()),
+			zap.Float64("cost", cost),
+		)
+	}
+	var err error
+	if a.client != nil && !a.ended() {
+		if err = a.client.Close(); err != nil {
 			a.logger.Error("closing client", err)
-			return err
 		}
 	}
-	return nil
+	// The track ended with the client, the sinks are done once it is played
+	// out
+	a.playout.Wait()
+	if a.recorder != nil {
+		if rerr := a.recorder.Close(); rerr != nil {
+			a.logger.Error("closing recorder", rerr)
+			err = errors.Join(err, rerr)
+		}
+	}
+	if a.journal != nil {
+		if jerr := a.journal.Close(); jerr != nil {
+			a.logger.Error("closing journal", jerr)
+			err = errors.Join(err, jerr)
+		}
+	}
+	return err
+}
+
+// latencyTurn logs the latency of a turn, it may be called with a.mu held.
+func (a *CLIAgent) latencyTurn(turn pkg.LatencyTurn) {
+	fields := []zap.Field{zap.String("response_id", turn.ResponseId)}
+	for _, stage := range []pkg.LatencyStage{pkg.LatencyUserPerceived, pkg.LatencyCommit, pkg.LatencyResponse, pkg.LatencyFirstAudio, pkg.LatencyPlayout} {
+		if d, ok := turn.Latency(stage); ok {
+			fields = append(fields, zap.Duration(string(stage), d))
+		}
+	}
+	a.logger.Info("turn latency", fields...)
+	if a.onLatency != nil {
+		a.onLatency(turn)
+	}
+}
+
+// budgetReached tells the user about the budget, it is called with a.mu held.
+func (a *CLIAgent) budgetReached(state pkg.BudgetState, usage pkg.TokenUsage, cost float64) {
+	switch {
+	case state == pkg.BudgetWarning:
+		a.printHelper(fmt.Sprintf("💸 Budget nearly reached: %d tokens, $%.4f\n\n", usage.Total(), cost), 0)
+	case a.budget.OutputLimit > 0:
+		a.printHelper(fmt.Sprintf("💸 Budget exceeded: %d tokens, $%.4f, answers are now limited to %d tokens\n\n", usage.Total(), cost, a.budget.OutputLimit), 0)
+	default:
+		a.printHelper(fmt.Sprintf("💸 Budget exceeded: %d tokens, $%.4f, ending the session\n\n", usage.Total(), cost), 0)
+	}
+}
+
+// rateLimitLow tells the user a rate limit is nearly exhausted. It is called
+// from the event dispatch, possibly of another session sharing the limits.
+func (a *CLIAgent) rateLimitLow(limit pkg.RateLimit) {
+	a.logger.Warn(
+		"rate limit nearly exhausted",
+		zap.String("name", limit.Name),
+		zap.Int("remaining", limit.Remaining),
+		zap.Int("limit", limit.Limit),
+		zap.Duration("reset", limit.Reset),
+	)
+	a.printHelper(fmt.Sprintf("⏳ Rate limit nearly exhausted: %d/%d %s left, reset in %s\n\n", limit.Remaining, limit.Limit, limit.Name, limit.Reset.Round(100*time.Millisecond)), 0)
+}
+
+// qualityChanged tells the user the network got worse or recovered, it is
+// called from the quality monitor.
+func (a *CLIAgent) qualityChanged(previous pkg.QualityLevel, sample pkg.QualitySample) {
+	if sample.Level == pkg.QualityGood {
+		a.printHelper("📶 Network quality recovered\n\n", 0)
+		return
+	}
+	a.printHelper(fmt.Sprintf(
+		"📶 Network quality %s (%s): RTT %s, jitter %s, loss %.1f%%\n\n",
+		sample.Level,
+		strings.Join(sample.Issues, ", "),
+		sample.RTT.Round(time.Millisecond),
+		sample.Jitter.Round(time.Millisecond),
+		sample.Loss*100,
+	), 0)
+}
+
+// hasMCPServers reports whether the session config uses MCP servers.
+func hasMCPServers(cfg *realtime.RealtimeSessionCreateRequestParam) bool {
+	for _, tool := range cfg.Tools {
+		if tool.OfMcp != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ended reports whether the session already ended, a.mu must be held.
+func (a *CLIAgent) ended() bool {
+	select {
+	case <-a.client.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *CLIAgent) beginTurn() {
+	if a.bargeIn.Interrupt() {
+		a.printHelper("✋ Interrupted\n\n", 0)
+	}
+	if err := a.client.BeginTurn(); err != nil {
+		a.logger.Error("beginning push-to-talk turn", err)
+		return
+	}
+	a.printHelper("🔴 Talking...\n", 0)
+}
+
+func (a *CLIAgent) endTurn() {
+	if err := a.client.EndTurn(); err != nil {
+		a.logger.Error("ending push-to-talk turn", err)
+		return
+	}
+	a.printHelper("⏹️ Sent\n\n", 0)
 }
 
 func (a *CLIAgent) eventHandler(event *pkg.ServerEvent) {
@@ -244,6 +954,26 @@ func (a *CLIAgent) eventHandler(event *pkg.ServerEvent) {
 	if msg != "" {
 		a.printHelper(msg, 0)
 	}
+	a.mcp.PipeEvent(event)
+	if a.recorder != nil {
+		a.recorder.Event(string(event.Type), map[string]any{"event_id": event.EventId})
+		if p, ok := event.Param.(*pkg.ServerEventParamSessionCreated); ok {
+			if id, ok := p.Session["id"].(string); ok {
+				a.recorder.SetSessionID(id)
+			}
+		}
+	}
+	if a.echoGuard != nil {
+		a.echoGuard.PipeEvent(event)
+	}
+	a.latency.PipeEvent(event)
+	a.usage.PipeEvent(event)
+	if a.exporter != nil {
+		a.exporter.PipeEvent(event)
+	}
+	if a.bargeIn.PipeEvent(event) {
+		a.printHelper("✋ Interrupted\n\n", 0)
+	}
 	switch event.Type {
 	case pkg.ServerEventTypeError:
 		a.logger.Error(
@@ -391,13 +1121,65 @@ func (a *CLIAgent) eventHandler(event *pkg.ServerEvent) {
 	case pkg.ServerEventTypeResponseFunctionCallArgumentsDone:
 	case pkg.ServerEventTypeResponseMCPCallArgumentsDelta:
 	case pkg.ServerEventTypeResponseMCPCallArgumentsDone:
+		a.logger.Info(
+			"response MCP call arguments done",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
 	case pkg.ServerEventTypeResponseMCPCallInProgress:
+		a.logger.Info(
+			"response MCP call in progress",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
 	case pkg.ServerEventTypeResponseMCPCallCompleted:
+		a.logger.Info(
+			"response MCP call completed",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
+		call, _ := a.mcp.Call(event.Param.(*pkg.ServerEventParamResponseMCPCallCompleted).ItemId)
+		a.printHelper(fmt.Sprintf("🛠️ MCP call %s (%s) completed\n\n", call.Name, call.ServerLabel), 0)
 	case pkg.ServerEventTypeResponseMCPCallFailed:
+		a.logger.Warn(
+			"response MCP call failed",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
+		call, _ := a.mcp.Call(event.Param.(*pkg.ServerEventParamResponseMCPCallFailed).ItemId)
+		a.printHelper(fmt.Sprintf("❌ MCP call %s (%s) failed\n\n", call.Name, call.ServerLabel), 0)
 	case pkg.ServerEventTypeMCPListToolsInProgress:
+		a.logger.Info(
+			"MCP list tools in progress",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
 	case pkg.ServerEventTypeMCPListToolsCompleted:
+		a.logger.Info(
+			"MCP list tools completed",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
+		lt, _ := a.mcp.ListTools(event.Param.(*pkg.ServerEventParamMCPListToolsCompleted).ItemId)
+		a.printHelper(fmt.Sprintf("🧰 MCP tools listed (%s)\n\n", lt.ServerLabel), 0)
 	case pkg.ServerEventTypeMCPListToolsFailed:
+		a.logger.Warn(
+			"MCP list tools failed",
+			zap.Any("item", event.Param),
+			zap.String("event_id", event.EventId),
+		)
+		lt, _ := a.mcp.ListTools(event.Param.(*pkg.ServerEventParamMCPListToolsFailed).ItemId)
+		a.printHelper(fmt.Sprintf("❌ MCP tools listing failed (%s)\n\n", lt.ServerLabel), 0)
 	case pkg.ServerEventTypeRatelimitsUpdated:
+		for _, limit := range event.Param.(*pkg.ServerEventParamRatelimitsUpdated).Limits(time.Now()) {
+			a.logger.Info(
+				"rate limit updated",
+				zap.String("name", limit.Name),
+				zap.Int("remaining", limit.Remaining),
+				zap.Int("limit", limit.Limit),
+				zap.Duration("reset", limit.Reset),
+			)
+		}
 	default:
 		a.logger.Warn(
 			"unknown event type received",
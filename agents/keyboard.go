@@ -0,0 +1,180 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+// Terminals do not report key releases, a push-to-talk turn ends once the
+// auto-repeat of the held key stops for this long. It has to be longer than
+// the auto-repeat delay of the keyboard, see CLIAgent.SetPushToTalk.
+const pushToTalkReleaseDelay = 600 * time.Millisecond
+
+// pushToTalkKeyboard reads the terminal key by key and turns holding the talk
+// key into push-to-talk turns. Other keys are forwarded to Input, so line based
+// prompts keep working, as is the talk key while the capture is suspended.
+type pushToTalkKeyboard struct {
+	logger  shared.LoggerAdapter
+	printer *shared.Printer
+	key     byte
+	release time.Duration
+	begin   func()
+	end     func()
+
+	sttyState string
+	input     *io.PipeReader
+	forward   chan byte
+	suspended atomic.Bool // a prompt reads the talk key as text
+}
+
+func newPushToTalkKeyboard(
+	ctx context.Context,
+	logger shared.LoggerAdapter,
+	printer *shared.Printer,
+	key byte,
+	release time.Duration,
+	begin, end func(),
+) (*pushToTalkKeyboard, error) {
+	if begin == nil || end == nil {
+		return nil, errors.New("turn callbacks are required")
+	}
+	if release <= 0 {
+		release = pushToTalkReleaseDelay
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("saving terminal state: %w", err)
+	}
+	// Unbuffered input without echo, signals and output processing are kept
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, fmt.Errorf("setting terminal mode: %w", err)
+	}
+	pr, pw := io.Pipe()
+	k := &pushToTalkKeyboard{
+		logger:    logger,
+		printer:   printer,
+		key:       key,
+		release:   release,
+		begin:     begin,
+		end:       end,
+		sttyState: strings.TrimSpace(state),
+		input:     pr,
+		forward:   make(chan byte, 256),
+	}
+	go k.pipe(pw)
+	go k.run(ctx)
+	return k, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Input returns the keys typed other than the talk key.
+func (k *pushToTalkKeyboard) Input() io.Reader {
+	return k.input
+}
+
+// Suspend hands the talk key to Input until Resume, e.g. while a prompt reads
+// a line.
+func (k *pushToTalkKeyboard) Suspend() {
+	k.suspended.Store(true)
+}
+
+func (k *pushToTalkKeyboard) Resume() {
+	k.suspended.Store(false)
+}
+
+func (k *pushToTalkKeyboard) Close() error {
+	if _, err := stty(k.sttyState); err != nil {
+		return fmt.Errorf("restoring terminal state: %w", err)
+	}
+	return nil
+}
+
+func (k *pushToTalkKeyboard) pipe(pw *io.PipeWriter) {
+	defer func() { _ = pw.Close() }()
+	buf := make([]byte, 1)
+	for b := range k.forward {
+		buf[0] = b
+		if _, err := pw.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+func (k *pushToTalkKeyboard) run(ctx context.Context) {
+	defer close(k.forward)
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 0 {
+				continue
+			}
+			select {
+			case keys <- buf[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	release := time.NewTimer(k.release)
+	release.Stop()
+	talking := false
+	for {
+		select {
+		case <-ctx.Done():
+			if talking {
+				k.end()
+			}
+			return
+		case b, ok := <-keys:
+			if !ok {
+				return
+			}
+			if b == k.key && !k.suspended.Load() {
+				if !talking {
+					talking = true
+					k.begin()
+				}
+				release.Reset(k.release)
+				continue
+			}
+			if b == '\r' {
+				b = '\n'
+			}
+			// Echo is off, typed keys are printed back
+			if err := k.printer.Write(string(b), 0); err != nil {
+				k.logger.Error("echoing key", err)
+			}
+			select {
+			case k.forward <- b:
+			default:
+				k.logger.Warn("keyboard input dropped")
+			}
+		case <-release.C:
+			if talking {
+				talking = false
+				k.end()
+			}
+		}
+	}
+}
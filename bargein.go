@@ -0,0 +1,140 @@
+package realtime
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"go.uber.org/zap"
+)
+
+// PlaybackController is implemented by the local audio output (see tools.Playback).
+// Durations are offsets in the stream of remote audio queued for playback.
+type PlaybackController interface {
+	Written() time.Duration
+	Played() time.Duration
+	Flush() time.Duration
+}
+
+// BargeIn stops local playback as soon as the user starts speaking and tells
+// the server how much of the assistant item was actually heard, so the
+// conversation transcript matches what the user heard.
+type BargeIn struct {
+	logger   shared.LoggerAdapter
+	client   *Client
+	playback PlaybackController
+
+	mu           sync.Mutex
+	itemId       string
+	contentIndex int
+	itemStart    time.Duration // stream offset where the item audio starts
+	streaming    bool          // the server is still sending audio for the item
+}
+
+func NewBargeIn(logger shared.LoggerAdapter, client *Client, playback PlaybackController) (*BargeIn, error) {
+	if logger == nil {
+		return nil, shared.ErrNoLogger
+	}
+	if client == nil {
+		return nil, shared.ErrClientNotInitialized
+	}
+	if playback == nil {
+		return nil, errors.New("no playback provided")
+	}
+	return &BargeIn{
+		logger:   logger,
+		client:   client,
+		playback: playback,
+	}, nil
+}
+
+// PipeEvent returns true when the event interrupted the assistant.
+func (b *BargeIn) PipeEvent(event *ServerEvent) (interrupted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch event.Type {
+	case ServerEventTypeResponseOutputItemAdded:
+		item := event.Param.(*ServerEventParamResponseOutputItemAdded).Item
+		if item["type"] != "message" || item["role"] != "assistant" {
+			return false
+		}
+		id, _ := item["id"].(string)
+		b.itemId = id
+		b.contentIndex = 0
+		b.itemStart = b.playback.Written()
+		b.streaming = true
+	case ServerEventTypeResponseContentPartAdded:
+		p := event.Param.(*ServerEventParamResponseContentPartAdded)
+		if p.ItemId == b.itemId && p.Part["type"] == "audio" {
+			b.contentIndex = p.ContentIndex
+		}
+	case ServerEventTypeOutputAudioBufferStopped, ServerEventTypeOutputAudioBufferCleared:
+		b.streaming = false
+	case ServerEventTypeInputAudioBufferSpeechStarted:
+		return b.interrupt()
+	}
+	return false
+}
+
+func (b *BargeIn) interrupt() bool {
+	if b.itemId == "" {
+		return false
+	}
+	itemId := b.itemId
+	b.itemId = ""
+	played := b.playback.Played()
+	if !b.streaming && b.playback.Written() <= played {
+		// The whole item has already been heard
+		return false
+	}
+	dropped := b.playback.Flush()
+	audioEnd := max(played-b.itemStart, 0)
+	b.logger.Info(
+		"assistant interrupted",
+		zap.String("item_id", itemId),
+		zap.Duration("audio_end", audioEnd),
+		zap.Duration("dropped", dropped),
+	)
+	err := b.client.SendEvent(&ClientEvent{
+		Type: ClientEventTypeConversationItemTruncate,
+		Param: &ClientEventParamConversationItemTruncate{
+			ItemId:       itemId,
+			ContentIndex: b.contentIndex,
+			AudioEndMs:   int(audioEnd.Milliseconds()),
+		},
+	})
+	if err != nil {
+		b.logger.Error("sending truncate event", err, zap.String("item_id", itemId))
+	}
+	return true
+}
+
+// Interrupt stops the assistant on demand, e.g. when a push-to-talk turn
+// begins while it is speaking. Without turn detection the server does not
+// cancel the response by itself, so it is cancelled here as well.
+func (b *BargeIn) Interrupt() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	streaming := b.streaming
+	if !b.interrupt() {
+		return false
+	}
+	if !streaming {
+		return true
+	}
+	b.streaming = false
+	if err := b.client.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeResponseCancel,
+		Param: &ClientEventParamResponseCancel{},
+	}); err != nil {
+		b.logger.Error("sending response cancel event", err)
+	}
+	if err := b.client.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeOutputAudioBufferClear,
+		Param: &ClientEventParamOutputAudioBufferClear{},
+	}); err != nil {
+		b.logger.Error("sending output audio buffer clear event", err)
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package realtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+type fakePlayback struct {
+	written, played time.Duration
+	flushed         int
+}
+
+func (p *fakePlayback) Written() time.Duration { return p.written }
+func (p *fakePlayback) Played() time.Duration  { return p.played }
+func (p *fakePlayback) Flush() time.Duration {
+	p.flushed++
+	dropped := p.written - p.played
+	p.written = p.played
+	return dropped
+}
+
+func assistantItemAdded(id string) *pkg.ServerEvent {
+	return &pkg.ServerEvent{
+		Type: pkg.ServerEventTypeResponseOutputItemAdded,
+		Param: &pkg.ServerEventParamResponseOutputItemAdded{
+			Item: map[string]any{"id": id, "type": "message", "role": "assistant"},
+		},
+	}
+}
+
+var speechStarted = &pkg.ServerEvent{
+	Type:  pkg.ServerEventTypeInputAudioBufferSpeechStarted,
+	Param: &pkg.ServerEventParamInputAudioBufferSpeechStarted{},
+}
+
+func TestBargeIn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	server := realtimetest.NewServer(realtimetest.Options{
+		Greeting: []*pkg.ServerEvent{{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{}}}},
+	})
+	defer server.Close()
+	opened := make(chan struct{})
+	c, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+		Events: func(event *pkg.ServerEvent) {
+			if event.Type == pkg.ServerEventTypeSessionCreated {
+				close(opened)
+			}
+		},
+	})
+	select {
+	case <-opened:
+	case <-ctx.Done():
+		t.Fatal("Expected the data channel to open")
+	}
+
+	newBargeIn := func(t *testing.T, playback *fakePlayback) *pkg.BargeIn {
+		t.Helper()
+		b, err := pkg.NewBargeIn(shared.NewStdLogger(), c, playback)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return b
+	}
+	// truncated returns the conversation.item.truncate sent for an item
+	truncated := func(t *testing.T, itemId string, wait bool) (map[string]any, bool) {
+		t.Helper()
+		for {
+			for _, msg := range call.Messages() {
+				if msg.Type != pkg.ClientEventTypeConversationItemTruncate {
+					continue
+				}
+				var event map[string]any
+				if err := json.Unmarshal(msg.Raw, &event); err != nil {
+					t.Fatalf("Expected a JSON event, got %v", err)
+				}
+				if event["item_id"] == itemId {
+					return event, true
+				}
+			}
+			if !wait {
+				return nil, false
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Expected %s to be truncated", itemId)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	t.Run("InterruptsWhilePlaying", func(t *testing.T) {
+		playback := &fakePlayback{written: 2 * time.Second, played: 2 * time.Second}
+		b := newBargeIn(t, playback)
+		b.PipeEvent(assistantItemAdded("item_1"))
+		b.PipeEvent(&pkg.ServerEvent{
+			Type: pkg.ServerEventTypeResponseContentPartAdded,
+			Param: &pkg.ServerEventParamResponseContentPartAdded{
+				ItemId:       "item_1",
+				ContentIndex: 1,
+				Part:         map[string]any{"type": "audio"},
+			},
+		})
+		playback.written += 3 * time.Second
+		playback.played += 1500 * time.Millisecond
+		if !b.PipeEvent(speechStarted) {
+			t.Fatal("Expected speech to interrupt the assistant")
+		}
+		if playback.flushed != 1 {
+			t.Errorf("Expected playback to be flushed once, got %d", playback.flushed)
+		}
+		if b.PipeEvent(speechStarted) {
+			t.Error("Expected a second speech start not to interrupt again")
+		}
+		event, _ := truncated(t, "item_1", true)
+		// What was played of the item, from its start at 2s
+		expected := (playback.Played() - 2*time.Second).Milliseconds()
+		if event["content_index"] != float64(1) || event["audio_end_ms"] != float64(expected) {
+			t.Errorf("Expected content 1 truncated at %d ms, got %v", expected, event)
+		}
+	})
+
+	t.Run("ItemAlreadyHeard", func(t *testing.T) {
+		playback := &fakePlayback{}
+		b := newBargeIn(t, playback)
+		b.PipeEvent(assistantItemAdded("item_2"))
+		playback.written, playback.played = time.Second, time.Second
+		b.PipeEvent(&pkg.ServerEvent{
+			Type:  pkg.ServerEventTypeOutputAudioBufferStopped,
+			Param: &pkg.ServerEventParamOutputAudioBufferStopped{ResponseId: "resp_1"},
+		})
+		if b.PipeEvent(speechStarted) {
+			t.Error("Expected no interruption once the item was fully played")
+		}
+		if playback.flushed != 0 {
+			t.Errorf("Expected playback not to be flushed, got %d", playback.flushed)
+		}
+		if event, ok := truncated(t, "item_2", false); ok {
+			t.Errorf("Expected no truncate, got %v", event)
+		}
+	})
+
+	t.Run("IgnoresUserItems", func(t *testing.T) {
+		playback := &fakePlayback{}
+		b := newBargeIn(t, playback)
+		b.PipeEvent(&pkg.ServerEvent{
+			Type: pkg.ServerEventTypeResponseOutputItemAdded,
+			Param: &pkg.ServerEventParamResponseOutputItemAdded{
+				Item: map[string]any{"id": "fc_1", "type": "function_call"},
+			},
+		})
+		if b.PipeEvent(speechStarted) {
+			t.Error("Expected no interruption without an assistant message")
+		}
+	})
+
+	t.Run("Interrupt", func(t *testing.T) {
+		playback := &fakePlayback{}
+		b := newBargeIn(t, playback)
+		b.PipeEvent(assistantItemAdded("item_3"))
+		playback.written, playback.played = 4*time.Second, 250*time.Millisecond
+		if !b.Interrupt() {
+			t.Fatal("Expected the assistant to be interrupted")
+		}
+		event, _ := truncated(t, "item_3", true)
+		if event["content_index"] != float64(0) || event["audio_end_ms"] != float64(250) {
+			t.Errorf("Expected content 0 truncated at 250 ms, got %v", event)
+		}
+		for _, eventType := range []pkg.ClientEventType{pkg.ClientEventTypeResponseCancel, pkg.ClientEventTypeOutputAudioBufferClear} {
+			if _, err := call.WaitMessage(ctx, eventType); err != nil {
+				t.Errorf("Expected %s while streaming, got %v", eventType, err)
+			}
+		}
+	})
+}
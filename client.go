@@ -3,18 +3,24 @@ package realtime
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"mime/multipart"
 	"net/textproto"
 	"net/url"
+	"path"
 	"sync"
+	"time"
 
 	"github.com/bridge-packages/go-openai-realtime/shared"
 	"github.com/bytedance/sonic"
+	"github.com/openai/openai-go/v3/packages/param"
 	"github.com/openai/openai-go/v3/realtime"
 	"github.com/pion/webrtc/v4"
 	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +28,8 @@ type TrackRemoteHandler func(track *webrtc.TrackRemote)
 type TrackLocalHandler func(track *webrtc.TrackLocalStaticSample)
 
 type EventHandler func(event *ServerEvent)
+type StateHandler func(state webrtc.PeerConnectionState)
+type TextHandler func(delta *ServerEventParamResponseOutputTextDelta)
 
 type ClientState int
 
@@ -34,6 +42,8 @@ const (
 	ClientStateClosed
 )
 
+var errClientClosed = errors.New("client closed")
+
 type Client struct {
 	logger   shared.LoggerAdapter
 	baseUrl  *url.URL
@@ -50,9 +60,22 @@ type Client struct {
 	audioTLH TrackLocalHandler  // track.Kind() == webrtc.RTPCodecTypeAudio
 	audioTRH TrackRemoteHandler // track.Kind() == webrtc.RTPCodecTypeAudio
 	eh       EventHandler
+	th       TextHandler
+	sh       StateHandler
+	journal  *Journal
+	tracer   *clientTracer
+
+	rateLimits *RateLimits
 
 	state     webrtc.PeerConnectionState
 	connected <-chan struct{}
+	callId    string
+	stats     webrtc.StatsReport // of the closed peer connection
+
+	pushToTalk bool
+	talking    bool
+	textOnly   bool
+	muted      bool
 
 	ctx    context.Context
 	cancel context.CancelCauseFunc
@@ -65,13 +88,15 @@ func (c *Client) Close() error {
 		return fmt.Errorf("respecting client context: %w", err)
 	}
 	if c.pc != nil {
+		// Read once more, the statistics are gone with the peer connection
+		c.stats = c.pc.GetStats()
 		if err := c.pc.Close(); err != nil {
 			c.logger.Error("closing peer connection failed", err)
 		}
 		c.pc = nil
 	}
 	if c.cancel != nil {
-		c.cancel(errors.New("client closed"))
+		c.cancel(errClientClosed)
 		c.cancel = nil
 	}
 	c.running = false
@@ -84,6 +109,26 @@ func (c *Client) DC() *webrtc.DataChannel {
 	return c.dc
 }
 
+// CallID returns the ID of the call once the session is started, it is empty
+// if the server did not return it.
+func (c *Client) CallID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.callId
+}
+
+// Stats returns the WebRTC statistics of the peer connection, the last ones
+// once the client is closed.
+func (c *Client) Stats() webrtc.StatsReport {
+	c.mu.Lock()
+	pc, stats := c.pc, c.stats
+	c.mu.Unlock()
+	if pc == nil {
+		return stats
+	}
+	return pc.GetStats()
+}
+
 func (c *Client) Done() <-chan struct{} {
 	return c.ctx.Done()
 }
@@ -98,6 +143,23 @@ func (c *Client) State() webrtc.PeerConnectionState {
 	return c.state
 }
 
+// SetMuted mutes or unmutes the microphone, the local track keeps running.
+// It can be called at any time.
+func (c *Client) SetMuted(muted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.muted != muted {
+		c.logger.Info("microphone mute changed", zap.Bool("muted", muted))
+	}
+	c.muted = muted
+}
+
+func (c *Client) Muted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.muted
+}
+
 func NewClient(ctx context.Context, logger shared.LoggerAdapter, apikey, greeting, baseUrl string) (c *Client, err error) {
 	if logger == nil {
 		return nil, shared.ErrNoLogger
@@ -118,14 +180,31 @@ func NewClient(ctx context.Context, logger shared.LoggerAdapter, apikey, greetin
 			Path:   "/v1",
 		}
 	}
-	ctx, cancel := context.WithCancelCause(ctx)
+	tracer := newClientTracer(ctx)
+	ctx, cancel := context.WithCancelCause(tracer.ctx)
+	go func() {
+		<-ctx.Done()
+		tracer.end(context.Cause(ctx))
+	}()
+	_, span := tracer.start(nil, SpanNewClient)
+	defer func() {
+		if err != nil {
+			fail(span, err)
+			cancel(err)
+			return
+		}
+		span.End()
+	}()
 	c = &Client{
 		logger:   logger,
 		baseUrl:  baseUrl_,
 		apiKey:   apikey,
 		greeting: greeting,
+		tracer:   tracer,
 		ctx:      ctx,
 		cancel:   cancel,
+
+		rateLimits: NewRateLimits(nil, 0),
 	}
 
 	// Creating a new WebRTC API object
@@ -139,6 +218,12 @@ func NewClient(ctx context.Context, logger shared.LoggerAdapter, apikey, greetin
 
 	// Setting up Connection State Change handler
 	c.pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		c.mu.Lock()
+		sh := c.sh
+		c.mu.Unlock()
+		if sh != nil {
+			sh(state)
+		}
 		c.mu.Lock()
 		defer c.mu.Unlock()
 		if err := c.respectCtx(); err != nil {
@@ -163,7 +248,9 @@ func NewClient(ctx context.Context, logger shared.LoggerAdapter, apikey, greetin
 			if !connectedGotClosed {
 				connectedGotClosed = true
 				close(connected)
-				go c.audioTLH(c.audioL)
+				if c.audioTLH != nil {
+					go c.audioTLH(c.audioL)
+				}
 				return
 			}
 			c.logger.Warn("peer connection state is connected (More than once)")
@@ -188,6 +275,9 @@ func NewClient(ctx context.Context, logger shared.LoggerAdapter, apikey, greetin
 		}
 	})
 
+	// Tracing the ICE connection, see Start
+	c.pc.OnICEConnectionStateChange(tracer.iceState)
+
 	// Creating data channel
 	c.dc, err = c.pc.CreateDataChannel("oai", nil)
 	if err != nil {
@@ -225,6 +315,9 @@ func (c *Client) RegisterTrackLocalHandler(handler TrackLocalHandler) error {
 	if c.running {
 		return shared.ErrSessionAlreadyRunning
 	}
+	if c.textOnly {
+		return shared.ErrAudioInTextOnly
+	}
 	if c.audioTLH != nil || c.audioL != nil {
 		return shared.ErrTLHandlerAlreadySet
 	}
@@ -261,6 +354,9 @@ func (c *Client) RegisterTrackRemoteHandler(handler TrackRemoteHandler) error {
 	if c.running {
 		return shared.ErrSessionAlreadyRunning
 	}
+	if c.textOnly {
+		return shared.ErrAudioInTextOnly
+	}
 	if c.audioTRH != nil {
 		return shared.ErrTRHandlerAlreadySet
 	}
@@ -276,6 +372,24 @@ func (c *Client) RegisterTrackRemoteHandler(handler TrackRemoteHandler) error {
 	return nil
 }
 
+// RegisterStateHandler is called on every peer connection state change, e.g.
+// for metrics.
+func (c *Client) RegisterStateHandler(handler StateHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return shared.ErrSessionAlreadyRunning
+	}
+	if c.sh != nil {
+		return shared.ErrSHandlerAlreadySet
+	}
+	if handler == nil {
+		return errors.New("handler is required")
+	}
+	c.sh = handler
+	return nil
+}
+
 func (c *Client) RegisterEventHandler(handler EventHandler) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -305,6 +419,11 @@ func (c *Client) RegisterEventHandler(handler EventHandler) error {
 			c.logger.Error("sending start message", err)
 			return
 		}
+		if c.journal != nil {
+			if err := c.journal.record(JournalOutbound, smb); err != nil {
+				c.logger.Error("recording start message in journal", err)
+			}
+		}
 		c.logger.Info("data channel opened and start message sent")
 	})
 	c.dc.OnMessage(func(msg webrtc.DataChannelMessage) {
@@ -312,27 +431,46 @@ func (c *Client) RegisterEventHandler(handler EventHandler) error {
 			c.logger.Warn("received non-string message on data channel")
 			return
 		}
-		event := new(ServerEvent)
-		if err := event.UnmarshalJSON(msg.Data); err != nil {
-			c.logger.Error(
-				"can not unmarshal event",
-				err,
-				zap.ByteString("data", msg.Data),
-			)
-			return
-		}
-		c.logger.Info(
-			"received event",
-			zap.String("type", string(event.Type)),
-			zap.String("event_id", event.EventId),
-			zap.Any("param", event.Param),
-		)
-		c.eh(event)
+		c.dispatch(msg.Data)
 	})
 	return nil
 }
 
-func (c *Client) Start() error {
+// dispatch hands an event received from the server to the handlers.
+func (c *Client) dispatch(data []byte) {
+	event := new(ServerEvent)
+	if err := event.UnmarshalJSON(data); err != nil {
+		c.logger.Error(
+			"can not unmarshal event",
+			err,
+			zap.ByteString("data", data),
+		)
+		return
+	}
+	c.logger.Info(
+		"received event",
+		zap.String("type", string(event.Type)),
+		zap.String("event_id", event.EventId),
+		zap.Any("param", event.Param),
+	)
+	if c.journal != nil {
+		if err := c.journal.RecordServerEvent(event); err != nil {
+			c.logger.Error("recording event in journal", err)
+		}
+	}
+	if c.tracer != nil {
+		c.tracer.pipeEvent(event)
+	}
+	if p, ok := event.Param.(*ServerEventParamRatelimitsUpdated); ok && c.rateLimits != nil {
+		c.rateLimits.Update(p.Limits(time.Now()))
+	}
+	if c.th != nil && event.Type == ServerEventTypeResponseOutputTextDelta {
+		c.th(event.Param.(*ServerEventParamResponseOutputTextDelta))
+	}
+	c.eh(event)
+}
+
+func (c *Client) Start() (err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.running {
@@ -341,43 +479,131 @@ func (c *Client) Start() error {
 	if c.cfg == nil {
 		return shared.ErrNoConfig
 	}
+	if c.pushToTalk && (c.cfg.Audio.Input.TurnDetection.OfServerVad != nil || c.cfg.Audio.Input.TurnDetection.OfSemanticVad != nil) {
+		return shared.ErrTurnDetectionEnabled
+	}
 	if c.pc == nil || c.dc == nil {
 		return shared.ErrClientNotInitialized
 	}
 	if c.eh == nil {
 		return shared.ErrNoEventHandler
 	}
+	attrs := []attribute.KeyValue{
+		AttrModel.String(c.cfg.Model),
+		AttrVoice.String(string(c.cfg.Audio.Output.Voice)),
+	}
+	c.tracer.session.SetAttributes(attrs...)
+	ctx, span := c.tracer.start(c.ctx, SpanStart, attrs...)
+	defer func() {
+		if err != nil {
+			fail(span, err)
+			return
+		}
+		span.End()
+	}()
+	_, offerSpan := c.tracer.start(ctx, SpanCreateOffer)
 	offer, err := c.pc.CreateOffer(nil)
 	if err != nil {
+		fail(offerSpan, err)
 		c.cancel(fmt.Errorf("creating offer: %w", err))
 		return fmt.Errorf("creating offer: %w", err)
 	}
 	if err = c.pc.SetLocalDescription(offer); err != nil {
+		fail(offerSpan, err)
 		c.cancel(fmt.Errorf("setting local description: %w", err))
 		return fmt.Errorf("setting local description: %w", err)
 	}
+	offerSpan.End()
 	if err := c.respectCtx(); err != nil {
 		return fmt.Errorf("respecting client context: %w", err)
 	}
-	answerOffer, err := c.createSession(offer.SDP)
+	cfg := *c.cfg
+	if c.pushToTalk {
+		cfg.Audio.Input.TurnDetection = param.Override[realtime.RealtimeAudioInputTurnDetectionUnionParam](json.RawMessage("null"))
+	}
+	if c.textOnly {
+		cfg.OutputModalities = []string{"text"}
+	}
+	answerOffer, callId, err := c.createSession(ctx, &cfg, offer.SDP)
 	if err != nil {
 		c.cancel(fmt.Errorf("creating session: %w", err))
 		return fmt.Errorf("creating session: %w", err)
 	}
+	c.callId = callId
+	c.tracer.session.SetAttributes(AttrCallId.String(callId))
+	span.SetAttributes(AttrCallId.String(callId))
+	c.tracer.startICE(ctx)
+	_, remoteSpan := c.tracer.start(ctx, SpanSetRemoteDescription)
 	if err := c.pc.SetRemoteDescription(webrtc.SessionDescription{
 		Type: webrtc.SDPTypeAnswer,
 		SDP:  answerOffer,
 	}); err != nil {
+		fail(remoteSpan, err)
 		c.cancel(fmt.Errorf("setting remote description: %w", err))
 		return err
 	}
+	remoteSpan.End()
 	return nil
 }
 
-func (c *Client) createSession(offer string) (answerOffer string, err error) {
-	sessBytes, err := c.cfg.MarshalJSON()
+func (c *Client) SendEvent(event *ClientEvent) error {
+	if event == nil {
+		return errors.New("event is required")
+	}
+	c.mu.Lock()
+	dc := c.dc
+	rateLimits := c.rateLimits
+	c.mu.Unlock()
+	if err := c.respectCtx(); err != nil {
+		return fmt.Errorf("respecting client context: %w", err)
+	}
+	if dc == nil {
+		return shared.ErrClientNotInitialized
+	}
+	if p, ok := event.Param.(*ClientEventParamResponseCreate); ok && p.outOfBand() && rateLimits != nil {
+		if err := rateLimits.wait(c.ctx); err != nil {
+			return fmt.Errorf("waiting for rate limits: %w", err)
+		}
+	}
+	data, err := event.MarshalJSON()
 	if err != nil {
-		return "", fmt.Errorf("marshaling config: %w", err)
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+	if err := dc.Send(data); err != nil {
+		return fmt.Errorf("sending event: %w", err)
+	}
+	if c.journal != nil {
+		if err := c.journal.record(JournalOutbound, data); err != nil {
+			c.logger.Error("recording event in journal", err)
+		}
+	}
+	if c.tracer != nil {
+		c.tracer.sent(event)
+	}
+	c.logger.Info(
+		"sent event",
+		zap.String("type", string(event.Type)),
+		zap.String("event_id", event.EventId),
+	)
+	return nil
+}
+
+func (c *Client) createSession(ctx context.Context, cfg *realtime.RealtimeSessionCreateRequestParam, offer string) (answerOffer, callId string, err error) {
+	uri := c.baseUrl.JoinPath("/realtime/calls").String()
+	_, span := c.tracer.start(ctx, SpanCreateCall,
+		semconv.HTTPRequestMethodPost,
+		semconv.URLFull(uri),
+	)
+	defer func() {
+		if err != nil {
+			fail(span, err)
+			return
+		}
+		span.End()
+	}()
+	sessBytes, err := cfg.MarshalJSON()
+	if err != nil {
+		return "", "", fmt.Errorf("marshaling config: %w", err)
 	}
 	body := new(bytes.Buffer)
 	writer := multipart.NewWriter(body)
@@ -388,10 +614,10 @@ func (c *Client) createSession(offer string) (answerOffer string, err error) {
 	sdpHeaders.Set("Content-Type", "application/sdp")
 	sdpPart, err := writer.CreatePart(sdpHeaders)
 	if err != nil {
-		return "", fmt.Errorf("creating SDP part: %w", err)
+		return "", "", fmt.Errorf("creating SDP part: %w", err)
 	}
 	if _, err = sdpPart.Write([]byte(offer)); err != nil {
-		return "", fmt.Errorf("writing SDP part: %w", err)
+		return "", "", fmt.Errorf("writing SDP part: %w", err)
 	}
 
 	// Session part
@@ -400,14 +626,14 @@ func (c *Client) createSession(offer string) (answerOffer string, err error) {
 	sessionHeaders.Set("Content-Type", "application/json")
 	sessionPart, err := writer.CreatePart(sessionHeaders)
 	if err != nil {
-		return "", fmt.Errorf("creating session part: %w", err)
+		return "", "", fmt.Errorf("creating session part: %w", err)
 	}
 	if _, err = sessionPart.Write(sessBytes); err != nil {
-		return "", fmt.Errorf("writing session part: %w", err)
+		return "", "", fmt.Errorf("writing session part: %w", err)
 	}
 
 	if err = writer.Close(); err != nil {
-		return "", fmt.Errorf("closing multipart writer: %w", err)
+		return "", "", fmt.Errorf("closing multipart writer: %w", err)
 	}
 
 	req := fasthttp.AcquireRequest()
@@ -415,7 +641,7 @@ func (c *Client) createSession(offer string) (answerOffer string, err error) {
 	defer fasthttp.ReleaseRequest(req)
 	defer fasthttp.ReleaseResponse(resp)
 
-	req.SetRequestURI(c.baseUrl.JoinPath("/realtime/calls").String())
+	req.SetRequestURI(uri)
 	req.Header.SetMethod(fasthttp.MethodPost)
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
@@ -427,15 +653,20 @@ func (c *Client) createSession(offer string) (answerOffer string, err error) {
 		errC <- fasthttp.Do(req, resp)
 	}()
 	select {
-	case <-c.ctx.Done():
-		return "", c.ctx.Err()
+	case <-ctx.Done():
+		return "", "", ctx.Err()
 	case err := <-errC:
 		if err != nil {
-			return "", fmt.Errorf("performing HTTP request: %w", err)
+			return "", "", fmt.Errorf("performing HTTP request: %w", err)
 		}
 	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode()))
 	if resp.StatusCode() != fasthttp.StatusCreated {
-		return "", fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+		return "", "", fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode(), string(resp.Body()))
+	}
+	// The call is at /v1/realtime/calls/{call_id}
+	if location := string(resp.Header.Peek(fasthttp.HeaderLocation)); location != "" {
+		callId = path.Base(location)
 	}
-	return string(resp.Body()[:]), nil
+	return string(resp.Body()[:]), callId, nil
 }
@@ -0,0 +1,281 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/goccy/go-yaml"
+)
+
+type ClientEvent struct {
+	EventId string // optional, the server echoes it back in error events
+	Type    ClientEventType
+	Param   EventParam
+}
+
+var _ Event = (*ClientEvent)(nil)
+
+func (e *ClientEvent) EventType() EventType {
+	return EventType(e.Type)
+}
+
+func (e *ClientEvent) IsServerEvent() bool {
+	return false
+}
+
+func (e *ClientEvent) IsClientEvent() bool {
+	return true
+}
+
+func (e *ClientEvent) MarshalYAML() ([]byte, error) {
+	resp, err := e.toMap()
+	if err != nil {
+		return nil, err
+	}
+	return yaml.MarshalWithOptions(resp, yaml.UseJSONMarshaler())
+}
+
+func (e *ClientEvent) UnmarshalYAML(data []byte) error {
+	var raw map[string]any
+	if err := yaml.UnmarshalWithOptions(data, &raw, yaml.UseJSONUnmarshaler()); err != nil {
+		return err
+	}
+	return e.fromMap(raw)
+}
+
+func (e *ClientEvent) MarshalJSON() ([]byte, error) {
+	resp, err := e.toMap()
+	if err != nil {
+		return nil, err
+	}
+	return sonic.Marshal(resp)
+}
+
+func (e *ClientEvent) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	if err := sonic.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return e.fromMap(raw)
+}
+
+func (e *ClientEvent) toMap() (map[string]any, error) {
+	if e.Type == "" {
+		return nil, errors.New("Type is empty")
+	}
+	if e.Param == nil {
+		return nil, errors.New("Param is nil")
+	}
+	resp := map[string]any{}
+	for k, v := range e.Param.Json() {
+		resp[k] = v
+	}
+	if e.EventId != "" {
+		resp["event_id"] = e.EventId
+	}
+	resp["type"] = e.Type
+	return resp, nil
+}
+
+func (e *ClientEvent) fromMap(raw map[string]any) error {
+	if v, ok := raw["event_id"].(string); ok {
+		e.EventId = v
+		delete(raw, "event_id")
+	}
+	if v, ok := raw["type"].(string); ok {
+		e.Type = ClientEventType(v)
+		delete(raw, "type")
+	} else {
+		return errors.New("missing type")
+	}
+	switch e.Type {
+	case ClientEventTypeSessionUpdate:
+		e.Param = new(ClientEventParamSessionUpdate)
+	case ClientEventTypeConversationItemCreate:
+		e.Param = new(ClientEventParamConversationItemCreate)
+	case ClientEventTypeConversationItemTruncate:
+		e.Param = new(ClientEventParamConversationItemTruncate)
+	case ClientEventTypeInputAudioBufferCommit:
+		e.Param = new(ClientEventParamInputAudioBufferCommit)
+	case ClientEventTypeInputAudioBufferClear:
+		e.Param = new(ClientEventParamInputAudioBufferClear)
+	case ClientEventTypeResponseCreate:
+		e.Param = new(ClientEventParamResponseCreate)
+	case ClientEventTypeResponseCancel:
+		e.Param = new(ClientEventParamResponseCancel)
+	case ClientEventTypeOutputAudioBufferClear:
+		e.Param = new(ClientEventParamOutputAudioBufferClear)
+	default:
+		return fmt.Errorf("unknown event type: %s", e.Type)
+	}
+	return e.Param.New(raw)
+}
+
+// session.update
+type ClientEventParamSessionUpdate struct {
+	Session map[string]any // the fields to update, "type" is required
+}
+
+func (p *ClientEventParamSessionUpdate) New(m map[string]any) error {
+	if session, ok := m["session"].(map[string]any); ok {
+		p.Session = session
+	} else {
+		return errors.New("missing session")
+	}
+	return nil
+}
+
+func (p *ClientEventParamSessionUpdate) Json() map[string]any {
+	return map[string]any{
+		"session": p.Session,
+	}
+}
+
+// conversation.item.create
+type ClientEventParamConversationItemCreate struct {
+	PreviousItemId string
+	Item           map[string]any
+}
+
+func (p *ClientEventParamConversationItemCreate) New(m map[string]any) error {
+	if v, ok := m["previous_item_id"].(string); ok {
+		p.PreviousItemId = v
+	} else {
+		p.PreviousItemId = ""
+	}
+	if item, ok := m["item"].(map[string]any); ok {
+		p.Item = item
+	} else {
+		return errors.New("missing item")
+	}
+	return nil
+}
+
+func (p *ClientEventParamConversationItemCreate) Json() map[string]any {
+	resp := map[string]any{
+		"item": p.Item,
+	}
+	if p.PreviousItemId != "" {
+		resp["previous_item_id"] = p.PreviousItemId
+	}
+	return resp
+}
+
+// conversation.item.truncate
+type ClientEventParamConversationItemTruncate struct {
+	ItemId       string
+	ContentIndex int
+	AudioEndMs   int
+}
+
+func (p *ClientEventParamConversationItemTruncate) New(m map[string]any) error {
+	if v, ok := m["item_id"].(string); ok {
+		p.ItemId = v
+	} else {
+		return errors.New("missing item_id")
+	}
+	if v, ok := asInt(m["content_index"]); ok {
+		p.ContentIndex = v
+	} else {
+		return errors.New("missing content_index")
+	}
+	if v, ok := asInt(m["audio_end_ms"]); ok {
+		p.AudioEndMs = v
+	} else {
+		return errors.New("missing audio_end_ms")
+	}
+	return nil
+}
+
+func (p *ClientEventParamConversationItemTruncate) Json() map[string]any {
+	return map[string]any{
+		"item_id":       p.ItemId,
+		"content_index": p.ContentIndex,
+		"audio_end_ms":  p.AudioEndMs,
+	}
+}
+
+// input_audio_buffer.commit
+type ClientEventParamInputAudioBufferCommit struct{}
+
+func (p *ClientEventParamInputAudioBufferCommit) New(m map[string]any) error {
+	return nil
+}
+
+func (p *ClientEventParamInputAudioBufferCommit) Json() map[string]any {
+	return map[string]any{}
+}
+
+// input_audio_buffer.clear
+type ClientEventParamInputAudioBufferClear struct{}
+
+func (p *ClientEventParamInputAudioBufferClear) New(m map[string]any) error {
+	return nil
+}
+
+func (p *ClientEventParamInputAudioBufferClear) Json() map[string]any {
+	return map[string]any{}
+}
+
+// response.create
+type ClientEventParamResponseCreate struct {
+	Response map[string]any // optional response parameters
+}
+
+func (p *ClientEventParamResponseCreate) New(m map[string]any) error {
+	if v, ok := m["response"].(map[string]any); ok {
+		p.Response = v
+	} else {
+		p.Response = nil
+	}
+	return nil
+}
+
+func (p *ClientEventParamResponseCreate) Json() map[string]any {
+	resp := map[string]any{}
+	if p.Response != nil {
+		resp["response"] = p.Response
+	}
+	return resp
+}
+
+// outOfBand reports whether the response stays out of the conversation, e.g.
+// a classification in the background.
+func (p *ClientEventParamResponseCreate) outOfBand() bool {
+	conversation, _ := p.Response["conversation"].(string)
+	return conversation == "none"
+}
+
+// response.cancel
+type ClientEventParamResponseCancel struct {
+	ResponseId string // optional, the in-progress response is cancelled if empty
+}
+
+func (p *ClientEventParamResponseCancel) New(m map[string]any) error {
+	if v, ok := m["response_id"].(string); ok {
+		p.ResponseId = v
+	} else {
+		p.ResponseId = ""
+	}
+	return nil
+}
+
+func (p *ClientEventParamResponseCancel) Json() map[string]any {
+	resp := map[string]any{}
+	if p.ResponseId != "" {
+		resp["response_id"] = p.ResponseId
+	}
+	return resp
+}
+
+// output_audio_buffer.clear
+type ClientEventParamOutputAudioBufferClear struct{}
+
+func (p *ClientEventParamOutputAudioBufferClear) New(m map[string]any) error {
+	return nil
+}
+
+func (p *ClientEventParamOutputAudioBufferClear) Json() map[string]any {
+	return map[string]any{}
+}
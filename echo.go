@@ -0,0 +1,55 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// EchoGuard tells whether the assistant may be audible in the microphone,
+// from the output audio buffer events and the local playback. It stays active
+// for a tail time after playback ends, to cover the output device latency and
+// the room reverb.
+type EchoGuard struct {
+	playback PlaybackController // optional
+	tail     time.Duration
+
+	mu        sync.Mutex
+	streaming bool
+	until     time.Time
+	now       func() time.Time
+}
+
+func NewEchoGuard(playback PlaybackController, tail time.Duration) *EchoGuard {
+	return &EchoGuard{
+		playback: playback,
+		tail:     tail,
+		now:      time.Now,
+	}
+}
+
+func (g *EchoGuard) PipeEvent(event *ServerEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch event.Type {
+	case ServerEventTypeOutputAudioBufferStarted:
+		g.streaming = true
+	case ServerEventTypeOutputAudioBufferStopped, ServerEventTypeOutputAudioBufferCleared:
+		g.streaming = false
+		g.until = g.now().Add(g.tail)
+	}
+}
+
+// Active reports whether the microphone should be protected from echo.
+func (g *EchoGuard) Active() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	if g.streaming {
+		return true
+	}
+	if g.playback != nil && g.playback.Played() < g.playback.Written() {
+		g.until = now.Add(g.tail)
+		return true
+	}
+	return now.Before(g.until)
+}
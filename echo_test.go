@@ -0,0 +1,45 @@
+package realtime_test
+
+import (
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+)
+
+func TestEchoGuard(t *testing.T) {
+	playback := &fakePlayback{}
+	g := pkg.NewEchoGuard(playback, 300*time.Millisecond)
+	now := time.Unix(0, 0)
+	pkg.SetEchoGuardClock(g, func() time.Time { return now })
+
+	if g.Active() {
+		t.Fatal("Expected the guard to be inactive before any audio")
+	}
+	g.PipeEvent(&pkg.ServerEvent{
+		Type:  pkg.ServerEventTypeOutputAudioBufferStarted,
+		Param: &pkg.ServerEventParamOutputAudioBufferStarted{ResponseId: "resp_1"},
+	})
+	if !g.Active() {
+		t.Error("Expected the guard to be active while the server streams audio")
+	}
+	g.PipeEvent(&pkg.ServerEvent{
+		Type:  pkg.ServerEventTypeOutputAudioBufferStopped,
+		Param: &pkg.ServerEventParamOutputAudioBufferStopped{ResponseId: "resp_1"},
+	})
+	playback.written = 2 * time.Second
+	playback.played = time.Second
+	now = now.Add(time.Second)
+	if !g.Active() {
+		t.Error("Expected the guard to be active while audio is still played")
+	}
+	playback.played = playback.written
+	now = now.Add(200 * time.Millisecond)
+	if !g.Active() {
+		t.Error("Expected the guard to be active during the tail")
+	}
+	now = now.Add(200 * time.Millisecond)
+	if g.Active() {
+		t.Error("Expected the guard to be inactive after the tail")
+	}
+}
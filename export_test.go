@@ -0,0 +1,14 @@
+package realtime
+
+import "time"
+
+// ObserveQuality feeds a sample to the monitor as if it was read from the
+// statistics.
+func ObserveQuality(m *QualityMonitor, sample QualitySample) {
+	m.observe(sample)
+}
+
+// SetEchoGuardClock replaces the clock of the guard.
+func SetEchoGuardClock(g *EchoGuard, now func() time.Time) {
+	g.now = now
+}
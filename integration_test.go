@@ -0,0 +1,138 @@
+package realtime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/openai/openai-go/v3/realtime"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestFakeServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	server := realtimetest.NewServer(realtimetest.Options{
+		Greeting: []*pkg.ServerEvent{
+			{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{"id": "sess_test"}}},
+		},
+		Replies: map[pkg.ClientEventType][]*pkg.ServerEvent{
+			pkg.ClientEventTypeConversationItemCreate: {
+				{Type: pkg.ServerEventTypeResponseOutputTextDelta, Param: &pkg.ServerEventParamResponseOutputTextDelta{ResponseId: "resp_1", ItemId: "item_1", Delta: "Hi"}},
+			},
+		},
+		Audio: realtimetest.AudioEcho,
+	})
+	defer server.Close()
+
+	events := make(chan *pkg.ServerEvent, 16)
+	echoed := make(chan struct{})
+	c, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+		Greeting: "Hello",
+		Events:   func(event *pkg.ServerEvent) { events <- event },
+		Remote: func(track *webrtc.TrackRemote) {
+			if _, _, err := track.ReadRTP(); err == nil {
+				close(echoed)
+			}
+		},
+	})
+	if state := c.State(); state != webrtc.PeerConnectionStateConnected {
+		t.Fatalf("Expected connected, got %v", state)
+	}
+
+	t.Run("CallID", func(t *testing.T) {
+		if c.CallID() != call.ID {
+			t.Errorf("Expected %q, got %q", call.ID, c.CallID())
+		}
+	})
+
+	t.Run("Greeting", func(t *testing.T) {
+		msg, err := call.WaitMessage(ctx, pkg.ClientEventTypeResponseCreate)
+		if err != nil {
+			t.Fatalf("Expected the start message, got %v", err)
+		}
+		if msg.Event == nil {
+			t.Fatalf("Expected the start message to parse, got %s", msg.Raw)
+		}
+		select {
+		case event := <-events:
+			if event.Type != pkg.ServerEventTypeSessionCreated || event.EventId == "" {
+				t.Errorf("Expected session.created with an event ID, got %+v", event)
+			}
+		case <-ctx.Done():
+			t.Fatal("Expected session.created")
+		}
+	})
+
+	t.Run("Replies", func(t *testing.T) {
+		if err := c.SendText("Hey"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		msg, err := call.WaitMessage(ctx, pkg.ClientEventTypeConversationItemCreate)
+		if err != nil {
+			t.Fatalf("Expected conversation.item.create, got %v", err)
+		}
+		if msg.Event == nil {
+			t.Errorf("Expected a parsed event, got %s", msg.Raw)
+		}
+		select {
+		case event := <-events:
+			if delta, ok := event.Param.(*pkg.ServerEventParamResponseOutputTextDelta); !ok || delta.Delta != "Hi" {
+				t.Errorf("Expected the scripted text delta, got %+v", event)
+			}
+		case <-ctx.Done():
+			t.Fatal("Expected the scripted reply")
+		}
+	})
+
+	t.Run("Echo", func(t *testing.T) {
+		select {
+		case <-echoed:
+		case <-ctx.Done():
+			t.Fatal("Expected the audio to be echoed")
+		}
+	})
+}
+
+func TestFakeServerConcurrentCalls(t *testing.T) {
+	server := realtimetest.NewServer(realtimetest.Options{})
+	defer server.Close()
+	const n = 8
+	clients := make([]*pkg.Client, n)
+	for i := range clients {
+		c, err := pkg.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", server.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer func() { _ = c.Close() }()
+		if err := c.SetConfig(&realtime.RealtimeSessionCreateRequestParam{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.RegisterEventHandler(func(*pkg.ServerEvent) {}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.SetTextOnly(true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		clients[i] = c
+	}
+	errs := make(chan error, n)
+	for _, c := range clients {
+		go func() { errs <- c.Start() }()
+	}
+	for range n {
+		if err := <-errs; err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	ids := map[string]bool{}
+	for _, c := range clients {
+		ids[c.CallID()] = true
+	}
+	if len(ids) != n {
+		t.Errorf("Expected %d distinct call IDs, got %v", n, ids)
+	}
+}
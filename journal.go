@@ -0,0 +1,194 @@
+package realtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/bytedance/sonic"
+)
+
+type JournalDirection string
+
+const (
+	JournalInbound  JournalDirection = "in"  // server events
+	JournalOutbound JournalDirection = "out" // client events
+)
+
+// JournalEntry is a line of a journal.
+type JournalEntry struct {
+	Time      time.Time        `json:"time"`
+	Direction JournalDirection `json:"direction"`
+	Event     json.RawMessage  `json:"event"`
+}
+
+// Journal writes the events of a session as JSON lines, see Client.SetJournal
+// and Client.Replay.
+type Journal struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+func NewJournal(w io.Writer) *Journal {
+	return &Journal{w: w, now: time.Now}
+}
+
+// CreateJournal creates the file at path.
+func CreateJournal(path string) (*Journal, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating journal: %w", err)
+	}
+	return NewJournal(f), nil
+}
+
+func (j *Journal) RecordServerEvent(event *ServerEvent) error {
+	data, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+	return j.record(JournalInbound, data)
+}
+
+func (j *Journal) RecordClientEvent(event *ClientEvent) error {
+	data, err := event.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+	return j.record(JournalOutbound, data)
+}
+
+func (j *Journal) record(direction JournalDirection, event []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	line, err := sonic.Marshal(JournalEntry{
+		Time:      j.now(),
+		Direction: direction,
+		Event:     event,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling journal entry: %w", err)
+	}
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying writer when it is an io.Closer.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// JournalReader reads the entries of a journal.
+type JournalReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func NewJournalReader(r io.Reader) *JournalReader {
+	return &JournalReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next entry, io.EOF at the end of the journal. Empty lines
+// are skipped.
+func (jr *JournalReader) Next() (JournalEntry, error) {
+	for {
+		line, err := jr.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return JournalEntry{}, err
+		}
+		jr.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := sonic.Unmarshal(line, &entry); err != nil {
+			return JournalEntry{}, fmt.Errorf("parsing journal line %d: %w", jr.line, err)
+		}
+		return entry, nil
+	}
+}
+
+// ReplayJournal calls fn with the entries of a journal, paced like they were
+// recorded. speed divides the delays, 0 replays without waiting.
+func ReplayJournal(ctx context.Context, r io.Reader, speed float64, fn func(entry JournalEntry) error) error {
+	if speed < 0 {
+		return errors.New("speed must not be negative")
+	}
+	jr := NewJournalReader(r)
+	var (
+		first time.Time
+		start = time.Now()
+	)
+	for {
+		entry, err := jr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first.IsZero() {
+			first = entry.Time
+		}
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(entry.Time.Sub(first)) / speed))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(at)):
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// SetJournal records the events sent and received in the journal. It must be
+// called before Start, the journal is left open.
+func (c *Client) SetJournal(journal *Journal) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return shared.ErrSessionAlreadyRunning
+	}
+	c.journal = journal
+	return nil
+}
+
+// Replay feeds the server events of a journal to the registered handlers,
+// through the same path as the events of a live session, see ReplayJournal.
+// No session is needed, so agents can be reproduced offline.
+func (c *Client) Replay(ctx context.Context, r io.Reader, speed float64) error {
+	c.mu.Lock()
+	eh := c.eh
+	c.mu.Unlock()
+	if eh == nil {
+		return shared.ErrNoEventHandler
+	}
+	return ReplayJournal(ctx, r, speed, func(entry JournalEntry) error {
+		if entry.Direction == JournalInbound {
+			c.dispatch(entry.Event)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+func TestJournal(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	buf := new(bytes.Buffer)
+	j := NewJournal(buf)
+	j.now = func() time.Time { return clock }
+
+	events := []*ServerEvent{
+		{EventId: "event_1", Type: ServerEventTypeResponseCreated, Param: &ServerEventParamResponseCreated{Response: map[string]any{"id": "resp_1"}}},
+		{EventId: "event_2", Type: ServerEventTypeResponseOutputTextDelta, Param: &ServerEventParamResponseOutputTextDelta{ResponseId: "resp_1", ItemId: "item_1", Delta: "Hello"}},
+	}
+	if err := j.RecordServerEvent(events[0]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clock = clock.Add(100 * time.Millisecond)
+	if err := j.RecordClientEvent(&ClientEvent{EventId: "event_3", Type: ClientEventTypeResponseCancel, Param: &ClientEventParamResponseCancel{}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clock = clock.Add(100 * time.Millisecond)
+	if err := j.RecordServerEvent(events[1]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	journal := buf.Bytes()
+
+	t.Run("Lines", func(t *testing.T) {
+		if n := bytes.Count(journal, []byte("\n")); n != 3 {
+			t.Fatalf("Expected 3 lines, got %d", n)
+		}
+		jr := NewJournalReader(bytes.NewReader(journal))
+		entry, err := jr.Next()
+		if err != nil || entry.Direction != JournalInbound || !entry.Time.Equal(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected the first inbound entry, got %+v (%v)", entry, err)
+		}
+		entry, _ = jr.Next()
+		if entry.Direction != JournalOutbound {
+			t.Errorf("Expected an outbound entry, got %+v", entry)
+		}
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		c, err := NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		t.Cleanup(func() { _ = c.Close() })
+		if err := c.Replay(context.Background(), bytes.NewReader(journal), 0); err == nil {
+			t.Error("Expected an error without event handler")
+		}
+		var got []*ServerEvent
+		var text string
+		if err := c.RegisterEventHandler(func(event *ServerEvent) { got = append(got, event) }); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.RegisterTextHandler(func(delta *ServerEventParamResponseOutputTextDelta) { text += delta.Delta }); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		start := time.Now()
+		// 200 ms recorded, replayed 10 times faster
+		if err := c.Replay(context.Background(), bytes.NewReader(journal), 10); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > time.Second {
+			t.Errorf("Expected the replay to take about 20ms, took %v", elapsed)
+		}
+		if len(got) != 2 || got[0].EventId != "event_1" || got[1].Type != ServerEventTypeResponseOutputTextDelta {
+			t.Errorf("Expected the 2 server events in order, got %+v", got)
+		}
+		if text != "Hello" {
+			t.Errorf("Expected the text handler to be called, got %q", text)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := ReplayJournal(ctx, bytes.NewReader(journal), 0, func(JournalEntry) error { return nil })
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
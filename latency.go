@@ -0,0 +1,261 @@
+package realtime
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+type LatencyStage string
+
+const (
+	LatencyUserPerceived LatencyStage = "user_perceived" // end of speech until the first sample played, or the first audio without playback
+	LatencyCommit        LatencyStage = "commit"         // speech stopped until committed
+	LatencyResponse      LatencyStage = "response"       // committed until response.created
+	LatencyFirstAudio    LatencyStage = "first_audio"    // response.created until the first audio of the server
+	LatencyPlayout       LatencyStage = "playout"        // first audio until the first sample played
+)
+
+var latencyStages = []LatencyStage{LatencyUserPerceived, LatencyCommit, LatencyResponse, LatencyFirstAudio, LatencyPlayout}
+
+// Latency window and playback polling
+const (
+	latencyWindow       = 1000 // samples kept per stage for the percentiles
+	latencyPollInterval = 5 * time.Millisecond
+	latencyPollTimeout  = 10 * time.Second
+)
+
+// LatencyMetrics receives the latency of each stage of a turn, e.g. to export
+// them as histograms.
+type LatencyMetrics interface {
+	ObserveLatency(stage LatencyStage, d time.Duration)
+}
+
+// LatencyTurn holds the timestamps of a response, zero when not seen.
+type LatencyTurn struct {
+	ResponseId      string
+	SpeechStopped   time.Time
+	Committed       time.Time
+	ResponseCreated time.Time
+	FirstAudio      time.Time // first response.output_audio.delta or output_audio_buffer.started
+	FirstPlayed     time.Time // first sample of the response handed to the output device
+}
+
+func latencyBetween(from, to time.Time) (time.Duration, bool) {
+	if from.IsZero() || to.IsZero() {
+		return 0, false
+	}
+	return to.Sub(from), true
+}
+
+// Latency returns the latency of a stage, false when the turn lacks a
+// timestamp, e.g. responses not following user speech have no user perceived
+// latency.
+func (t LatencyTurn) Latency(stage LatencyStage) (time.Duration, bool) {
+	switch stage {
+	case LatencyUserPerceived:
+		from := t.SpeechStopped
+		if from.IsZero() {
+			from = t.Committed
+		}
+		to := t.FirstPlayed
+		if to.IsZero() {
+			to = t.FirstAudio
+		}
+		return latencyBetween(from, to)
+	case LatencyCommit:
+		return latencyBetween(t.SpeechStopped, t.Committed)
+	case LatencyResponse:
+		return latencyBetween(t.Committed, t.ResponseCreated)
+	case LatencyFirstAudio:
+		return latencyBetween(t.ResponseCreated, t.FirstAudio)
+	case LatencyPlayout:
+		return latencyBetween(t.FirstAudio, t.FirstPlayed)
+	}
+	return 0, false
+}
+
+type LatencySummary struct {
+	Count int // all time, the percentiles cover the last samples
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// LatencyTracker times the turns of a conversation from the event stream and
+// the local playback.
+type LatencyTracker struct {
+	playback PlaybackController // optional
+	onTurn   func(turn LatencyTurn)
+	metrics  LatencyMetrics
+
+	mu      sync.Mutex
+	pending LatencyTurn // speech not answered yet
+	turn    *LatencyTurn
+	offset  time.Duration // playback offset where the audio of the turn starts
+	samples map[LatencyStage][]time.Duration
+	counts  map[LatencyStage]int
+	closed  chan struct{}
+	now     func() time.Time
+}
+
+// NewLatencyTracker makes a tracker, playback, onTurn and metrics are
+// optional. onTurn is called once a turn is complete.
+func NewLatencyTracker(playback PlaybackController, onTurn func(turn LatencyTurn), metrics LatencyMetrics) *LatencyTracker {
+	return &LatencyTracker{
+		playback: playback,
+		onTurn:   onTurn,
+		metrics:  metrics,
+		samples:  map[LatencyStage][]time.Duration{},
+		counts:   map[LatencyStage]int{},
+		closed:   make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+func (l *LatencyTracker) PipeEvent(event *ServerEvent) {
+	l.mu.Lock()
+	var done []LatencyTurn
+	now := l.now()
+	switch event.Type {
+	case ServerEventTypeInputAudioBufferSpeechStopped:
+		l.pending = LatencyTurn{SpeechStopped: now}
+	case ServerEventTypeInputAudioBufferCommitted:
+		if l.pending.Committed.IsZero() {
+			l.pending.Committed = now
+		}
+	case ServerEventTypeResponseCreated:
+		// A response still waiting for audio is replaced, e.g. cancelled
+		done = l.finish(done)
+		turn := l.pending
+		l.pending = LatencyTurn{}
+		turn.ResponseId, _ = event.Param.(*ServerEventParamResponseCreated).Response["id"].(string)
+		turn.ResponseCreated = now
+		l.turn = &turn
+		if l.playback != nil {
+			l.offset = l.playback.Written()
+		}
+	case ServerEventTypeResponseOutputAudioDelta, ServerEventTypeOutputAudioBufferStarted:
+		if l.turn == nil || !l.turn.FirstAudio.IsZero() {
+			break
+		}
+		l.turn.FirstAudio = now
+		if l.playback == nil {
+			done = l.finish(done)
+			break
+		}
+		go l.watchPlayback(l.turn, l.offset)
+	case ServerEventTypeResponseDone:
+		// Without audio, e.g. text only, nothing else will happen
+		if l.turn != nil && l.turn.FirstAudio.IsZero() {
+			done = l.finish(done)
+		}
+	}
+	l.mu.Unlock()
+	l.report(done)
+}
+
+// watchPlayback waits for the playback to pass the offset where the audio of
+// the turn starts.
+func (l *LatencyTracker) watchPlayback(turn *LatencyTurn, offset time.Duration) {
+	ticker := time.NewTicker(latencyPollInterval)
+	defer ticker.Stop()
+	timeout := time.After(latencyPollTimeout)
+	for {
+		played := false
+		select {
+		case <-l.closed:
+			return
+		case <-timeout:
+		case <-ticker.C:
+			if played = l.playback.Played() > offset; !played {
+				continue
+			}
+		}
+		var done []LatencyTurn
+		l.mu.Lock()
+		if l.turn == turn {
+			if played {
+				l.turn.FirstPlayed = l.now()
+			}
+			done = l.finish(done)
+		}
+		l.mu.Unlock()
+		l.report(done)
+		return
+	}
+}
+
+// finish records the samples of the current turn and appends it to done, l.mu
+// must be held.
+func (l *LatencyTracker) finish(done []LatencyTurn) []LatencyTurn {
+	if l.turn == nil {
+		return done
+	}
+	turn := *l.turn
+	l.turn = nil
+	for _, stage := range latencyStages {
+		d, ok := turn.Latency(stage)
+		if !ok {
+			continue
+		}
+		samples := append(l.samples[stage], d)
+		if len(samples) > latencyWindow {
+			samples = samples[len(samples)-latencyWindow:]
+		}
+		l.samples[stage] = samples
+		l.counts[stage]++
+	}
+	return append(done, turn)
+}
+
+// report hands the finished turns to the callback and the metrics, without
+// holding l.mu.
+func (l *LatencyTracker) report(done []LatencyTurn) {
+	for _, turn := range done {
+		if l.metrics != nil {
+			for _, stage := range latencyStages {
+				if d, ok := turn.Latency(stage); ok {
+					l.metrics.ObserveLatency(stage, d)
+				}
+			}
+		}
+		if l.onTurn != nil {
+			l.onTurn(turn)
+		}
+	}
+}
+
+// Stats returns the percentiles of each stage seen so far.
+func (l *LatencyTracker) Stats() map[LatencyStage]LatencySummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[LatencyStage]LatencySummary, len(l.samples))
+	for stage, samples := range l.samples {
+		sorted := slices.Clone(samples)
+		slices.Sort(sorted)
+		at := func(p float64) time.Duration {
+			return sorted[min(len(sorted)-1, int(p*float64(len(sorted))))]
+		}
+		stats[stage] = LatencySummary{
+			Count: l.counts[stage],
+			P50:   at(0.5),
+			P90:   at(0.9),
+			P99:   at(0.99),
+			Max:   sorted[len(sorted)-1],
+		}
+	}
+	return stats
+}
+
+// Close stops watching the playback.
+func (l *LatencyTracker) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.closed:
+	default:
+		close(l.closed)
+	}
+}
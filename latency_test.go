@@ -0,0 +1,119 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type atomicPlayback struct {
+	written, played atomic.Int64
+}
+
+func (p *atomicPlayback) Written() time.Duration { return time.Duration(p.written.Load()) }
+func (p *atomicPlayback) Played() time.Duration  { return time.Duration(p.played.Load()) }
+func (p *atomicPlayback) Flush() time.Duration   { return 0 }
+
+type latencyRecorder struct {
+	mu       sync.Mutex
+	observed map[LatencyStage][]time.Duration
+}
+
+func (r *latencyRecorder) ObserveLatency(stage LatencyStage, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observed[stage] = append(r.observed[stage], d)
+}
+
+func TestLatencyTracker(t *testing.T) {
+	event := func(eventType ServerEventType) *ServerEvent {
+		e := &ServerEvent{Type: eventType}
+		if eventType == ServerEventTypeResponseCreated {
+			e.Param = &ServerEventParamResponseCreated{Response: map[string]any{"id": "resp_1"}}
+		}
+		return e
+	}
+
+	t.Run("WithoutPlayback", func(t *testing.T) {
+		clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		var turns []LatencyTurn
+		metrics := &latencyRecorder{observed: map[LatencyStage][]time.Duration{}}
+		l := NewLatencyTracker(nil, func(turn LatencyTurn) { turns = append(turns, turn) }, metrics)
+		l.now = func() time.Time { return clock }
+		defer l.Close()
+
+		l.PipeEvent(event(ServerEventTypeInputAudioBufferSpeechStopped))
+		clock = clock.Add(100 * time.Millisecond)
+		l.PipeEvent(event(ServerEventTypeInputAudioBufferCommitted))
+		clock = clock.Add(50 * time.Millisecond)
+		l.PipeEvent(event(ServerEventTypeResponseCreated))
+		clock = clock.Add(300 * time.Millisecond)
+		l.PipeEvent(event(ServerEventTypeOutputAudioBufferStarted))
+		l.PipeEvent(event(ServerEventTypeResponseOutputAudioDelta))
+
+		if len(turns) != 1 || turns[0].ResponseId != "resp_1" {
+			t.Fatalf("Expected one turn for resp_1, got %+v", turns)
+		}
+		for stage, expected := range map[LatencyStage]time.Duration{
+			LatencyUserPerceived: 450 * time.Millisecond,
+			LatencyCommit:        100 * time.Millisecond,
+			LatencyResponse:      50 * time.Millisecond,
+			LatencyFirstAudio:    300 * time.Millisecond,
+		} {
+			if d, ok := turns[0].Latency(stage); !ok || d != expected {
+				t.Errorf("Expected %s to be %v, got %v", stage, expected, d)
+			}
+			if got := metrics.observed[stage]; len(got) != 1 || got[0] != expected {
+				t.Errorf("Expected %s to be observed once, got %v", stage, got)
+			}
+		}
+		if _, ok := turns[0].Latency(LatencyPlayout); ok {
+			t.Error("Expected no playout latency without playback")
+		}
+
+		// A greeting has no user speech
+		l.PipeEvent(event(ServerEventTypeResponseCreated))
+		l.PipeEvent(event(ServerEventTypeResponseDone))
+		if len(turns) != 2 {
+			t.Fatalf("Expected the text response to finish, got %d turns", len(turns))
+		}
+		if _, ok := turns[1].Latency(LatencyUserPerceived); ok {
+			t.Error("Expected no user perceived latency without speech")
+		}
+		if s := l.Stats()[LatencyUserPerceived]; s.Count != 1 || s.P50 != 450*time.Millisecond || s.Max != 450*time.Millisecond {
+			t.Errorf("Expected the aggregate of the single turn, got %+v", s)
+		}
+	})
+
+	t.Run("WithPlayback", func(t *testing.T) {
+		playback := new(atomicPlayback)
+		playback.written.Store(int64(time.Second))
+		playback.played.Store(int64(time.Second))
+		done := make(chan LatencyTurn, 1)
+		l := NewLatencyTracker(playback, func(turn LatencyTurn) { done <- turn }, nil)
+		defer l.Close()
+
+		l.PipeEvent(event(ServerEventTypeInputAudioBufferSpeechStopped))
+		l.PipeEvent(event(ServerEventTypeResponseCreated))
+		playback.written.Add(int64(500 * time.Millisecond))
+		l.PipeEvent(event(ServerEventTypeOutputAudioBufferStarted))
+		select {
+		case turn := <-done:
+			t.Fatalf("Expected the turn to wait for the playback, got %+v", turn)
+		case <-time.After(30 * time.Millisecond):
+		}
+		playback.played.Add(int64(20 * time.Millisecond))
+		select {
+		case turn := <-done:
+			if turn.FirstPlayed.IsZero() || turn.FirstPlayed.Before(turn.FirstAudio) {
+				t.Errorf("Expected the first played sample after the first audio, got %+v", turn)
+			}
+			if d, ok := turn.Latency(LatencyPlayout); !ok || d < 30*time.Millisecond {
+				t.Errorf("Expected a playout latency of at least 30ms, got %v", d)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the turn to finish once played")
+		}
+	})
+}
@@ -0,0 +1,444 @@
+package realtime
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/realtime"
+	"go.uber.org/zap"
+)
+
+// MCPServer describes a remote MCP server the session is allowed to use.
+type MCPServer struct {
+	Label           string
+	URL             string
+	Description     string
+	Authorization   string
+	Headers         map[string]string
+	AllowedTools    []string
+	RequireApproval string // "always" or "never", empty keeps the server default
+}
+
+func (s *MCPServer) ToolParam() realtime.RealtimeToolsConfigUnionParam {
+	tool := realtime.RealtimeToolsConfigUnionParamOfMcp(s.Label)
+	if s.URL != "" {
+		tool.OfMcp.ServerURL = param.NewOpt(s.URL)
+	}
+	if s.Description != "" {
+		tool.OfMcp.ServerDescription = param.NewOpt(s.Description)
+	}
+	if s.Authorization != "" {
+		tool.OfMcp.Authorization = param.NewOpt(s.Authorization)
+	}
+	if len(s.Headers) > 0 {
+		tool.OfMcp.Headers = s.Headers
+	}
+	if len(s.AllowedTools) > 0 {
+		tool.OfMcp.AllowedTools.OfMcpAllowedTools = s.AllowedTools
+	}
+	if s.RequireApproval != "" {
+		tool.OfMcp.RequireApproval.OfMcpToolApprovalSetting = param.NewOpt(s.RequireApproval)
+	}
+	return tool
+}
+
+// AddMCPServers appends the given servers to the tools of the session config.
+// Server labels must be unique within a session.
+func AddMCPServers(cfg *realtime.RealtimeSessionCreateRequestParam, servers ...MCPServer) error {
+	if cfg == nil {
+		return shared.ErrNoConfig
+	}
+	labels := shared.NewSet[string]()
+	for _, tool := range cfg.Tools {
+		if tool.OfMcp != nil {
+			labels.Add(tool.OfMcp.ServerLabel)
+		}
+	}
+	for _, server := range servers {
+		if server.Label == "" {
+			return errors.New("MCP server label is required")
+		}
+		if server.URL == "" {
+			return fmt.Errorf("MCP server %q: URL is required", server.Label)
+		}
+		switch server.RequireApproval {
+		case "", "always", "never":
+		default:
+			return fmt.Errorf("MCP server %q: invalid require approval setting: %s", server.Label, server.RequireApproval)
+		}
+		if labels.Add(server.Label) {
+			return fmt.Errorf("MCP server %q: duplicate label", server.Label)
+		}
+		cfg.Tools = append(cfg.Tools, server.ToolParam())
+	}
+	return nil
+}
+
+type MCPStatus string
+
+const (
+	MCPStatusInProgress MCPStatus = "in_progress"
+	MCPStatusCompleted  MCPStatus = "completed"
+	MCPStatusFailed     MCPStatus = "failed"
+	MCPStatusIncomplete MCPStatus = "incomplete" // the response ended before the call
+)
+
+type MCPListTools struct {
+	ItemId      string
+	ServerLabel string
+	Status      MCPStatus
+	Tools       []string
+}
+
+type MCPCall struct {
+	ItemId            string
+	ResponseId        string
+	ServerLabel       string
+	Name              string
+	Arguments         string
+	ApprovalRequestId string
+	Status            MCPStatus
+	Output            string
+	Error             any
+}
+
+type MCPApprovalRequest struct {
+	ItemId      string
+	ServerLabel string
+	Name        string
+	Arguments   string
+}
+
+type MCPServerState struct {
+	Label     string
+	ListTools []MCPListTools
+	Calls     []MCPCall
+}
+
+// Approver decides whether a tool call requested by the model may run.
+// Approve may block (e.g. waiting for a user), it is never called on the
+// event dispatch goroutine.
+type Approver interface {
+	Approve(ctx context.Context, req MCPApprovalRequest) (approve bool, reason string, err error)
+}
+
+type ApproverFunc func(ctx context.Context, req MCPApprovalRequest) (bool, string, error)
+
+func (f ApproverFunc) Approve(ctx context.Context, req MCPApprovalRequest) (bool, string, error) {
+	return f(ctx, req)
+}
+
+// Tool names in allow and deny lists are either a bare tool name, matching the
+// tool on every server, or "<server label>/<tool name>".
+type listApprover struct {
+	tools shared.Set[string]
+	allow bool
+	next  Approver
+}
+
+// NewAllowListApprover approves the listed tools and hands everything else to
+// next. With a nil next, unlisted tools are denied.
+func NewAllowListApprover(next Approver, tools ...string) Approver {
+	return &listApprover{tools: shared.NewSet(tools...), allow: true, next: next}
+}
+
+// NewDenyListApprover denies the listed tools and hands everything else to
+// next. With a nil next, unlisted tools are approved.
+func NewDenyListApprover(next Approver, tools ...string) Approver {
+	return &listApprover{tools: shared.NewSet(tools...), allow: false, next: next}
+}
+
+func (a *listApprover) Approve(ctx context.Context, req MCPApprovalRequest) (bool, string, error) {
+	if a.tools.Contains(req.Name) || a.tools.Contains(req.ServerLabel+"/"+req.Name) {
+		if a.allow {
+			return true, "", nil
+		}
+		return false, "tool is in deny list", nil
+	}
+	if a.next != nil {
+		return a.next.Approve(ctx, req)
+	}
+	if a.allow {
+		return false, "tool is not in allow list", nil
+	}
+	return true, "", nil
+}
+
+// MCPManager tracks list-tools and call progress of the session's MCP servers
+// and answers approval requests through an Approver.
+type MCPManager struct {
+	ctx      context.Context
+	logger   shared.LoggerAdapter
+	client   *Client
+	approver Approver
+
+	mu        sync.Mutex
+	listTools map[string]*MCPListTools // by item id
+	calls     map[string]*MCPCall      // by item id
+	approvals shared.Set[string]       // handled approval request item ids
+}
+
+func NewMCPManager(ctx context.Context, logger shared.LoggerAdapter, client *Client, approver Approver) (*MCPManager, error) {
+	if logger == nil {
+		return nil, shared.ErrNoLogger
+	}
+	if client == nil {
+		return nil, shared.ErrClientNotInitialized
+	}
+	if approver == nil {
+		return nil, shared.ErrNoApprover
+	}
+	return &MCPManager{
+		ctx:       ctx,
+		logger:    logger,
+		client:    client,
+		approver:  approver,
+		listTools: make(map[string]*MCPListTools),
+		calls:     make(map[string]*MCPCall),
+		approvals: shared.NewSet[string](),
+	}, nil
+}
+
+func (m *MCPManager) PipeEvent(event *ServerEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch event.Type {
+	case ServerEventTypeConversationItemAdded:
+		m.pipeItem(event.Param.(*ServerEventParamConversationItemAdded).Item, "")
+	case ServerEventTypeConversationItemDone:
+		m.pipeItem(event.Param.(*ServerEventParamConversationItemDone).Item, "")
+	case ServerEventTypeResponseOutputItemAdded:
+		p := event.Param.(*ServerEventParamResponseOutputItemAdded)
+		m.pipeItem(p.Item, p.ResponseId)
+	case ServerEventTypeResponseOutputItemDone:
+		p := event.Param.(*ServerEventParamResponseOutputItemDone)
+		m.pipeItem(p.Item, p.ResponseId)
+	case ServerEventTypeResponseDone:
+		m.pipeResponseDone(event.Param.(*ServerEventParamResponseDone).Response)
+	case ServerEventTypeMCPListToolsInProgress:
+		m.listToolsOf(event.Param.(*ServerEventParamMCPListToolsInProgress).ItemId).Status = MCPStatusInProgress
+	case ServerEventTypeMCPListToolsCompleted:
+		m.listToolsOf(event.Param.(*ServerEventParamMCPListToolsCompleted).ItemId).Status = MCPStatusCompleted
+	case ServerEventTypeMCPListToolsFailed:
+		m.listToolsOf(event.Param.(*ServerEventParamMCPListToolsFailed).ItemId).Status = MCPStatusFailed
+	case ServerEventTypeResponseMCPCallArgumentsDelta:
+		p := event.Param.(*ServerEventParamResponseMCPCallArgumentsDelta)
+		m.callOf(p.ItemId).Arguments += p.Delta
+	case ServerEventTypeResponseMCPCallArgumentsDone:
+		p := event.Param.(*ServerEventParamResponseMCPCallArgumentsDone)
+		m.callOf(p.ItemId).Arguments = p.Arguments
+	case ServerEventTypeResponseMCPCallInProgress:
+		m.callOf(event.Param.(*ServerEventParamResponseMCPCallInProgress).ItemId).Status = MCPStatusInProgress
+	case ServerEventTypeResponseMCPCallCompleted:
+		m.callOf(event.Param.(*ServerEventParamResponseMCPCallCompleted).ItemId).Status = MCPStatusCompleted
+	case ServerEventTypeResponseMCPCallFailed:
+		m.callOf(event.Param.(*ServerEventParamResponseMCPCallFailed).ItemId).Status = MCPStatusFailed
+	}
+}
+
+// pipeResponseDone marks the calls of the response still in progress as
+// incomplete, the server will not report them anymore.
+func (m *MCPManager) pipeResponseDone(response map[string]any) {
+	id, _ := response["id"].(string)
+	if output, ok := response["output"].([]any); ok {
+		for _, o := range output {
+			if item, ok := o.(map[string]any); ok {
+				m.pipeItem(item, id)
+			}
+		}
+	}
+	if id == "" {
+		return
+	}
+	for _, call := range m.calls {
+		if call.ResponseId == id && call.Status == MCPStatusInProgress {
+			call.Status = MCPStatusIncomplete
+		}
+	}
+}
+
+// pipeItem updates the state from an item, responseId is empty for the items
+// of the conversation.
+func (m *MCPManager) pipeItem(item map[string]any, responseId string) {
+	id, _ := item["id"].(string)
+	if id == "" {
+		return
+	}
+	label, _ := item["server_label"].(string)
+	status, _ := item["status"].(string)
+	switch item["type"] {
+	case "mcp_list_tools":
+		lt := m.listToolsOf(id)
+		if label != "" {
+			lt.ServerLabel = label
+		}
+		if status != "" {
+			lt.Status = MCPStatus(status)
+		}
+		if tools, ok := item["tools"].([]any); ok {
+			lt.Tools = lt.Tools[:0]
+			for _, tool := range tools {
+				if t, ok := tool.(map[string]any); ok {
+					if name, ok := t["name"].(string); ok {
+						lt.Tools = append(lt.Tools, name)
+					}
+				}
+			}
+		}
+	case "mcp_call":
+		call := m.callOf(id)
+		if responseId != "" {
+			call.ResponseId = responseId
+		}
+		if label != "" {
+			call.ServerLabel = label
+		}
+		if status != "" {
+			call.Status = MCPStatus(status)
+		}
+		if v, ok := item["name"].(string); ok {
+			call.Name = v
+		}
+		if v, ok := item["arguments"].(string); ok && v != "" {
+			call.Arguments = v
+		}
+		if v, ok := item["approval_request_id"].(string); ok {
+			call.ApprovalRequestId = v
+		}
+		if v, ok := item["output"].(string); ok {
+			call.Output = v
+		}
+		if v, ok := item["error"]; ok && v != nil {
+			call.Error = v
+		}
+	case "mcp_approval_request":
+		if m.approvals.Add(id) {
+			return
+		}
+		req := MCPApprovalRequest{ItemId: id, ServerLabel: label}
+		req.Name, _ = item["name"].(string)
+		req.Arguments, _ = item["arguments"].(string)
+		m.logger.Info(
+			"MCP approval requested",
+			zap.String("item_id", req.ItemId),
+			zap.String("server_label", req.ServerLabel),
+			zap.String("name", req.Name),
+		)
+		go m.approve(req)
+	}
+}
+
+func (m *MCPManager) listToolsOf(itemId string) *MCPListTools {
+	lt, ok := m.listTools[itemId]
+	if !ok {
+		lt = &MCPListTools{ItemId: itemId, Status: MCPStatusInProgress}
+		m.listTools[itemId] = lt
+	}
+	return lt
+}
+
+func (m *MCPManager) callOf(itemId string) *MCPCall {
+	call, ok := m.calls[itemId]
+	if !ok {
+		call = &MCPCall{ItemId: itemId, Status: MCPStatusInProgress}
+		m.calls[itemId] = call
+	}
+	return call
+}
+
+func (m *MCPManager) approve(req MCPApprovalRequest) {
+	approve, reason, err := m.approver.Approve(m.ctx, req)
+	if err != nil {
+		m.logger.Error("approving MCP tool call", err, zap.String("item_id", req.ItemId))
+		approve, reason = false, "approval failed"
+	}
+	if err := m.Respond(req.ItemId, approve, reason); err != nil {
+		m.logger.Error("responding to MCP approval request", err, zap.String("item_id", req.ItemId))
+	}
+}
+
+// Respond sends an mcp_approval_response item for the given approval request.
+func (m *MCPManager) Respond(approvalRequestId string, approve bool, reason string) error {
+	item := map[string]any{
+		"type":                "mcp_approval_response",
+		"approval_request_id": approvalRequestId,
+		"approve":             approve,
+	}
+	if reason != "" {
+		item["reason"] = reason
+	}
+	err := m.client.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeConversationItemCreate,
+		Param: &ClientEventParamConversationItemCreate{Item: item},
+	})
+	if err != nil {
+		return fmt.Errorf("sending approval response: %w", err)
+	}
+	m.logger.Info(
+		"MCP approval response sent",
+		zap.String("approval_request_id", approvalRequestId),
+		zap.Bool("approve", approve),
+	)
+	return nil
+}
+
+func (m *MCPManager) ListTools(itemId string) (MCPListTools, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lt, ok := m.listTools[itemId]
+	if !ok {
+		return MCPListTools{}, false
+	}
+	resp := *lt
+	resp.Tools = slices.Clone(lt.Tools)
+	return resp, true
+}
+
+func (m *MCPManager) Call(itemId string) (MCPCall, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	call, ok := m.calls[itemId]
+	if !ok {
+		return MCPCall{}, false
+	}
+	return *call, true
+}
+
+// Servers returns a snapshot of the tracked state grouped by server label.
+func (m *MCPManager) Servers() []MCPServerState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byLabel := map[string]*MCPServerState{}
+	stateOf := func(label string) *MCPServerState {
+		s, ok := byLabel[label]
+		if !ok {
+			s = &MCPServerState{Label: label}
+			byLabel[label] = s
+		}
+		return s
+	}
+	for _, lt := range m.listTools {
+		s := stateOf(lt.ServerLabel)
+		cp := *lt
+		cp.Tools = slices.Clone(lt.Tools)
+		s.ListTools = append(s.ListTools, cp)
+	}
+	for _, call := range m.calls {
+		s := stateOf(call.ServerLabel)
+		s.Calls = append(s.Calls, *call)
+	}
+	resp := make([]MCPServerState, 0, len(byLabel))
+	for _, s := range byLabel {
+		slices.SortFunc(s.ListTools, func(a, b MCPListTools) int { return cmp.Compare(a.ItemId, b.ItemId) })
+		slices.SortFunc(s.Calls, func(a, b MCPCall) int { return cmp.Compare(a.ItemId, b.ItemId) })
+		resp = append(resp, *s)
+	}
+	slices.SortFunc(resp, func(a, b MCPServerState) int { return cmp.Compare(a.Label, b.Label) })
+	return resp
+}
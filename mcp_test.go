@@ -0,0 +1,279 @@
+package realtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/openai/openai-go/v3/realtime"
+)
+
+func TestAddMCPServers(t *testing.T) {
+	t.Run("AppendsTools", func(t *testing.T) {
+		cfg := &realtime.RealtimeSessionCreateRequestParam{}
+		err := pkg.AddMCPServers(cfg,
+			pkg.MCPServer{Label: "docs", URL: "https://example.com/mcp", RequireApproval: "always"},
+			pkg.MCPServer{Label: "crm", URL: "https://example.org/mcp"},
+		)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(cfg.Tools) != 2 {
+			t.Fatalf("Expected 2 tools, got %d", len(cfg.Tools))
+		}
+		if cfg.Tools[0].OfMcp == nil || cfg.Tools[0].OfMcp.ServerLabel != "docs" {
+			t.Errorf("Expected first tool to be the docs MCP server, got %+v", cfg.Tools[0])
+		}
+	})
+
+	t.Run("DuplicateLabel", func(t *testing.T) {
+		cfg := &realtime.RealtimeSessionCreateRequestParam{}
+		err := pkg.AddMCPServers(cfg,
+			pkg.MCPServer{Label: "docs", URL: "https://example.com/mcp"},
+			pkg.MCPServer{Label: "docs", URL: "https://example.org/mcp"},
+		)
+		if err == nil {
+			t.Error("Expected an error for duplicate labels")
+		}
+	})
+
+	t.Run("InvalidApprovalSetting", func(t *testing.T) {
+		cfg := &realtime.RealtimeSessionCreateRequestParam{}
+		err := pkg.AddMCPServers(cfg, pkg.MCPServer{Label: "docs", URL: "https://example.com/mcp", RequireApproval: "sometimes"})
+		if err == nil {
+			t.Error("Expected an error for an invalid approval setting")
+		}
+	})
+}
+
+func TestListApprovers(t *testing.T) {
+	ctx := context.Background()
+	approver := pkg.NewDenyListApprover(pkg.NewAllowListApprover(nil, "search", "crm/lookup"), "docs/delete")
+
+	cases := []struct {
+		req  pkg.MCPApprovalRequest
+		want bool
+	}{
+		{pkg.MCPApprovalRequest{ServerLabel: "docs", Name: "search"}, true},
+		{pkg.MCPApprovalRequest{ServerLabel: "crm", Name: "lookup"}, true},
+		{pkg.MCPApprovalRequest{ServerLabel: "docs", Name: "lookup"}, false},
+		{pkg.MCPApprovalRequest{ServerLabel: "docs", Name: "delete"}, false},
+		{pkg.MCPApprovalRequest{ServerLabel: "crm", Name: "update"}, false},
+	}
+	for _, c := range cases {
+		got, _, err := approver.Approve(ctx, c.req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got != c.want {
+			t.Errorf("Expected Approve(%s/%s) to return %v, got %v", c.req.ServerLabel, c.req.Name, c.want, got)
+		}
+	}
+
+	if got, _, _ := pkg.NewDenyListApprover(nil, "delete").Approve(ctx, pkg.MCPApprovalRequest{Name: "search"}); !got {
+		t.Error("Expected deny list without next approver to approve unlisted tools")
+	}
+}
+
+func TestMCPManagerPipeEvent(t *testing.T) {
+	c, err := pkg.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	m, err := pkg.NewMCPManager(context.Background(), shared.NewStdLogger(), c, pkg.NewAllowListApprover(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	events := []*pkg.ServerEvent{
+		{Type: pkg.ServerEventTypeMCPListToolsInProgress, Param: &pkg.ServerEventParamMCPListToolsInProgress{ItemId: "lt_1"}},
+		{Type: pkg.ServerEventTypeConversationItemDone, Param: &pkg.ServerEventParamConversationItemDone{Item: map[string]any{
+			"id":           "lt_1",
+			"type":         "mcp_list_tools",
+			"server_label": "docs",
+			"tools":        []any{map[string]any{"name": "search"}, map[string]any{"name": "fetch"}},
+		}}},
+		{Type: pkg.ServerEventTypeMCPListToolsCompleted, Param: &pkg.ServerEventParamMCPListToolsCompleted{ItemId: "lt_1"}},
+		{Type: pkg.ServerEventTypeResponseOutputItemAdded, Param: &pkg.ServerEventParamResponseOutputItemAdded{Item: map[string]any{
+			"id":           "mc_1",
+			"type":         "mcp_call",
+			"server_label": "docs",
+			"name":         "search",
+		}}},
+		{Type: pkg.ServerEventTypeResponseMCPCallArgumentsDelta, Param: &pkg.ServerEventParamResponseMCPCallArgumentsDelta{ItemId: "mc_1", Delta: `{"q":`}},
+		{Type: pkg.ServerEventTypeResponseMCPCallArgumentsDelta, Param: &pkg.ServerEventParamResponseMCPCallArgumentsDelta{ItemId: "mc_1", Delta: `"go"}`}},
+		{Type: pkg.ServerEventTypeResponseMCPCallFailed, Param: &pkg.ServerEventParamResponseMCPCallFailed{ItemId: "mc_1"}},
+	}
+	for _, event := range events {
+		m.PipeEvent(event)
+	}
+
+	lt, ok := m.ListTools("lt_1")
+	if !ok {
+		t.Fatal("Expected list tools lt_1 to be tracked")
+	}
+	if lt.Status != pkg.MCPStatusCompleted || lt.ServerLabel != "docs" || len(lt.Tools) != 2 {
+		t.Errorf("Unexpected list tools state %+v", lt)
+	}
+	call, ok := m.Call("mc_1")
+	if !ok {
+		t.Fatal("Expected call mc_1 to be tracked")
+	}
+	if call.Status != pkg.MCPStatusFailed || call.Arguments != `{"q":"go"}` {
+		t.Errorf("Unexpected call state %+v", call)
+	}
+	servers := m.Servers()
+	if len(servers) != 1 || servers[0].Label != "docs" || len(servers[0].Calls) != 1 {
+		t.Errorf("Unexpected servers snapshot %+v", servers)
+	}
+}
+
+func TestMCPManagerResponseDone(t *testing.T) {
+	c, err := pkg.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	m, err := pkg.NewMCPManager(context.Background(), shared.NewStdLogger(), c, pkg.NewAllowListApprover(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	call := func(id string) map[string]any {
+		return map[string]any{"id": id, "type": "mcp_call", "server_label": "docs", "name": "search"}
+	}
+	events := []*pkg.ServerEvent{
+		{Type: pkg.ServerEventTypeResponseOutputItemAdded, Param: &pkg.ServerEventParamResponseOutputItemAdded{ResponseId: "resp_1", Item: call("mc_1")}},
+		{Type: pkg.ServerEventTypeResponseOutputItemAdded, Param: &pkg.ServerEventParamResponseOutputItemAdded{ResponseId: "resp_1", Item: call("mc_2")}},
+		{Type: pkg.ServerEventTypeResponseOutputItemAdded, Param: &pkg.ServerEventParamResponseOutputItemAdded{ResponseId: "resp_2", Item: call("mc_3")}},
+		{Type: pkg.ServerEventTypeResponseMCPCallCompleted, Param: &pkg.ServerEventParamResponseMCPCallCompleted{ItemId: "mc_1"}},
+		{Type: pkg.ServerEventTypeResponseDone, Param: &pkg.ServerEventParamResponseDone{Response: map[string]any{"id": "resp_1", "status": "cancelled"}}},
+	}
+	for _, event := range events {
+		m.PipeEvent(event)
+	}
+
+	expected := map[string]pkg.MCPStatus{
+		"mc_1": pkg.MCPStatusCompleted,
+		"mc_2": pkg.MCPStatusIncomplete,
+		"mc_3": pkg.MCPStatusInProgress, // another response
+	}
+	for id, status := range expected {
+		call, ok := m.Call(id)
+		if !ok {
+			t.Fatalf("Expected call %s to be tracked", id)
+		}
+		if call.Status != status {
+			t.Errorf("Expected call %s to be %s, got %s", id, status, call.Status)
+		}
+	}
+}
+
+func TestMCPManagerApproval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	server := realtimetest.NewServer(realtimetest.Options{})
+	defer server.Close()
+	var m *pkg.MCPManager
+	approver := pkg.ApproverFunc(func(ctx context.Context, req pkg.MCPApprovalRequest) (bool, string, error) {
+		return req.ServerLabel == "docs" && req.Name == "search", "trusted tool", nil
+	})
+	_, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+		Events: func(event *pkg.ServerEvent) { m.PipeEvent(event) },
+		Setup: func(c *pkg.Client) (err error) {
+			m, err = pkg.NewMCPManager(ctx, shared.NewStdLogger(), c, approver)
+			return err
+		},
+	})
+	select {
+	case <-call.Opened():
+	case <-ctx.Done():
+		t.Fatal("Expected the data channel to open")
+	}
+
+	// responses waits for n mcp_approval_response items
+	responses := func(n int) []map[string]any {
+		t.Helper()
+		for {
+			var items []map[string]any
+			for _, msg := range call.Messages() {
+				if msg.Type != pkg.ClientEventTypeConversationItemCreate {
+					continue
+				}
+				var event struct {
+					Item map[string]any `json:"item"`
+				}
+				if err := json.Unmarshal(msg.Raw, &event); err != nil {
+					t.Fatalf("Expected a JSON event, got %v", err)
+				}
+				if event.Item["type"] == "mcp_approval_response" {
+					items = append(items, event.Item)
+				}
+			}
+			if len(items) >= n {
+				return items
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Expected %d approval responses, got %v", n, items)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	request := &pkg.ServerEvent{Type: pkg.ServerEventTypeResponseOutputItemDone, Param: &pkg.ServerEventParamResponseOutputItemDone{Item: map[string]any{
+		"id":           "mcpr_1",
+		"type":         "mcp_approval_request",
+		"server_label": "docs",
+		"name":         "search",
+		"arguments":    `{"q":"go"}`,
+	}}}
+	// The request is also announced by conversation.item.done, it is answered once
+	done := &pkg.ServerEvent{Type: pkg.ServerEventTypeConversationItemDone, Param: &pkg.ServerEventParamConversationItemDone{Item: request.Param.(*pkg.ServerEventParamResponseOutputItemDone).Item}}
+	if err := call.Send(request, done); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	item := responses(1)[0]
+	if item["approval_request_id"] != "mcpr_1" || item["approve"] != true || item["reason"] != "trusted tool" {
+		t.Errorf("Expected the request to be approved, got %v", item)
+	}
+
+	if err := m.Respond("mcpr_2", false, "not now"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	items := responses(2)
+	if len(items) != 2 {
+		t.Fatalf("Expected the request to be answered once, got %v", items)
+	}
+	if item := items[1]; item["approval_request_id"] != "mcpr_2" || item["approve"] != false || item["reason"] != "not now" {
+		t.Errorf("Expected the request to be denied, got %v", item)
+	}
+
+	if err := call.Send(&pkg.ServerEvent{Type: pkg.ServerEventTypeConversationItemDone, Param: &pkg.ServerEventParamConversationItemDone{Item: map[string]any{
+		"id":                  "mc_1",
+		"type":                "mcp_call",
+		"server_label":        "docs",
+		"name":                "search",
+		"approval_request_id": "mcpr_1",
+		"status":              "completed",
+		"output":              "found",
+	}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for {
+		if mc, ok := m.Call("mc_1"); ok {
+			if mc.Status != pkg.MCPStatusCompleted || mc.Output != "found" || mc.ApprovalRequestId != "mcpr_1" {
+				t.Errorf("Expected the status of the item, got %+v", mc)
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("Expected the call to be tracked")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/pion/webrtc/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	descPacketsReceived = prometheus.NewDesc(namespace+"_rtp_packets_received_total", "RTP packets received.", nil, nil)
+	descPacketsLost     = prometheus.NewDesc(namespace+"_rtp_packets_lost_total", "RTP packets lost, as reported by the receiver.", nil, nil)
+	descJitter          = prometheus.NewDesc(namespace+"_rtp_jitter_seconds", "Highest interarrival jitter of the received streams.", nil, nil)
+	descDroppedBytes    = prometheus.NewDesc(namespace+"_audio_dropped_bytes_total", "Bytes of audio dropped by the playback buffer.", nil, nil)
+	descUnderruns       = prometheus.NewDesc(namespace+"_audio_underruns_total", "Reads of the playback buffer that found it empty.", nil, nil)
+	descDecodeErrors    = prometheus.NewDesc(namespace+"_audio_decode_errors_total", "Remote audio packets that could not be decoded.", nil, nil)
+)
+
+type rtpTotals struct {
+	received uint64
+	lost     uint64
+	jitter   float64 // not cumulative
+}
+
+func (t *rtpTotals) add(o rtpTotals) {
+	t.received += o.received
+	t.lost += o.lost
+}
+
+func addAudio(t *realtime.AudioStats, o realtime.AudioStats) {
+	t.DroppedBytes += o.DroppedBytes
+	t.Underruns += o.Underruns
+	t.DecodeErrors += o.DecodeErrors
+}
+
+type sourceTotals struct {
+	rtpTotals
+	audio realtime.AudioStats
+}
+
+// clientSource keeps the last statistics of a client, so its counters do not
+// go back when the peer connection is closed and its statistics are gone.
+type clientSource struct {
+	client *realtime.Client
+	last   rtpTotals
+}
+
+func (s *clientSource) update() {
+	var t rtpTotals
+	for _, stats := range s.client.Stats() {
+		if stats, ok := stats.(webrtc.InboundRTPStreamStats); ok {
+			t.received += uint64(stats.PacketsReceived)
+			t.lost += uint64(max(stats.PacketsLost, 0))
+			t.jitter = max(t.jitter, stats.Jitter)
+		}
+	}
+	s.last = rtpTotals{
+		received: max(s.last.received, t.received),
+		lost:     max(s.last.lost, t.lost),
+		jitter:   t.jitter,
+	}
+}
+
+type audioSource struct {
+	stats func() realtime.AudioStats
+	last  realtime.AudioStats
+}
+
+func (s *audioSource) update() {
+	s.last = s.stats()
+}
+
+// statsCollector reads the statistics of the tracked sources on each scrape.
+type statsCollector Metrics
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		descPacketsReceived, descPacketsLost, descJitter,
+		descDroppedBytes, descUnderruns, descDecodeErrors,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	totals := c.closed
+	for source := range c.clients {
+		source.update()
+		totals.rtpTotals.add(source.last)
+		totals.jitter = max(totals.jitter, source.last.jitter)
+	}
+	for source := range c.audio {
+		source.update()
+		addAudio(&totals.audio, source.last)
+	}
+	c.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(descPacketsReceived, prometheus.CounterValue, float64(totals.received))
+	ch <- prometheus.MustNewConstMetric(descPacketsLost, prometheus.CounterValue, float64(totals.lost))
+	ch <- prometheus.MustNewConstMetric(descJitter, prometheus.GaugeValue, totals.jitter)
+	ch <- prometheus.MustNewConstMetric(descDroppedBytes, prometheus.CounterValue, float64(totals.audio.DroppedBytes))
+	ch <- prometheus.MustNewConstMetric(descUnderruns, prometheus.CounterValue, float64(totals.audio.Underruns))
+	ch <- prometheus.MustNewConstMetric(descDecodeErrors, prometheus.CounterValue, float64(totals.audio.DecodeErrors))
+}
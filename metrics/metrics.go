@@ -0,0 +1,239 @@
+// Package metrics exposes Prometheus metrics of clients and agents. Events go
+// through PipeEvent like the other components, the WebRTC and audio
+// statistics are read on each scrape.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/pion/webrtc/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "realtime"
+
+// Stages where a session can fail
+const (
+	StageStart      = "start"      // the call could not be created
+	StageConnection = "connection" // the peer connection failed
+)
+
+// Metrics is shared by the clients and agents of a process, e.g. one per
+// registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	sessionsCreated  prometheus.Counter
+	sessionsFailed   *prometheus.CounterVec
+	sessionsActive   prometheus.Gauge
+	stateTransitions *prometheus.CounterVec
+	events           *prometheus.CounterVec
+	errorEvents      *prometheus.CounterVec
+	tokens           *prometheus.CounterVec
+	cachedTokens     prometheus.Counter
+	latency          *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	clients map[*clientSource]struct{}
+	audio   map[*audioSource]struct{}
+	closed  sourceTotals // of the sources not tracked anymore
+}
+
+var _ realtime.LatencyMetrics = (*Metrics)(nil)
+
+// New registers the metrics in registry, a new one if nil.
+func New(registry *prometheus.Registry) (*Metrics, error) {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	m := &Metrics{
+		registry: registry,
+		sessionsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_created_total",
+			Help:      "Sessions whose call was created.",
+		}),
+		sessionsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_failed_total",
+			Help:      "Sessions that failed, by stage.",
+		}, []string{"stage"}),
+		sessionsActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sessions_active",
+			Help:      "Sessions whose peer connection is connected.",
+		}),
+		stateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connection_state_transitions_total",
+			Help:      "Peer connection state changes, by new state.",
+		}, []string{"state"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_received_total",
+			Help:      "Server events received, by type.",
+		}, []string{"type"}),
+		errorEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "error_events_total",
+			Help:      "Error events received, by error type and code.",
+		}, []string{"type", "code"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_total",
+			Help:      "Tokens used by the responses, by direction and modality.",
+		}, []string{"direction", "modality"}),
+		cachedTokens: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cached_tokens_total",
+			Help:      "Input tokens served from the cache, included in tokens_total.",
+		}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "turn_latency_seconds",
+			Help:      "Latency of the turns, by stage.",
+			Buckets:   []float64{.05, .1, .2, .3, .5, .75, 1, 1.5, 2, 3, 5},
+		}, []string{"stage"}),
+		clients: map[*clientSource]struct{}{},
+		audio:   map[*audioSource]struct{}{},
+	}
+	for _, c := range []prometheus.Collector{
+		m.sessionsCreated,
+		m.sessionsFailed,
+		m.sessionsActive,
+		m.stateTransitions,
+		m.events,
+		m.errorEvents,
+		m.tokens,
+		m.cachedTokens,
+		m.latency,
+		(*statsCollector)(m),
+	} {
+		if err := registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Handler serves the metrics, in the OpenMetrics format when asked.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Track counts the session and the state changes of a client, and reads its
+// RTP statistics until it is done. It must be called before Start. A session
+// is created once its peer connection starts connecting, a client done before
+// that failed to start.
+func (m *Metrics) Track(client *realtime.Client) error {
+	var started, connected atomic.Bool
+	err := client.RegisterStateHandler(func(state webrtc.PeerConnectionState) {
+		m.stateTransitions.WithLabelValues(state.String()).Inc()
+		switch state {
+		case webrtc.PeerConnectionStateConnecting:
+			// The call is created before the ICE checks start
+			if started.CompareAndSwap(false, true) {
+				m.sessionsCreated.Inc()
+			}
+		case webrtc.PeerConnectionStateConnected:
+			if connected.CompareAndSwap(false, true) {
+				m.sessionsActive.Inc()
+			}
+		case webrtc.PeerConnectionStateFailed:
+			m.sessionsFailed.WithLabelValues(StageConnection).Inc()
+		}
+		if state >= webrtc.PeerConnectionStateDisconnected && connected.CompareAndSwap(true, false) {
+			m.sessionsActive.Dec()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	source := &clientSource{client: client}
+	m.mu.Lock()
+	m.clients[source] = struct{}{}
+	m.mu.Unlock()
+	go func() {
+		<-client.Done()
+		if !started.Load() && client.CallID() == "" {
+			m.sessionsFailed.WithLabelValues(StageStart).Inc()
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// The client keeps the last statistics once closed
+		source.update()
+		delete(m.clients, source)
+		m.closed.add(source.last)
+	}()
+	return nil
+}
+
+// TrackAudio reads the audio statistics of a session on each scrape, until
+// untrack is called.
+func (m *Metrics) TrackAudio(stats func() realtime.AudioStats) (untrack func()) {
+	source := &audioSource{stats: stats}
+	m.mu.Lock()
+	m.audio[source] = struct{}{}
+	m.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			source.update()
+			delete(m.audio, source)
+			addAudio(&m.closed.audio, source.last)
+		})
+	}
+}
+
+func (m *Metrics) PipeEvent(event *realtime.ServerEvent) {
+	m.events.WithLabelValues(string(event.Type)).Inc()
+	switch p := event.Param.(type) {
+	case *realtime.ServerEventParamError:
+		m.errorEvents.WithLabelValues(p.Type, p.Code).Inc()
+	case *realtime.ServerEventParamResponseDone:
+		m.countTokens(p.Response)
+	}
+}
+
+// countTokens counts the usage of a response.done event.
+func (m *Metrics) countTokens(response map[string]any) {
+	usage, _ := response["usage"].(map[string]any)
+	for _, direction := range []string{"input", "output"} {
+		details, _ := usage[direction+"_token_details"].(map[string]any)
+		for _, modality := range []string{"text", "audio", "image"} {
+			if n := number(details[modality+"_tokens"]); n > 0 {
+				m.tokens.WithLabelValues(direction, modality).Add(n)
+			}
+		}
+	}
+	details, _ := usage["input_token_details"].(map[string]any)
+	if n := number(details["cached_tokens"]); n > 0 {
+		m.cachedTokens.Add(n)
+	}
+}
+
+func number(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	case interface{ Float64() (float64, error) }:
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
+
+func (m *Metrics) ObserveLatency(stage realtime.LatencyStage, d time.Duration) {
+	m.latency.WithLabelValues(string(stage)).Observe(d.Seconds())
+}
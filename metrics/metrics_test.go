@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	openai "github.com/openai/openai-go/v3/realtime"
+	"github.com/pion/webrtc/v4"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("Events", func(t *testing.T) {
+		m, err := New(nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		m.PipeEvent(&realtime.ServerEvent{
+			Type:  realtime.ServerEventTypeError,
+			Param: &realtime.ServerEventParamError{Type: "invalid_request_error", Code: "invalid_value"},
+		})
+		m.PipeEvent(&realtime.ServerEvent{
+			Type: realtime.ServerEventTypeResponseDone,
+			Param: &realtime.ServerEventParamResponseDone{Response: map[string]any{
+				"usage": map[string]any{
+					"input_token_details":  map[string]any{"text_tokens": float64(10), "audio_tokens": float64(20), "cached_tokens": float64(8)},
+					"output_token_details": map[string]any{"audio_tokens": float64(30)},
+				},
+			}},
+		})
+		m.ObserveLatency(realtime.LatencyUserPerceived, 400*time.Millisecond)
+		untrack := m.TrackAudio(func() realtime.AudioStats {
+			return realtime.AudioStats{DroppedBytes: 960, Underruns: 2, DecodeErrors: 1}
+		})
+		untrack()
+		untrack()
+
+		body := scrape(t, m)
+		for _, expected := range []string{
+			`realtime_events_received_total{type="error"} 1`,
+			`realtime_events_received_total{type="response.done"} 1`,
+			`realtime_error_events_total{code="invalid_value",type="invalid_request_error"} 1`,
+			`realtime_tokens_total{direction="input",modality="text"} 10`,
+			`realtime_tokens_total{direction="input",modality="audio"} 20`,
+			`realtime_tokens_total{direction="output",modality="audio"} 30`,
+			`realtime_cached_tokens_total 8`,
+			`realtime_turn_latency_seconds_bucket{stage="user_perceived",le="0.5"} 1`,
+			`realtime_audio_dropped_bytes_total 960`,
+			`realtime_audio_underruns_total 2`,
+			`realtime_audio_decode_errors_total 1`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected %q in the metrics, got\n%s", expected, body)
+			}
+		}
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		server := realtimetest.NewServer(realtimetest.Options{Audio: realtimetest.AudioGenerate})
+		defer server.Close()
+		m, err := New(nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		c, _ := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+			Events: m.PipeEvent,
+			Setup:  m.Track,
+		})
+		time.Sleep(200 * time.Millisecond)
+
+		body := scrape(t, m)
+		for _, expected := range []string{
+			`realtime_sessions_created_total 1`,
+			`realtime_sessions_active 1`,
+			`realtime_connection_state_transitions_total{state="connected"} 1`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected %q in the metrics, got\n%s", expected, body)
+			}
+		}
+		if strings.Contains(body, `realtime_sessions_failed_total{`) {
+			t.Errorf("Expected no failed session, got\n%s", body)
+		}
+		scraped := value(t, body, "realtime_rtp_packets_received_total")
+		if scraped == 0 {
+			t.Errorf("Expected RTP packets to be received, got\n%s", body)
+		}
+
+		// The packets received since the last scrape are counted too
+		time.Sleep(200 * time.Millisecond)
+		if err := c.Close(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var received float64
+		for _, stats := range c.Stats() {
+			if stats, ok := stats.(webrtc.InboundRTPStreamStats); ok {
+				received += float64(stats.PacketsReceived)
+			}
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			body = scrape(t, m)
+			if strings.Contains(body, "realtime_sessions_active 0") && value(t, body, "realtime_rtp_packets_received_total") == received {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected no active session and %v RTP packets after closing, got\n%s", received, body)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if received <= scraped {
+			t.Errorf("Expected more than the %v packets of the last scrape, got %v", scraped, received)
+		}
+	})
+
+	t.Run("FailedStart", func(t *testing.T) {
+		m, err := New(nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// Nothing listens on the server
+		server := realtimetest.NewServer(realtimetest.Options{})
+		url := server.URL
+		server.Close()
+		c, err := realtime.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", url)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer func() { _ = c.Close() }()
+		if err := c.SetConfig(&openai.RealtimeSessionCreateRequestParam{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.RegisterEventHandler(m.PipeEvent); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := m.Track(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.Start(); err == nil {
+			t.Fatal("Expected the call to fail")
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(scrape(t, m), `realtime_sessions_failed_total{stage="start"} 1`) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected a session failed at start, got\n%s", scrape(t, m))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if body := scrape(t, m); !strings.Contains(body, "realtime_sessions_created_total 0") {
+			t.Errorf("Expected no session created, got\n%s", body)
+		}
+	})
+}
+
+// value returns the value of an unlabeled metric.
+func value(t *testing.T, body, name string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if v, ok := strings.CutPrefix(line, name+" "); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatalf("Expected a number for %s, got %q", name, v)
+			}
+			return f
+		}
+	}
+	t.Fatalf("Expected %s in the metrics, got\n%s", name, body)
+	return 0
+}
@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"fmt"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+// SetPushToTalk enables push-to-talk mode: turn detection is disabled for the
+// session and turns are driven by BeginTurn and EndTurn. The session config
+// must not configure a turn detection.
+func (c *Client) SetPushToTalk(enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return shared.ErrSessionAlreadyRunning
+	}
+	c.pushToTalk = enabled
+	return nil
+}
+
+func (c *Client) PushToTalk() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pushToTalk
+}
+
+// MicOpen reports whether captured audio should be sent to the session.
+// It is always true unless push-to-talk mode is enabled.
+func (c *Client) MicOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.pushToTalk || c.talking
+}
+
+// BeginTurn opens the microphone and clears the input audio buffer so that the
+// turn only contains what is said from now on.
+func (c *Client) BeginTurn() error {
+	c.mu.Lock()
+	if !c.pushToTalk {
+		c.mu.Unlock()
+		return shared.ErrPushToTalkDisabled
+	}
+	if c.talking {
+		c.mu.Unlock()
+		return nil
+	}
+	c.talking = true
+	c.mu.Unlock()
+	c.logger.Info("push-to-talk turn began")
+	err := c.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeInputAudioBufferClear,
+		Param: &ClientEventParamInputAudioBufferClear{},
+	})
+	if err != nil {
+		return fmt.Errorf("clearing input audio buffer: %w", err)
+	}
+	return nil
+}
+
+// EndTurn closes the microphone, commits the input audio buffer and asks for
+// a response.
+func (c *Client) EndTurn() error {
+	c.mu.Lock()
+	if !c.pushToTalk {
+		c.mu.Unlock()
+		return shared.ErrPushToTalkDisabled
+	}
+	if !c.talking {
+		c.mu.Unlock()
+		return nil
+	}
+	c.talking = false
+	c.mu.Unlock()
+	c.logger.Info("push-to-talk turn ended")
+	err := c.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeInputAudioBufferCommit,
+		Param: &ClientEventParamInputAudioBufferCommit{},
+	})
+	if err != nil {
+		return fmt.Errorf("committing input audio buffer: %w", err)
+	}
+	err = c.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeResponseCreate,
+		Param: &ClientEventParamResponseCreate{},
+	})
+	if err != nil {
+		return fmt.Errorf("creating response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package realtime_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/openai/openai-go/v3/realtime"
+)
+
+func TestPushToTalk(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		c, err := pkg.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer func() { _ = c.Close() }()
+		if !c.MicOpen() {
+			t.Error("Expected the microphone to be open without push-to-talk")
+		}
+		if err := c.BeginTurn(); !errors.Is(err, shared.ErrPushToTalkDisabled) {
+			t.Errorf("Expected ErrPushToTalkDisabled, got %v", err)
+		}
+		if err := c.EndTurn(); !errors.Is(err, shared.ErrPushToTalkDisabled) {
+			t.Errorf("Expected ErrPushToTalkDisabled, got %v", err)
+		}
+	})
+
+	t.Run("TurnDetectionEnabled", func(t *testing.T) {
+		server := realtimetest.NewServer(realtimetest.Options{})
+		defer server.Close()
+		c, err := pkg.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", server.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer func() { _ = c.Close() }()
+		cfg := &realtime.RealtimeSessionCreateRequestParam{}
+		cfg.Audio.Input.TurnDetection.OfServerVad = &realtime.RealtimeAudioInputTurnDetectionServerVadParam{}
+		if err := c.SetConfig(cfg); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.RegisterEventHandler(func(*pkg.ServerEvent) {}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.SetPushToTalk(true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.Start(); !errors.Is(err, shared.ErrTurnDetectionEnabled) {
+			t.Errorf("Expected ErrTurnDetectionEnabled, got %v", err)
+		}
+		if len(server.Calls()) != 0 {
+			t.Error("Expected no call to be made")
+		}
+		// Nothing was negotiated, the client starts once the config is fixed
+		if err := c.SetConfig(&realtime.RealtimeSessionCreateRequestParam{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.Start(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		if _, err := server.WaitCall(ctx); err != nil {
+			t.Errorf("Expected a call, got %v", err)
+		}
+	})
+
+	t.Run("Turns", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		server := realtimetest.NewServer(realtimetest.Options{
+			Greeting: []*pkg.ServerEvent{{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{}}}},
+		})
+		defer server.Close()
+		opened := make(chan struct{})
+		c, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+			Events: func(event *pkg.ServerEvent) {
+				if event.Type == pkg.ServerEventTypeSessionCreated {
+					close(opened)
+				}
+			},
+			Setup: func(c *pkg.Client) error { return c.SetPushToTalk(true) },
+		})
+		select {
+		case <-opened:
+		case <-ctx.Done():
+			t.Fatal("Expected the data channel to open")
+		}
+		// The greeting is answered on open, before any turn
+		if _, err := call.WaitMessage(ctx, pkg.ClientEventTypeResponseCreate); err != nil {
+			t.Fatalf("Expected the greeting, got %v", err)
+		}
+
+		audio, _ := call.Session["audio"].(map[string]any)
+		input, _ := audio["input"].(map[string]any)
+		if detection, ok := input["turn_detection"]; !ok || detection != nil {
+			t.Errorf("Expected turn_detection to be null, got %v", call.Session)
+		}
+
+		if c.MicOpen() {
+			t.Error("Expected the microphone to be closed between turns")
+		}
+		if err := c.BeginTurn(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !c.MicOpen() {
+			t.Error("Expected the microphone to be open during the turn")
+		}
+		if _, err := call.WaitMessage(ctx, pkg.ClientEventTypeInputAudioBufferClear); err != nil {
+			t.Fatalf("Expected the input buffer to be cleared, got %v", err)
+		}
+		if err := c.EndTurn(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if c.MicOpen() {
+			t.Error("Expected the microphone to be closed after the turn")
+		}
+		expected := []pkg.ClientEventType{
+			pkg.ClientEventTypeInputAudioBufferClear,
+			pkg.ClientEventTypeInputAudioBufferCommit,
+			pkg.ClientEventTypeResponseCreate,
+		}
+		var types []pkg.ClientEventType
+		for len(types) < len(expected) {
+			types = types[:0]
+			for _, msg := range call.Messages() {
+				if msg.Type == pkg.ClientEventTypeInputAudioBufferClear || len(types) > 0 {
+					types = append(types, msg.Type)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Expected %v, got %v", expected, types)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if !slices.Equal(types, expected) {
+			t.Errorf("Expected %v, got %v", expected, types)
+		}
+		// Ending twice does not commit again
+		if err := c.EndTurn(); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,338 @@
+package realtime
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/pion/webrtc/v4"
+	"go.uber.org/zap"
+)
+
+type QualityLevel int
+
+const (
+	QualityGood QualityLevel = iota
+	QualityFair
+	QualityPoor // e.g. "poor network" in a UI
+)
+
+func (l QualityLevel) String() string {
+	switch l {
+	case QualityGood:
+		return "good"
+	case QualityFair:
+		return "fair"
+	case QualityPoor:
+		return "poor"
+	}
+	return "unknown"
+}
+
+// Quality issues, see QualitySample.Issues
+const (
+	QualityIssueRTT      = "rtt"
+	QualityIssueJitter   = "jitter"
+	QualityIssueLoss     = "loss"
+	QualityIssueBacklog  = "backlog" // the data channel does not drain
+	qualityRecoverStreak = 3         // better samples in a row before the level improves
+)
+
+// QualitySample is the state of the connection at a time, the rates cover the
+// time since the previous sample.
+type QualitySample struct {
+	Time             time.Time
+	RTT              time.Duration // of the selected candidate pair, of SCTP without a measure
+	Jitter           time.Duration // of the received audio
+	PacketsReceived  uint64
+	PacketsLost      uint64
+	Loss             float64 // fraction of the packets lost
+	ReceiveBitrate   float64 // bits per second
+	SendBitrate      float64
+	LocalCandidate   string // type of the selected pair: host, srflx, prflx or relay
+	RemoteCandidate  string
+	BufferedAmount   uint64 // of the data channel
+	MessagesSent     uint32
+	MessagesReceived uint32
+	Level            QualityLevel
+	Issues           []string // of Level
+}
+
+// AudioStats are the cumulative statistics of the local playback of a session,
+// e.g. for metrics.
+type AudioStats struct {
+	DroppedBytes uint64 // dropped by the playback buffer
+	Underruns    uint64
+	DecodeErrors uint64
+}
+
+// QualityThreshold is the level where a measure degrades the quality, zero
+// fields are ignored.
+type QualityThreshold struct {
+	RTT            time.Duration
+	Jitter         time.Duration
+	Loss           float64
+	BufferedAmount uint64
+}
+
+type QualityThresholds struct {
+	Fair QualityThreshold
+	Poor QualityThreshold
+}
+
+// DefaultQualityThresholds suit a voice conversation.
+var DefaultQualityThresholds = QualityThresholds{
+	Fair: QualityThreshold{RTT: 300 * time.Millisecond, Jitter: 30 * time.Millisecond, Loss: 0.02, BufferedAmount: 64 << 10},
+	Poor: QualityThreshold{RTT: 600 * time.Millisecond, Jitter: 60 * time.Millisecond, Loss: 0.08, BufferedAmount: 256 << 10},
+}
+
+// exceeded returns the issues of a sample over the threshold.
+func (t QualityThreshold) exceeded(s QualitySample) []string {
+	var issues []string
+	if t.RTT > 0 && s.RTT >= t.RTT {
+		issues = append(issues, QualityIssueRTT)
+	}
+	if t.Jitter > 0 && s.Jitter >= t.Jitter {
+		issues = append(issues, QualityIssueJitter)
+	}
+	if t.Loss > 0 && s.Loss >= t.Loss {
+		issues = append(issues, QualityIssueLoss)
+	}
+	if t.BufferedAmount > 0 && s.BufferedAmount >= t.BufferedAmount {
+		issues = append(issues, QualityIssueBacklog)
+	}
+	return issues
+}
+
+// Level returns the level of a sample and the measures responsible for it.
+func (t QualityThresholds) Level(s QualitySample) (QualityLevel, []string) {
+	if issues := t.Poor.exceeded(s); len(issues) > 0 {
+		return QualityPoor, issues
+	}
+	if issues := t.Fair.exceeded(s); len(issues) > 0 {
+		return QualityFair, issues
+	}
+	return QualityGood, nil
+}
+
+// qualityCounters are the cumulative statistics the rates are computed from.
+type qualityCounters struct {
+	time          time.Time
+	bytesSent     uint64
+	bytesReceived uint64
+	received      uint64
+	lost          uint64
+}
+
+// QualityMonitor samples the statistics of the peer connection of a client
+// while it is connected, to tell when the network degrades.
+type QualityMonitor struct {
+	logger     shared.LoggerAdapter
+	client     *Client
+	interval   time.Duration
+	thresholds QualityThresholds
+	onChange   func(previous QualityLevel, sample QualitySample)
+
+	mu       sync.Mutex
+	last     QualitySample
+	level    QualityLevel
+	streak   int // samples in a row better than level
+	counters qualityCounters
+	closed   chan struct{}
+}
+
+// NewQualityMonitor starts sampling every interval once the client is
+// connected, until it is done or the monitor is closed. thresholds default to
+// DefaultQualityThresholds, onChange is optional and called when the level
+// changes. The level degrades at once but improves after a few better
+// samples, so a UI does not flicker.
+func NewQualityMonitor(
+	logger shared.LoggerAdapter,
+	client *Client,
+	interval time.Duration,
+	thresholds *QualityThresholds,
+	onChange func(previous QualityLevel, sample QualitySample),
+) (*QualityMonitor, error) {
+	if logger == nil {
+		return nil, shared.ErrNoLogger
+	}
+	if client == nil {
+		return nil, shared.ErrClientNotInitialized
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	if thresholds == nil {
+		thresholds = &DefaultQualityThresholds
+	}
+	m := &QualityMonitor{
+		logger:     logger,
+		client:     client,
+		interval:   interval,
+		thresholds: *thresholds,
+		onChange:   onChange,
+		closed:     make(chan struct{}),
+	}
+	go m.run()
+	return m, nil
+}
+
+func (m *QualityMonitor) run() {
+	select {
+	case <-m.closed:
+		return
+	case <-m.client.Done():
+		return
+	case <-m.client.Connected():
+	}
+	m.mu.Lock()
+	m.last, m.counters = m.read(qualityCounters{})
+	m.mu.Unlock()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.closed:
+			return
+		case <-m.client.Done():
+			return
+		case <-ticker.C:
+			m.sample()
+		}
+	}
+}
+
+func (m *QualityMonitor) sample() {
+	m.mu.Lock()
+	sample, counters := m.read(m.counters)
+	m.counters = counters
+	m.mu.Unlock()
+	m.observe(sample)
+}
+
+// observe rates a sample, logs it and reports a change of level.
+func (m *QualityMonitor) observe(sample QualitySample) {
+	m.mu.Lock()
+	level, issues := m.thresholds.Level(sample)
+	previous := m.level
+	if level < m.level {
+		m.streak++
+	}
+	if level >= m.level || m.streak >= qualityRecoverStreak {
+		m.level = level
+		m.streak = 0
+	}
+	sample.Level = m.level
+	sample.Issues = issues
+	if sample.Level != level {
+		// Still recovering, the level and its issues stay
+		sample.Issues = m.last.Issues
+	}
+	m.last = sample
+	m.mu.Unlock()
+	m.logger.Trace(
+		"connection quality",
+		zap.Stringer("level", sample.Level),
+		zap.Duration("rtt", sample.RTT),
+		zap.Duration("jitter", sample.Jitter),
+		zap.Float64("loss", sample.Loss),
+		zap.Float64("receive_bitrate", sample.ReceiveBitrate),
+		zap.Float64("send_bitrate", sample.SendBitrate),
+		zap.Uint64("buffered", sample.BufferedAmount),
+	)
+	if sample.Level == previous {
+		return
+	}
+	m.logger.Info(
+		"connection quality changed",
+		zap.Stringer("previous", previous),
+		zap.Stringer("level", sample.Level),
+		zap.Strings("issues", sample.Issues),
+		zap.Duration("rtt", sample.RTT),
+		zap.Duration("jitter", sample.Jitter),
+		zap.Float64("loss", sample.Loss),
+		zap.String("local_candidate", sample.LocalCandidate),
+		zap.String("remote_candidate", sample.RemoteCandidate),
+	)
+	if m.onChange != nil {
+		m.onChange(previous, sample)
+	}
+}
+
+// read takes a sample, with the rates since previous.
+func (m *QualityMonitor) read(previous qualityCounters) (QualitySample, qualityCounters) {
+	now := time.Now()
+	sample := QualitySample{Time: now}
+	counters := qualityCounters{time: now}
+	report := m.client.Stats()
+	var sctpRTT time.Duration
+	var localId, remoteId string
+	for _, stats := range report {
+		switch stats := stats.(type) {
+		case webrtc.ICECandidatePairStats:
+			if stats.Nominated && stats.State == webrtc.StatsICECandidatePairStateSucceeded {
+				localId, remoteId = stats.LocalCandidateID, stats.RemoteCandidateID
+				sample.RTT = seconds(stats.CurrentRoundTripTime)
+			}
+		case webrtc.SCTPTransportStats:
+			sctpRTT = seconds(stats.SmoothedRoundTripTime)
+		case webrtc.TransportStats:
+			counters.bytesSent += stats.BytesSent
+			counters.bytesReceived += stats.BytesReceived
+		case webrtc.InboundRTPStreamStats:
+			counters.received += uint64(stats.PacketsReceived)
+			counters.lost += uint64(max(stats.PacketsLost, 0))
+			sample.Jitter = max(sample.Jitter, seconds(stats.Jitter))
+		case webrtc.DataChannelStats:
+			sample.MessagesSent += stats.MessagesSent
+			sample.MessagesReceived += stats.MessagesReceived
+		}
+	}
+	if sample.RTT == 0 {
+		sample.RTT = sctpRTT
+	}
+	if stats, ok := report[localId].(webrtc.ICECandidateStats); ok {
+		sample.LocalCandidate = stats.CandidateType.String()
+	}
+	if stats, ok := report[remoteId].(webrtc.ICECandidateStats); ok {
+		sample.RemoteCandidate = stats.CandidateType.String()
+	}
+	if dc := m.client.DC(); dc != nil {
+		sample.BufferedAmount = dc.BufferedAmount()
+	}
+	sample.PacketsReceived = counters.received
+	sample.PacketsLost = counters.lost
+	if elapsed := now.Sub(previous.time).Seconds(); !previous.time.IsZero() && elapsed > 0 {
+		sample.SendBitrate = float64(counters.bytesSent-min(previous.bytesSent, counters.bytesSent)) * 8 / elapsed
+		sample.ReceiveBitrate = float64(counters.bytesReceived-min(previous.bytesReceived, counters.bytesReceived)) * 8 / elapsed
+	}
+	received := counters.received - min(previous.received, counters.received)
+	lost := counters.lost - min(previous.lost, counters.lost)
+	if received+lost > 0 {
+		sample.Loss = float64(lost) / float64(received+lost)
+	}
+	return sample, counters
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Last returns the last sample, zero before the connection.
+func (m *QualityMonitor) Last() QualitySample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Close stops sampling.
+func (m *QualityMonitor) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-m.closed:
+	default:
+		close(m.closed)
+	}
+}
@@ -0,0 +1,133 @@
+package realtime_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+func TestQualityMonitor(t *testing.T) {
+	t.Run("Thresholds", func(t *testing.T) {
+		thresholds := pkg.DefaultQualityThresholds
+		for _, c := range []struct {
+			sample pkg.QualitySample
+			level  pkg.QualityLevel
+			issues []string
+		}{
+			{pkg.QualitySample{RTT: 50 * time.Millisecond}, pkg.QualityGood, nil},
+			{pkg.QualitySample{RTT: 400 * time.Millisecond, Loss: 0.01}, pkg.QualityFair, []string{pkg.QualityIssueRTT}},
+			{pkg.QualitySample{Jitter: 40 * time.Millisecond, Loss: 0.1}, pkg.QualityPoor, []string{pkg.QualityIssueLoss}},
+			{pkg.QualitySample{BufferedAmount: 1 << 20}, pkg.QualityPoor, []string{pkg.QualityIssueBacklog}},
+		} {
+			level, issues := thresholds.Level(c.sample)
+			if level != c.level || !slices.Equal(issues, c.issues) {
+				t.Errorf("Expected %v %v for %+v, got %v %v", c.level, c.issues, c.sample, level, issues)
+			}
+		}
+		if _, err := pkg.NewQualityMonitor(shared.NewStdLogger(), nil, time.Second, nil, nil); err == nil {
+			t.Error("Expected a client to be required")
+		}
+	})
+
+	t.Run("Hysteresis", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c, err := pkg.NewClient(ctx, shared.NewStdLogger(), "sk-test", "", "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		type change struct{ previous, level pkg.QualityLevel }
+		var changes []change
+		m, err := pkg.NewQualityMonitor(shared.NewStdLogger(), c, time.Second, nil, func(previous pkg.QualityLevel, sample pkg.QualitySample) {
+			changes = append(changes, change{previous, sample.Level})
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer m.Close()
+
+		good := pkg.QualitySample{RTT: 50 * time.Millisecond}
+		fair := pkg.QualitySample{RTT: 400 * time.Millisecond}
+		poor := pkg.QualitySample{Loss: 0.1}
+		pkg.ObserveQuality(m, good)
+		pkg.ObserveQuality(m, poor)
+		if len(changes) != 1 || changes[0] != (change{pkg.QualityGood, pkg.QualityPoor}) {
+			t.Fatalf("Expected to degrade at once, got %v", changes)
+		}
+		pkg.ObserveQuality(m, good)
+		pkg.ObserveQuality(m, good)
+		if last := m.Last(); last.Level != pkg.QualityPoor || !slices.Equal(last.Issues, []string{pkg.QualityIssueLoss}) {
+			t.Errorf("Expected the level and issues to stay while recovering, got %+v", last)
+		}
+		// A worse sample starts the recovery over
+		pkg.ObserveQuality(m, poor)
+		pkg.ObserveQuality(m, good)
+		pkg.ObserveQuality(m, good)
+		if len(changes) != 1 {
+			t.Fatalf("Expected no recovery yet, got %v", changes)
+		}
+		pkg.ObserveQuality(m, fair)
+		if len(changes) != 2 || changes[1] != (change{pkg.QualityPoor, pkg.QualityFair}) {
+			t.Fatalf("Expected to recover after three better samples, got %v", changes)
+		}
+		if last := m.Last(); !slices.Equal(last.Issues, []string{pkg.QualityIssueRTT}) {
+			t.Errorf("Expected the issues of the new level, got %+v", last)
+		}
+		pkg.ObserveQuality(m, poor)
+		if len(changes) != 3 || changes[2] != (change{pkg.QualityFair, pkg.QualityPoor}) {
+			t.Errorf("Expected to degrade again, got %v", changes)
+		}
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		server := realtimetest.NewServer(realtimetest.Options{
+			Greeting: []*pkg.ServerEvent{{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{}}}},
+			Audio:    realtimetest.AudioGenerate,
+		})
+		defer server.Close()
+		var m *pkg.QualityMonitor
+		c, _ := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+			Greeting: "Hello",
+			Setup: func(c *pkg.Client) (err error) {
+				m, err = pkg.NewQualityMonitor(shared.NewStdLogger(), c, 50*time.Millisecond, nil, nil)
+				return err
+			},
+		})
+		defer m.Close()
+
+		var sample pkg.QualitySample
+		for {
+			sample = m.Last()
+			if sample.ReceiveBitrate > 0 && sample.SendBitrate > 0 && sample.PacketsReceived > 0 && sample.MessagesReceived > 0 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("Expected audio and messages to be received, got %+v", sample)
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+		if sample.LocalCandidate != "host" || sample.RemoteCandidate != "host" {
+			t.Errorf("Expected a host candidate pair, got %q and %q", sample.LocalCandidate, sample.RemoteCandidate)
+		}
+		if sample.Level != pkg.QualityGood || sample.Loss != 0 {
+			t.Errorf("Expected a good local connection, got %+v", sample)
+		}
+
+		if err := c.Close(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		last := m.Last()
+		time.Sleep(200 * time.Millisecond)
+		if !m.Last().Time.Equal(last.Time) {
+			t.Error("Expected the sampling to stop with the client")
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package realtime
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+// RateLimit is a limit reported by rate_limits.updated, e.g. "requests" or
+// "tokens".
+type RateLimit struct {
+	Name      string
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until the limit is restored, from Updated
+	Updated   time.Time
+}
+
+func (l RateLimit) ResetAt() time.Time {
+	return l.Updated.Add(l.Reset)
+}
+
+// Fraction returns the fraction of the limit remaining, 1 if the limit is not
+// known.
+func (l RateLimit) Fraction() float64 {
+	if l.Limit <= 0 {
+		return 1
+	}
+	return float64(l.Remaining) / float64(l.Limit)
+}
+
+// Limits returns the rate limits of the event, received at now.
+func (p *ServerEventParamRatelimitsUpdated) Limits(now time.Time) []RateLimit {
+	limits := make([]RateLimit, 0, len(p.RateLimits))
+	for _, m := range p.RateLimits {
+		l := RateLimit{Updated: now}
+		l.Name, _ = m["name"].(string)
+		l.Limit, _ = asInt(m["limit"])
+		l.Remaining, _ = asInt(m["remaining"])
+		switch seconds := m["reset_seconds"].(type) {
+		case float64:
+			l.Reset = time.Duration(seconds * float64(time.Second))
+		default:
+			if n, ok := asInt(seconds); ok {
+				l.Reset = time.Duration(n) * time.Second
+			}
+		}
+		limits = append(limits, l)
+	}
+	return limits
+}
+
+// RateLimitPolicy returns how long to wait before sending an out-of-band
+// response.create, given the current limits. Responses of the conversation
+// are never delayed, the user is waiting for them.
+type RateLimitPolicy func(limits []RateLimit, now time.Time) time.Duration
+
+// WaitForReset delays the responses until the limits with at most threshold
+// of them remaining are reset, for at most maxDelay.
+func WaitForReset(threshold float64, maxDelay time.Duration) RateLimitPolicy {
+	return func(limits []RateLimit, now time.Time) time.Duration {
+		var delay time.Duration
+		for _, l := range limits {
+			if l.Fraction() <= threshold {
+				delay = max(delay, l.ResetAt().Sub(now))
+			}
+		}
+		return min(delay, maxDelay)
+	}
+}
+
+// RateLimits keeps the last rate limits reported to one or more clients, see
+// RateLimitPool to share them between the clients of an API key.
+type RateLimits struct {
+	policy RateLimitPolicy // optional
+	warnAt float64
+
+	mu        sync.Mutex
+	limits    map[string]RateLimit
+	warned    map[string]bool // below warnAt since the last warning
+	onChange  map[int]func(limits []RateLimit)
+	onWarning map[int]func(limit RateLimit)
+	handlers  int
+	now       func() time.Time
+}
+
+// NewRateLimits makes the state, policy is optional. The warning handlers are
+// called when the remaining fraction of a limit goes down to warnAt, never if
+// zero.
+func NewRateLimits(policy RateLimitPolicy, warnAt float64) *RateLimits {
+	return &RateLimits{
+		policy:    policy,
+		warnAt:    warnAt,
+		limits:    map[string]RateLimit{},
+		warned:    map[string]bool{},
+		onChange:  map[int]func(limits []RateLimit){},
+		onWarning: map[int]func(limit RateLimit){},
+		now:       time.Now,
+	}
+}
+
+// OnChange calls handler with all the limits when the limit or remaining
+// count of one changes, until remove is called. It is called from the event
+// dispatch of the client that received the update.
+func (r *RateLimits) OnChange(handler func(limits []RateLimit)) (remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers++
+	id := r.handlers
+	r.onChange[id] = handler
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.onChange, id)
+	}
+}
+
+// OnWarning calls handler when a limit is nearly exhausted, until remove is
+// called. It is called from the event dispatch of the client that received
+// the update.
+func (r *RateLimits) OnWarning(handler func(limit RateLimit)) (remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers++
+	id := r.handlers
+	r.onWarning[id] = handler
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.onWarning, id)
+	}
+}
+
+// Limits returns the last limits, by name.
+func (r *RateLimits) Limits() []RateLimit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sorted()
+}
+
+// sorted returns the limits by name, r.mu must be held.
+func (r *RateLimits) sorted() []RateLimit {
+	limits := make([]RateLimit, 0, len(r.limits))
+	for _, l := range r.limits {
+		limits = append(limits, l)
+	}
+	slices.SortFunc(limits, func(a, b RateLimit) int { return cmp.Compare(a.Name, b.Name) })
+	return limits
+}
+
+// Limit returns the last limit of a name, e.g. "tokens".
+func (r *RateLimits) Limit(name string) (RateLimit, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.limits[name]
+	return l, ok
+}
+
+// Update records the limits of a rate_limits.updated event and notifies the
+// handlers, the clients call it for their events.
+func (r *RateLimits) Update(limits []RateLimit) {
+	r.mu.Lock()
+	changed := false
+	var warnings []RateLimit
+	for _, l := range limits {
+		last, ok := r.limits[l.Name]
+		r.limits[l.Name] = l
+		changed = changed || !ok || last.Limit != l.Limit || last.Remaining != l.Remaining
+		low := r.warnAt > 0 && l.Fraction() <= r.warnAt
+		if low && !r.warned[l.Name] {
+			warnings = append(warnings, l)
+		}
+		r.warned[l.Name] = low
+	}
+	var all []RateLimit
+	var onChange []func(limits []RateLimit)
+	if changed {
+		all = r.sorted()
+		for _, handler := range r.onChange {
+			onChange = append(onChange, handler)
+		}
+	}
+	var onWarning []func(limit RateLimit)
+	if len(warnings) > 0 {
+		for _, handler := range r.onWarning {
+			onWarning = append(onWarning, handler)
+		}
+	}
+	r.mu.Unlock()
+	for _, handler := range onChange {
+		handler(all)
+	}
+	for _, l := range warnings {
+		for _, handler := range onWarning {
+			handler(l)
+		}
+	}
+}
+
+// Delay returns how long the policy waits before the next out-of-band
+// response.create.
+func (r *RateLimits) Delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.policy == nil {
+		return 0
+	}
+	return max(r.policy(r.sorted(), r.now()), 0)
+}
+
+// wait waits for the delay of the policy, or ctx.
+func (r *RateLimits) wait(ctx context.Context) error {
+	delay := r.Delay()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimitPool shares the rate limits between the clients of an API key, e.g.
+// on a server running many sessions.
+type RateLimitPool struct {
+	policy RateLimitPolicy
+	warnAt float64
+
+	mu    sync.Mutex
+	byKey map[string]*RateLimits
+}
+
+// NewRateLimitPool makes a pool, the limits of each API key use policy and
+// warnAt, see NewRateLimits.
+func NewRateLimitPool(policy RateLimitPolicy, warnAt float64) *RateLimitPool {
+	return &RateLimitPool{
+		policy: policy,
+		warnAt: warnAt,
+		byKey:  map[string]*RateLimits{},
+	}
+}
+
+// For returns the limits of an API key.
+func (p *RateLimitPool) For(apiKey string) *RateLimits {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.byKey[apiKey]
+	if !ok {
+		r = NewRateLimits(p.policy, p.warnAt)
+		p.byKey[apiKey] = r
+	}
+	return r
+}
+
+// Attach makes the client use the limits of its API key. It must be called
+// before Start.
+func (p *RateLimitPool) Attach(c *Client) error {
+	if c == nil {
+		return errors.New("client is required")
+	}
+	return c.SetRateLimits(p.For(c.apiKey))
+}
+
+// SetRateLimits replaces the rate limits of the client, e.g. to share them,
+// see RateLimitPool. It must be called before Start.
+func (c *Client) SetRateLimits(limits *RateLimits) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return shared.ErrSessionAlreadyRunning
+	}
+	if limits == nil {
+		return errors.New("rate limits are required")
+	}
+	c.rateLimits = limits
+	return nil
+}
+
+// RateLimits returns the rate limits reported to the client, SendEvent
+// waits for their policy before an out-of-band response.create.
+func (c *Client) RateLimits() *RateLimits {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimits
+}
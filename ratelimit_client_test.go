@@ -0,0 +1,89 @@
+package realtime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+)
+
+func TestSendEventRateLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	const maxDelay = 300 * time.Millisecond
+	server := realtimetest.NewServer(realtimetest.Options{
+		Greeting: []*pkg.ServerEvent{{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{}}}},
+		Replies: map[pkg.ClientEventType][]*pkg.ServerEvent{
+			pkg.ClientEventTypeResponseCreate: {{Type: pkg.ServerEventTypeRatelimitsUpdated, Param: &pkg.ServerEventParamRatelimitsUpdated{RateLimits: []map[string]any{
+				{"name": "tokens", "limit": 10000, "remaining": 100, "reset_seconds": 5},
+			}}}},
+		},
+	})
+	defer server.Close()
+	updated := make(chan struct{}, 8)
+	c, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+		Events: func(event *pkg.ServerEvent) {
+			if event.Type == pkg.ServerEventTypeRatelimitsUpdated {
+				updated <- struct{}{}
+			}
+		},
+		Setup: func(c *pkg.Client) error {
+			return c.SetRateLimits(pkg.NewRateLimits(pkg.WaitForReset(0.1, maxDelay), 0))
+		},
+	})
+	// The greeting is answered with nearly exhausted limits
+	if _, err := call.WaitMessage(ctx, pkg.ClientEventTypeResponseCreate); err != nil {
+		t.Fatalf("Expected the greeting, got %v", err)
+	}
+	select {
+	case <-updated:
+	case <-ctx.Done():
+		t.Fatal("Expected the rate limits")
+	}
+	if d := c.RateLimits().Delay(); d != maxDelay {
+		t.Fatalf("Expected a delay of %v, got %v", maxDelay, d)
+	}
+	send := func(response map[string]any) time.Duration {
+		t.Helper()
+		start := time.Now()
+		if err := c.SendEvent(&pkg.ClientEvent{
+			Type:  pkg.ClientEventTypeResponseCreate,
+			Param: &pkg.ClientEventParamResponseCreate{Response: response},
+		}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return time.Since(start)
+	}
+
+	t.Run("Conversation", func(t *testing.T) {
+		if d := send(map[string]any{}); d >= maxDelay/2 {
+			t.Errorf("Expected a conversation response not to wait, took %v", d)
+		}
+	})
+
+	t.Run("OutOfBand", func(t *testing.T) {
+		if d := send(map[string]any{"conversation": "none"}); d < maxDelay {
+			t.Errorf("Expected an out-of-band response to wait for %v, took %v", maxDelay, d)
+		}
+	})
+
+	// Both responses reach the server, after the greeting
+	for {
+		var creates int
+		for _, msg := range call.Messages() {
+			if msg.Type == pkg.ClientEventTypeResponseCreate {
+				creates++
+			}
+		}
+		if creates == 3 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Expected 3 response.create, got %d", creates)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimits(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := func(remaining int) *ServerEventParamRatelimitsUpdated {
+		p := new(ServerEventParamRatelimitsUpdated)
+		if err := p.New(map[string]any{"rate_limits": []any{
+			map[string]any{"name": "requests", "limit": float64(100), "remaining": float64(99), "reset_seconds": float64(1)},
+			map[string]any{"name": "tokens", "limit": float64(10000), "remaining": float64(remaining), "reset_seconds": 6.5},
+		}}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return p
+	}
+
+	t.Run("Limits", func(t *testing.T) {
+		limits := event(4000).Limits(now)
+		if len(limits) != 2 {
+			t.Fatalf("Expected 2 limits, got %+v", limits)
+		}
+		tokens := limits[1]
+		if tokens.Name != "tokens" || tokens.Limit != 10000 || tokens.Remaining != 4000 || tokens.Reset != 6500*time.Millisecond {
+			t.Errorf("Expected the token limit, got %+v", tokens)
+		}
+		if !tokens.ResetAt().Equal(now.Add(6500*time.Millisecond)) || tokens.Fraction() != 0.4 {
+			t.Errorf("Expected the reset time and fraction, got %v and %v", tokens.ResetAt(), tokens.Fraction())
+		}
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		r := NewRateLimits(nil, 0.1)
+		var changes int
+		var warnings []RateLimit
+		r.OnChange(func(limits []RateLimit) { changes++ })
+		remove := r.OnWarning(func(limit RateLimit) { warnings = append(warnings, limit) })
+
+		r.Update(event(4000).Limits(now))
+		r.Update(event(4000).Limits(now.Add(time.Second)))
+		if changes != 1 {
+			t.Errorf("Expected a change only when the counts change, got %d", changes)
+		}
+		r.Update(event(900).Limits(now))
+		r.Update(event(800).Limits(now))
+		if changes != 3 || len(warnings) != 1 || warnings[0].Remaining != 900 {
+			t.Errorf("Expected a single warning below 10%%, got %d changes and %+v", changes, warnings)
+		}
+		r.Update(event(9000).Limits(now))
+		r.Update(event(500).Limits(now))
+		if len(warnings) != 2 {
+			t.Errorf("Expected a new warning after the reset, got %+v", warnings)
+		}
+		remove()
+		r.Update(event(9000).Limits(now))
+		r.Update(event(500).Limits(now))
+		if len(warnings) != 2 {
+			t.Errorf("Expected no warning once removed, got %+v", warnings)
+		}
+		if l, ok := r.Limit("tokens"); !ok || l.Remaining != 500 {
+			t.Errorf("Expected the last token limit, got %+v", l)
+		}
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		r := NewRateLimits(WaitForReset(0.1, 5*time.Second), 0)
+		r.now = func() time.Time { return now.Add(time.Second) }
+		r.Update(event(4000).Limits(now))
+		if d := r.Delay(); d != 0 {
+			t.Errorf("Expected no delay with enough tokens, got %v", d)
+		}
+		r.Update(event(500).Limits(now))
+		if d := r.Delay(); d != 5*time.Second {
+			t.Errorf("Expected the delay to be capped, got %v", d)
+		}
+		r.now = func() time.Time { return now.Add(6 * time.Second) }
+		if d := r.Delay(); d != 500*time.Millisecond {
+			t.Errorf("Expected to wait for the reset, got %v", d)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := r.wait(ctx); err == nil {
+			t.Error("Expected the wait to stop with the context")
+		}
+	})
+
+	t.Run("Pool", func(t *testing.T) {
+		pool := NewRateLimitPool(nil, 0.1)
+		if pool.For("sk-a") != pool.For("sk-a") || pool.For("sk-a") == pool.For("sk-b") {
+			t.Error("Expected the limits to be shared by API key")
+		}
+		a, b := &Client{apiKey: "sk-a"}, &Client{apiKey: "sk-a"}
+		if err := pool.Attach(a); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := pool.Attach(b); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		a.RateLimits().Update(event(500).Limits(now))
+		if l, ok := b.RateLimits().Limit("tokens"); !ok || l.Remaining != 500 {
+			t.Errorf("Expected the update of a client to reach the other, got %+v", l)
+		}
+	})
+}
@@ -0,0 +1,349 @@
+package realtimetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bytedance/sonic"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+const frameDuration = 20 * time.Millisecond
+
+// ClientMessage is a message received from the client on the data channel.
+type ClientMessage struct {
+	Type  realtime.ClientEventType
+	Event *realtime.ClientEvent // nil when the client package does not know the type
+	Raw   []byte
+	Time  time.Time
+}
+
+// Call is a session with a client.
+type Call struct {
+	ID string
+	// Session is the session config sent by the client.
+	Session map[string]any
+
+	server *Server
+	pc     *webrtc.PeerConnection
+	track  *webrtc.TrackLocalStaticSample // nil with AudioNone
+	ctx    context.Context
+	cancel context.CancelFunc
+	open   chan struct{}
+	done   chan struct{} // closed when the scenario ended
+	err    error
+
+	mu       sync.Mutex
+	dc       *webrtc.DataChannel
+	messages []ClientMessage
+	wake     chan struct{}
+	eventId  int
+}
+
+func newCall(s *Server, id, offer string, session map[string]any) (*Call, string, error) {
+	pc, err := s.api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, "", fmt.Errorf("creating peer connection: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Call{
+		ID:      id,
+		Session: session,
+		server:  s,
+		pc:      pc,
+		ctx:     ctx,
+		cancel:  cancel,
+		open:    make(chan struct{}),
+		done:    make(chan struct{}),
+		wake:    make(chan struct{}),
+	}
+	fail := func(err error) (*Call, string, error) {
+		c.Close()
+		return nil, "", err
+	}
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		if dc.Label() != "oai" {
+			return
+		}
+		c.mu.Lock()
+		c.dc = dc
+		c.mu.Unlock()
+		dc.OnOpen(func() {
+			close(c.open)
+			go c.play()
+		})
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			c.receive(msg.Data)
+		})
+	})
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateClosed || state == webrtc.PeerConnectionStateFailed {
+			c.cancel()
+		}
+	})
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		return fail(fmt.Errorf("setting remote description: %w", err))
+	}
+	withAudio := s.opts.Audio != AudioNone || (s.opts.Scenario != nil && s.opts.Scenario.hasAudio())
+	if withAudio && hasAudio(pc) {
+		c.track, err = webrtc.NewTrackLocalStaticSample(
+			webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+			"audio",
+			"assistant",
+		)
+		if err != nil {
+			return fail(fmt.Errorf("creating audio track: %w", err))
+		}
+		sender, err := pc.AddTrack(c.track)
+		if err != nil {
+			return fail(fmt.Errorf("adding audio track: %w", err))
+		}
+		go drainRTCP(sender)
+	}
+	switch s.opts.Audio {
+	case AudioEcho:
+		pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+			c.echo(track)
+		})
+	case AudioGenerate:
+		go c.generate()
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return fail(fmt.Errorf("creating answer: %w", err))
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return fail(fmt.Errorf("setting local description: %w", err))
+	}
+	<-gathered
+	return c, pc.LocalDescription().SDP, nil
+}
+
+func hasAudio(pc *webrtc.PeerConnection) bool {
+	for _, t := range pc.GetTransceivers() {
+		if t.Kind() == webrtc.RTPCodecTypeAudio {
+			return true
+		}
+	}
+	return false
+}
+
+func drainRTCP(sender *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := sender.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+func (c *Call) echo(track *webrtc.TrackRemote) {
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		if c.track == nil || len(packet.Payload) == 0 {
+			continue
+		}
+		_ = c.track.WriteSample(media.Sample{Data: packet.Payload, Duration: frameDuration})
+	}
+}
+
+func (c *Call) play() {
+	defer close(c.done)
+	if err := c.Send(c.server.opts.Greeting...); err != nil {
+		c.err = fmt.Errorf("sending greeting: %w", err)
+		return
+	}
+	if scenario := c.server.opts.Scenario; scenario != nil {
+		c.err = scenario.run(c)
+	}
+}
+
+// WaitScenario waits for the greeting and the scenario to be played.
+func (c *Call) WaitScenario(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return c.err
+	}
+}
+
+func (c *Call) generate() {
+	select {
+	case <-c.open:
+	case <-c.ctx.Done():
+		return
+	}
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		frames := c.server.opts.AudioFrames
+		_ = c.WriteAudio(frames[i%len(frames)])
+	}
+}
+
+func (c *Call) receive(data []byte) {
+	msg := ClientMessage{Raw: append([]byte(nil), data...), Time: time.Now()}
+	event := new(realtime.ClientEvent)
+	if err := event.UnmarshalJSON(data); err == nil {
+		msg.Event = event
+		msg.Type = event.Type
+	} else {
+		var raw struct {
+			Type string `json:"type"`
+		}
+		_ = sonic.Unmarshal(data, &raw)
+		msg.Type = realtime.ClientEventType(raw.Type)
+	}
+	c.mu.Lock()
+	c.messages = append(c.messages, msg)
+	close(c.wake)
+	c.wake = make(chan struct{})
+	c.mu.Unlock()
+	opts := c.server.opts
+	if replies := opts.Replies[msg.Type]; len(replies) > 0 {
+		_ = c.Send(replies...)
+	}
+	if opts.Handler != nil {
+		opts.Handler(c, msg)
+	}
+}
+
+// Opened is closed once the data channel is open.
+func (c *Call) Opened() <-chan struct{} {
+	return c.open
+}
+
+// Messages returns the messages received from the client so far.
+func (c *Call) Messages() []ClientMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ClientMessage(nil), c.messages...)
+}
+
+// WaitMessage waits for a message of the given type, received before or
+// after the call.
+func (c *Call) WaitMessage(ctx context.Context, eventType realtime.ClientEventType) (ClientMessage, error) {
+	_, msg, err := c.waitMessage(ctx, 0, func(msg ClientMessage) bool {
+		return msg.Type == eventType
+	})
+	if err != nil {
+		return ClientMessage{}, fmt.Errorf("waiting for %s: %w", eventType, err)
+	}
+	return msg, nil
+}
+
+// waitMessage waits for a matching message from the index from, it returns
+// the index of the message.
+func (c *Call) waitMessage(ctx context.Context, from int, match func(ClientMessage) bool) (int, ClientMessage, error) {
+	for {
+		c.mu.Lock()
+		for i := from; i < len(c.messages); i++ {
+			if match(c.messages[i]) {
+				msg := c.messages[i]
+				c.mu.Unlock()
+				return i, msg, nil
+			}
+		}
+		from = max(from, len(c.messages))
+		wake := c.wake
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return 0, ClientMessage{}, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Send sends events to the client, waiting Options.EventDelay before each.
+// Missing event IDs are filled in.
+func (c *Call) Send(events ...*realtime.ServerEvent) error {
+	select {
+	case <-c.open:
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+	for _, event := range events {
+		if delay := c.server.opts.EventDelay; delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-c.ctx.Done():
+				return c.ctx.Err()
+			}
+		}
+		c.mu.Lock()
+		c.eventId++
+		e := *event
+		if e.EventId == "" {
+			e.EventId = fmt.Sprintf("event_test_%d", c.eventId)
+		}
+		dc := c.dc
+		c.mu.Unlock()
+		data, err := e.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("marshaling %s: %w", e.Type, err)
+		}
+		if err := dc.SendText(string(data)); err != nil {
+			return fmt.Errorf("sending %s: %w", e.Type, err)
+		}
+	}
+	return nil
+}
+
+// WriteAudio sends a 20 ms Opus frame on the remote track of the client.
+func (c *Call) WriteAudio(frame []byte) error {
+	if c.track == nil {
+		return fmt.Errorf("the call has no audio track")
+	}
+	return c.track.WriteSample(media.Sample{Data: frame, Duration: frameDuration})
+}
+
+// streamAudio writes the frames paced to real time.
+func (c *Call) streamAudio(frames [][]byte) error {
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+	for _, frame := range frames {
+		if err := c.WriteAudio(frame); err != nil {
+			return err
+		}
+		select {
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// flush gives the data channel time to deliver the events sent before
+// hanging up.
+func (c *Call) flush() {
+	c.mu.Lock()
+	dc := c.dc
+	c.mu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for dc != nil && dc.BufferedAmount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	_ = sleep(c.ctx, 100*time.Millisecond)
+}
+
+// Close hangs up.
+func (c *Call) Close() {
+	c.cancel()
+	_ = c.pc.Close()
+}
@@ -0,0 +1,123 @@
+package realtimetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	openai "github.com/openai/openai-go/v3/realtime"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// ConnectOptions tune Connect, the zero value connects a client sending
+// silence and ignoring what it receives.
+type ConnectOptions struct {
+	// Greeting is the greeting of the client, see realtime.NewClient.
+	Greeting string
+	// Config is the session config, an empty one by default.
+	Config *openai.RealtimeSessionCreateRequestParam
+	// Events receives the server events.
+	Events func(event *realtime.ServerEvent)
+	// Local writes the microphone track, silence by default.
+	Local func(track *webrtc.TrackLocalStaticSample)
+	// Remote reads the assistant track, drained by default.
+	Remote func(track *webrtc.TrackRemote)
+	// TextOnly starts a text-only session, without the tracks.
+	TextOnly bool
+	// Setup is called before the client is started, e.g. to attach
+	// components to it.
+	Setup func(c *realtime.Client) error
+}
+
+// Connect starts a client on the server and waits for it to connect, or to be
+// done. The client is closed when the test ends, ctx bounds the whole
+// session.
+func Connect(t testing.TB, ctx context.Context, server *Server, opts ConnectOptions) (*realtime.Client, *Call) {
+	t.Helper()
+	c, err := realtime.NewClient(ctx, shared.NewStdLogger(), "sk-test", opts.Greeting, server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = &openai.RealtimeSessionCreateRequestParam{}
+	}
+	if err := c.SetConfig(cfg); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	events := opts.Events
+	if events == nil {
+		events = func(*realtime.ServerEvent) {}
+	}
+	if err := c.RegisterEventHandler(events); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if opts.TextOnly {
+		if err := c.SetTextOnly(true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	} else {
+		local := opts.Local
+		if local == nil {
+			local = func(track *webrtc.TrackLocalStaticSample) { writeSilence(ctx, c, track) }
+		}
+		if err := c.RegisterTrackLocalHandler(local); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		remote := opts.Remote
+		if remote == nil {
+			remote = drain
+		}
+		if err := c.RegisterTrackRemoteHandler(remote); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if opts.Setup != nil {
+		if err := opts.Setup(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	call, err := server.WaitCall(ctx)
+	if err != nil {
+		t.Fatalf("Expected a call, got %v", err)
+	}
+	select {
+	case <-c.Connected():
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatal("Expected the client to connect")
+	}
+	return c, call
+}
+
+// writeSilence writes silence frames paced to real time until the client is
+// done.
+func writeSilence(ctx context.Context, c *realtime.Client, track *webrtc.TrackLocalStaticSample) {
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			_ = track.WriteSample(media.Sample{Data: SilenceFrame, Duration: frameDuration})
+		}
+	}
+}
+
+func drain(track *webrtc.TrackRemote) {
+	for {
+		if _, _, err := track.ReadRTP(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package realtimetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bytedance/sonic"
+	"github.com/goccy/go-yaml"
+)
+
+// Scenario is a conversation described in YAML, played by the server on every
+// call, see Options.Scenario:
+//
+//	name: weather tool call
+//	steps:
+//	  - emit:
+//	      - type: session.created
+//	        session: {id: sess_1}
+//	  - on: conversation.item.create
+//	    contains: weather
+//	    delay: 100ms
+//	    emit:
+//	      - type: response.output_text.delta
+//	        response_id: resp_1
+//	        item_id: item_1
+//	        output_index: 0
+//	        content_index: 0
+//	        delta: Sunny
+//	        delay: 50ms
+//	    audio: sunny.wav
+//	  - on: response.cancel
+//	    error: {type: invalid_request_error, code: cancel_failed, message: no response}
+//	  - disconnect: true
+//	expect:
+//	  - type: conversation.item.create
+//	    contains: weather
+//	  - type: response.create
+//
+// Steps run in order, a step with on first waits for the next matching client
+// event. Events are written like the server sends them, event_id is optional.
+type Scenario struct {
+	Name   string        `yaml:"name"`
+	Steps  []Step        `yaml:"steps"`
+	Expect []Expectation `yaml:"expect"`
+
+	// Encoder returns the 20 ms Opus frames of the WAV file of an audio step,
+	// e.g. with tools.OpenWAVSource and tools.EncodeOpusFrames.
+	Encoder func(path string) ([][]byte, error) `yaml:"-"`
+
+	dir    string
+	mu     sync.Mutex
+	frames map[string][][]byte
+}
+
+type Step struct {
+	On       realtime.ClientEventType `yaml:"on"`
+	Contains string                   `yaml:"contains"` // in the raw JSON of the client event
+	Delay    Duration                 `yaml:"delay"`
+
+	Emit       []ScriptEvent `yaml:"emit"`
+	Audio      string        `yaml:"audio"` // WAV file, relative to the scenario file
+	Error      *ScriptError  `yaml:"error"`
+	Disconnect bool          `yaml:"disconnect"`
+}
+
+// ScriptEvent is a server event, sent after Delay.
+type ScriptEvent struct {
+	Delay time.Duration
+	Event *realtime.ServerEvent
+}
+
+type ScriptError struct {
+	Type    string `yaml:"type"`
+	Code    string `yaml:"code"`
+	Message string `yaml:"message"`
+}
+
+// Expectation matches a client event, expectations must be met in order.
+type Expectation struct {
+	Type     realtime.ClientEventType `yaml:"type"`
+	Contains string                   `yaml:"contains"`
+}
+
+// Duration is a time.Duration written like "1.5s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(data []byte) error {
+	var s string
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (e *ScriptEvent) UnmarshalYAML(data []byte) error {
+	var raw map[string]any
+	if err := yaml.UnmarshalWithOptions(data, &raw, yaml.UseJSONUnmarshaler()); err != nil {
+		return err
+	}
+	if v, ok := raw["delay"]; ok {
+		s, _ := v.(string)
+		delay, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("parsing delay: %w", err)
+		}
+		e.Delay = delay
+		delete(raw, "delay")
+	}
+	// The event ID is filled in when sent
+	_, hasId := raw["event_id"]
+	if !hasId {
+		raw["event_id"] = "script"
+	}
+	data, err := sonic.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	e.Event = new(realtime.ServerEvent)
+	if err := e.Event.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("parsing %v event: %w", raw["type"], err)
+	}
+	if !hasId {
+		e.Event.EventId = ""
+	}
+	return nil
+}
+
+// ParseScenario parses a scenario, audio files are relative to the working
+// directory.
+func ParseScenario(data []byte) (*Scenario, error) {
+	s := new(Scenario)
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+	for i, step := range s.Steps {
+		if step.Contains != "" && step.On == "" {
+			return nil, fmt.Errorf("step %d: contains needs on", i+1)
+		}
+	}
+	for i, expect := range s.Expect {
+		if expect.Type == "" {
+			return nil, fmt.Errorf("expectation %d: missing type", i+1)
+		}
+	}
+	return s, nil
+}
+
+// LoadScenario reads a scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+	s, err := ParseScenario(data)
+	if err != nil {
+		return nil, err
+	}
+	s.dir = filepath.Dir(path)
+	return s, nil
+}
+
+func (s *Scenario) hasAudio() bool {
+	for _, step := range s.Steps {
+		if step.Audio != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// audio returns the frames of an audio step, encoded once.
+func (s *Scenario) audio(file string) ([][]byte, error) {
+	if s.Encoder == nil {
+		return nil, errors.New("audio steps need Scenario.Encoder")
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(s.dir, file)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if frames, ok := s.frames[file]; ok {
+		return frames, nil
+	}
+	frames, err := s.Encoder(file)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", file, err)
+	}
+	if s.frames == nil {
+		s.frames = map[string][][]byte{}
+	}
+	s.frames[file] = frames
+	return frames, nil
+}
+
+func matches(msg ClientMessage, eventType realtime.ClientEventType, contains string) bool {
+	return msg.Type == eventType && bytes.Contains(msg.Raw, []byte(contains))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func (s *Scenario) run(c *Call) error {
+	cursor := 0
+	for i, step := range s.Steps {
+		if step.On != "" {
+			n, _, err := c.waitMessage(c.ctx, cursor, func(msg ClientMessage) bool {
+				return matches(msg, step.On, step.Contains)
+			})
+			if err != nil {
+				return fmt.Errorf("step %d: waiting for %s: %w", i+1, step.On, err)
+			}
+			cursor = n + 1
+		}
+		if err := sleep(c.ctx, time.Duration(step.Delay)); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		for _, e := range step.Emit {
+			if err := sleep(c.ctx, e.Delay); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+			if err := c.Send(e.Event); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		if step.Audio != "" {
+			frames, err := s.audio(step.Audio)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+			if err := c.streamAudio(frames); err != nil {
+				return fmt.Errorf("step %d: streaming audio: %w", i+1, err)
+			}
+		}
+		if step.Error != nil {
+			err := c.Send(&realtime.ServerEvent{
+				Type: realtime.ServerEventTypeError,
+				Param: &realtime.ServerEventParamError{
+					Type:    step.Error.Type,
+					Code:    step.Error.Code,
+					Message: step.Error.Message,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		if step.Disconnect {
+			c.flush()
+			c.Close()
+			return nil
+		}
+	}
+	return nil
+}
+
+// Check verifies the expectations against the events the client sent.
+func (s *Scenario) Check(c *Call) error {
+	messages := c.Messages()
+	cursor := 0
+	for i, expect := range s.Expect {
+		found := false
+		for ; cursor < len(messages) && !found; cursor++ {
+			found = matches(messages[cursor], expect.Type, expect.Contains)
+		}
+		if !found {
+			if expect.Contains != "" {
+				return fmt.Errorf("expectation %d: no %s containing %q", i+1, expect.Type, expect.Contains)
+			}
+			return fmt.Errorf("expectation %d: no %s", i+1, expect.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package realtimetest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/pion/webrtc/v4"
+)
+
+const testScenario = `
+name: text then hang up
+steps:
+  - emit:
+      - type: session.created
+        session: {id: sess_1}
+  - on: conversation.item.create
+    contains: weather
+    delay: 10ms
+    emit:
+      - type: response.output_text.delta
+        response_id: resp_1
+        item_id: item_1
+        output_index: 0
+        content_index: 0
+        delta: Sunny
+        delay: 10ms
+    audio: sunny.wav
+  - on: response.cancel
+    error: {type: invalid_request_error, code: cancel_failed, message: no response}
+    disconnect: true
+expect:
+  - type: conversation.item.create
+    contains: weather
+  - type: response.create
+  - type: response.cancel
+`
+
+func TestParseScenario(t *testing.T) {
+	s, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if s.Name != "text then hang up" || len(s.Steps) != 3 || len(s.Expect) != 3 {
+		t.Fatalf("Expected 3 steps and 3 expectations, got %+v", s)
+	}
+	step := s.Steps[1]
+	if step.On != realtime.ClientEventTypeConversationItemCreate || time.Duration(step.Delay) != 10*time.Millisecond {
+		t.Errorf("Expected the trigger and the delay, got %+v", step)
+	}
+	if len(step.Emit) != 1 || step.Emit[0].Delay != 10*time.Millisecond || step.Emit[0].Event.EventId != "" {
+		t.Errorf("Expected an event without ID after 10ms, got %+v", step.Emit)
+	}
+	if !s.Steps[2].Disconnect || s.Steps[2].Error.Code != "cancel_failed" {
+		t.Errorf("Expected an error and a disconnect, got %+v", s.Steps[2])
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, script := range []string{
+			"steps: [{emit: [{type: not.an.event, x: 1}]}]",
+			"steps: [{contains: x}]",
+			"steps: [{delay: soon}]",
+			"expect: [{contains: x}]",
+		} {
+			if _, err := ParseScenario([]byte(script)); err == nil {
+				t.Errorf("Expected an error for %q", script)
+			}
+		}
+	})
+}
+
+func TestScenario(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	scenario, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	scenario.Encoder = func(path string) ([][]byte, error) {
+		if !strings.HasSuffix(path, "sunny.wav") {
+			t.Errorf("Expected sunny.wav, got %s", path)
+		}
+		return [][]byte{SilenceFrame, SilenceFrame, SilenceFrame}, nil
+	}
+	server := NewServer(Options{Scenario: scenario})
+	defer server.Close()
+
+	events := make(chan *realtime.ServerEvent, 16)
+	audio := make(chan struct{})
+	c, call := Connect(t, ctx, server, ConnectOptions{
+		Events: func(event *realtime.ServerEvent) { events <- event },
+		Local:  func(*webrtc.TrackLocalStaticSample) {},
+		Remote: func(track *webrtc.TrackRemote) {
+			if _, _, err := track.ReadRTP(); err == nil {
+				close(audio)
+			}
+		},
+	})
+	next := func() *realtime.ServerEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-ctx.Done():
+			t.Fatal("Expected a server event")
+			return nil
+		}
+	}
+	if event := next(); event.Type != realtime.ServerEventTypeSessionCreated {
+		t.Fatalf("Expected session.created, got %s", event.Type)
+	}
+	// Not matched by contains
+	_ = c.SendText("Hi")
+	_ = c.SendText("How is the weather?")
+	if event := next(); event.Type != realtime.ServerEventTypeResponseOutputTextDelta {
+		t.Fatalf("Expected the text delta, got %s", event.Type)
+	}
+	select {
+	case <-audio:
+	case <-ctx.Done():
+		t.Fatal("Expected the assistant audio")
+	}
+	_ = c.SendEvent(&realtime.ClientEvent{Type: realtime.ClientEventTypeResponseCancel, Param: &realtime.ClientEventParamResponseCancel{}})
+	if event := next(); event.Type != realtime.ServerEventTypeError {
+		t.Fatalf("Expected the error, got %s", event.Type)
+	}
+	if err := call.WaitScenario(ctx); err != nil {
+		t.Fatalf("Expected the scenario to end, got %v", err)
+	}
+	select {
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatal("Expected the client to be disconnected")
+	}
+	if err := scenario.Check(call); err != nil {
+		t.Errorf("Expected the expectations to be met, got %v", err)
+	}
+	scenario.Expect = append(scenario.Expect, Expectation{Type: realtime.ClientEventTypeInputAudioBufferClear})
+	if err := scenario.Check(call); err == nil {
+		t.Error("Expected an unmet expectation")
+	}
+}
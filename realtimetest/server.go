@@ -0,0 +1,190 @@
+// Package realtimetest provides a fake Realtime server for integration tests,
+// like net/http/httptest. It answers WebRTC calls locally, so tests run
+// without network access or an API key.
+package realtimetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	realtime "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bytedance/sonic"
+	"github.com/pion/webrtc/v4"
+)
+
+// SilenceFrame is a 20 ms Opus frame of silence.
+var SilenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+type AudioMode int
+
+const (
+	AudioNone     AudioMode = iota // the server sends no audio
+	AudioEcho                      // the frames received from the client are sent back
+	AudioGenerate                  // Options.AudioFrames are sent in a loop
+)
+
+type Options struct {
+	// Greeting is played when the data channel opens, e.g. session.created.
+	Greeting []*realtime.ServerEvent
+	// Replies are played when the client sends an event of the given type.
+	Replies map[realtime.ClientEventType][]*realtime.ServerEvent
+	// Handler is called with every message of the client, after the replies.
+	Handler func(call *Call, msg ClientMessage)
+	// Scenario is played after the greeting.
+	Scenario *Scenario
+	// EventDelay is waited before each scripted event.
+	EventDelay time.Duration
+
+	Audio AudioMode
+	// AudioFrames are 20 ms Opus frames, SilenceFrame by default.
+	AudioFrames [][]byte
+}
+
+// Server is a fake Realtime server, pass its URL as the base URL of the
+// client.
+type Server struct {
+	URL string
+
+	opts Options
+	http *httptest.Server
+	api  *webrtc.API
+
+	mu    sync.Mutex
+	ids   int // call IDs handed out, calls are added once connected
+	calls []*Call
+	wake  chan struct{}
+}
+
+// NewServer starts a server, it must be closed when done.
+func NewServer(opts Options) *Server {
+	if len(opts.AudioFrames) == 0 {
+		opts.AudioFrames = [][]byte{SilenceFrame}
+	}
+	settings := webrtc.SettingEngine{}
+	// Like the OpenAI servers, the client does the connectivity checks
+	settings.SetLite(true)
+	settings.SetIncludeLoopbackCandidate(true)
+	settings.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	media := &webrtc.MediaEngine{}
+	if err := media.RegisterDefaultCodecs(); err != nil {
+		panic(fmt.Sprintf("realtimetest: registering codecs: %v", err))
+	}
+	s := &Server{
+		opts: opts,
+		api:  webrtc.NewAPI(webrtc.WithSettingEngine(settings), webrtc.WithMediaEngine(media)),
+		wake: make(chan struct{}),
+	}
+	s.http = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.http.URL + "/v1"
+	return s
+}
+
+// Close closes the calls and stops the server.
+func (s *Server) Close() {
+	for _, call := range s.Calls() {
+		call.Close()
+	}
+	s.http.Close()
+}
+
+func (s *Server) Calls() []*Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Call(nil), s.calls...)
+}
+
+// WaitCall waits for the first call.
+func (s *Server) WaitCall(ctx context.Context) (*Call, error) {
+	for {
+		s.mu.Lock()
+		if len(s.calls) > 0 {
+			call := s.calls[0]
+			s.mu.Unlock()
+			return call, nil
+		}
+		wake := s.wake
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/realtime/calls") {
+		http.NotFound(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		http.Error(w, "missing API key", http.StatusUnauthorized)
+		return
+	}
+	offer, session, err := parseCall(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.ids++
+	id := fmt.Sprintf("rtc_test_%d", s.ids)
+	s.mu.Unlock()
+	call, answer, err := newCall(s, id, offer, session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	close(s.wake)
+	s.wake = make(chan struct{})
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", "/v1/realtime/calls/"+id)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, answer)
+}
+
+// parseCall reads the SDP offer and the session config of a call request.
+func parseCall(r *http.Request) (offer string, session map[string]any, err error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return "", nil, errors.New("expected a multipart form")
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("reading form: %w", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return "", nil, fmt.Errorf("reading form: %w", err)
+		}
+		switch part.FormName() {
+		case "sdp":
+			offer = string(data)
+		case "session":
+			if err := sonic.Unmarshal(data, &session); err != nil {
+				return "", nil, fmt.Errorf("parsing session: %w", err)
+			}
+		}
+	}
+	if offer == "" {
+		return "", nil, errors.New("missing sdp")
+	}
+	return offer, session, nil
+}
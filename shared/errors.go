@@ -3,15 +3,25 @@ package shared
 import "errors"
 
 var (
-	ErrUnauthorized          = errors.New("unauthorized")
-	ErrForbidden             = errors.New("forbidden")
-	ErrNoLogger              = errors.New("no logger provided")
-	ErrNoConfig              = errors.New("no config provided")
-	ErrClientNotInitialized  = errors.New("client not initialized")
-	ErrNoEventHandler        = errors.New("no event handler provided")
-	ErrNoAPIKey              = errors.New("no API key provided")
-	ErrSessionAlreadyRunning = errors.New("session already running")
-	ErrTRHandlerAlreadySet   = errors.New("track remote handler already set")
-	ErrTLHandlerAlreadySet   = errors.New("track local handler already set")
-	ErrEHandlerAlreadySet    = errors.New("event handler already set")
+	ErrUnauthorized            = errors.New("unauthorized")
+	ErrForbidden               = errors.New("forbidden")
+	ErrNoLogger                = errors.New("no logger provided")
+	ErrNoConfig                = errors.New("no config provided")
+	ErrClientNotInitialized    = errors.New("client not initialized")
+	ErrNoEventHandler          = errors.New("no event handler provided")
+	ErrNoAPIKey                = errors.New("no API key provided")
+	ErrSessionAlreadyRunning   = errors.New("session already running")
+	ErrTRHandlerAlreadySet     = errors.New("track remote handler already set")
+	ErrTLHandlerAlreadySet     = errors.New("track local handler already set")
+	ErrEHandlerAlreadySet      = errors.New("event handler already set")
+	ErrTHandlerAlreadySet      = errors.New("text handler already set")
+	ErrSHandlerAlreadySet      = errors.New("state handler already set")
+	ErrNoApprover              = errors.New("no approver provided")
+	ErrTurnDetectionEnabled    = errors.New("turn detection must be disabled in push-to-talk mode")
+	ErrPushToTalkDisabled      = errors.New("push-to-talk mode is disabled")
+	ErrAudioInTextOnly         = errors.New("audio tracks are not supported in text-only mode")
+	ErrDeviceNotFound          = errors.New("audio device not found")
+	ErrAmbiguousDevice         = errors.New("several audio devices match")
+	ErrOutputDeviceUnsupported = errors.New("selecting the output device is not supported on this platform")
+	ErrSinkClosed              = errors.New("audio sink closed")
 )
@@ -0,0 +1,80 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+// SetTextOnly enables text-only mode: no media tracks are negotiated and the
+// session only outputs text. No track handler may be registered.
+func (c *Client) SetTextOnly(enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return shared.ErrSessionAlreadyRunning
+	}
+	if enabled && (c.audioL != nil || c.audioTRH != nil) {
+		return shared.ErrAudioInTextOnly
+	}
+	c.textOnly = enabled
+	return nil
+}
+
+func (c *Client) TextOnly() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.textOnly
+}
+
+// RegisterTextHandler registers a handler for response.output_text.delta
+// events. It is called before the event handler.
+func (c *Client) RegisterTextHandler(handler TextHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return shared.ErrSessionAlreadyRunning
+	}
+	if c.th != nil {
+		return shared.ErrTHandlerAlreadySet
+	}
+	if handler == nil {
+		return errors.New("handler is required")
+	}
+	c.th = handler
+	return nil
+}
+
+// SendText adds a user message to the conversation and asks for a response.
+func (c *Client) SendText(text string) error {
+	if text == "" {
+		return errors.New("text is required")
+	}
+	err := c.SendEvent(&ClientEvent{
+		Type: ClientEventTypeConversationItemCreate,
+		Param: &ClientEventParamConversationItemCreate{
+			Item: map[string]any{
+				"type": "message",
+				"role": "user",
+				"content": []any{
+					map[string]any{
+						"type": "input_text",
+						"text": text,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("creating user message: %w", err)
+	}
+	err = c.SendEvent(&ClientEvent{
+		Type:  ClientEventTypeResponseCreate,
+		Param: &ClientEventParamResponseCreate{},
+	})
+	if err != nil {
+		return fmt.Errorf("creating response: %w", err)
+	}
+	return nil
+}
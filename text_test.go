@@ -0,0 +1,162 @@
+package realtime_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestTextOnly(t *testing.T) {
+	newClient := func(t *testing.T) *pkg.Client {
+		t.Helper()
+		c, err := pkg.NewClient(context.Background(), shared.NewStdLogger(), "sk-test", "", "")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+
+	t.Run("RejectsTracks", func(t *testing.T) {
+		c := newClient(t)
+		if err := c.SetTextOnly(true); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		err := c.RegisterTrackLocalHandler(func(*webrtc.TrackLocalStaticSample) {})
+		if !errors.Is(err, shared.ErrAudioInTextOnly) {
+			t.Errorf("Expected ErrAudioInTextOnly, got %v", err)
+		}
+		err = c.RegisterTrackRemoteHandler(func(*webrtc.TrackRemote) {})
+		if !errors.Is(err, shared.ErrAudioInTextOnly) {
+			t.Errorf("Expected ErrAudioInTextOnly, got %v", err)
+		}
+	})
+
+	t.Run("RejectsRegisteredTracks", func(t *testing.T) {
+		c := newClient(t)
+		if err := c.RegisterTrackRemoteHandler(func(*webrtc.TrackRemote) {}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := c.SetTextOnly(true); !errors.Is(err, shared.ErrAudioInTextOnly) {
+			t.Errorf("Expected ErrAudioInTextOnly, got %v", err)
+		}
+	})
+
+	t.Run("SendText", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		delta := func(text string) *pkg.ServerEvent {
+			return &pkg.ServerEvent{Type: pkg.ServerEventTypeResponseOutputTextDelta, Param: &pkg.ServerEventParamResponseOutputTextDelta{ResponseId: "resp_1", ItemId: "item_1", Delta: text}}
+		}
+		server := realtimetest.NewServer(realtimetest.Options{
+			Greeting: []*pkg.ServerEvent{{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{}}}},
+			Replies: map[pkg.ClientEventType][]*pkg.ServerEvent{
+				pkg.ClientEventTypeConversationItemCreate: {delta("Hel"), delta("lo")},
+			},
+		})
+		defer server.Close()
+		var mu sync.Mutex
+		var received []string // deltas, then the event type
+		opened := make(chan struct{})
+		texts := make(chan struct{}, 2)
+		c, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+			TextOnly: true,
+			Events: func(event *pkg.ServerEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				switch event.Type {
+				case pkg.ServerEventTypeSessionCreated:
+					close(opened)
+				case pkg.ServerEventTypeResponseOutputTextDelta:
+					received = append(received, string(event.Type))
+					texts <- struct{}{}
+				}
+			},
+			Setup: func(c *pkg.Client) error {
+				return c.RegisterTextHandler(func(delta *pkg.ServerEventParamResponseOutputTextDelta) {
+					mu.Lock()
+					defer mu.Unlock()
+					received = append(received, delta.Delta)
+				})
+			},
+		})
+		if modalities, _ := call.Session["output_modalities"].([]any); len(modalities) != 1 || modalities[0] != "text" {
+			t.Errorf("Expected a text-only session, got %v", call.Session)
+		}
+		select {
+		case <-opened:
+		case <-ctx.Done():
+			t.Fatal("Expected the data channel to open")
+		}
+		// The greeting is answered on open
+		if _, err := call.WaitMessage(ctx, pkg.ClientEventTypeResponseCreate); err != nil {
+			t.Fatalf("Expected the greeting, got %v", err)
+		}
+
+		if err := c.SendText("Hey"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		msg, err := call.WaitMessage(ctx, pkg.ClientEventTypeConversationItemCreate)
+		if err != nil {
+			t.Fatalf("Expected conversation.item.create, got %v", err)
+		}
+		var create struct {
+			Item struct {
+				Type    string `json:"type"`
+				Role    string `json:"role"`
+				Content []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"item"`
+		}
+		if err := json.Unmarshal(msg.Raw, &create); err != nil {
+			t.Fatalf("Expected a JSON event, got %v", err)
+		}
+		item := create.Item
+		if item.Type != "message" || item.Role != "user" || len(item.Content) != 1 || item.Content[0].Type != "input_text" || item.Content[0].Text != "Hey" {
+			t.Errorf("Expected a user text message, got %s", msg.Raw)
+		}
+		// The response is asked right after the message
+		for {
+			messages := call.Messages()
+			i := slices.IndexFunc(messages, func(m realtimetest.ClientMessage) bool {
+				return m.Type == pkg.ClientEventTypeConversationItemCreate
+			})
+			if i+1 < len(messages) {
+				if messages[i+1].Type != pkg.ClientEventTypeResponseCreate {
+					t.Errorf("Expected response.create after the message, got %s", messages[i+1].Type)
+				}
+				break
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatal("Expected response.create")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		for range 2 {
+			select {
+			case <-texts:
+			case <-ctx.Done():
+				t.Fatal("Expected the text deltas")
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		expected := []string{"Hel", string(pkg.ServerEventTypeResponseOutputTextDelta), "lo", string(pkg.ServerEventTypeResponseOutputTextDelta)}
+		if !slices.Equal(received, expected) {
+			t.Errorf("Expected each delta to reach the text handler first, got %v", received)
+		}
+	})
+}
@@ -2,172 +2,498 @@ package tools
 
 import (
 	"context"
-	"encoding/binary"
-	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bridge-packages/go-openai-realtime/shared"
-	"github.com/ebitengine/oto/v3"
 	"github.com/hraban/opus"
-	"github.com/pion/mediadevices"
 	"github.com/pion/webrtc/v4"
 	"github.com/pion/webrtc/v4/pkg/media"
 	"go.uber.org/zap"
 )
 
-type AudioBuffer struct {
-	buffer []byte
+// Microphone audio is decoded at this rate when it has to be processed
+const micSampleRate = 48000
+
+// Depth bounds of the remote audio jitter buffer
+const (
+	jitterMinDepth = 40 * time.Millisecond
+	jitterMaxDepth = 200 * time.Millisecond
+)
+
+// Playback reports how much of the remote audio has been received and played
+// by PlayRemoteAudio, and allows discarding audio that was not played yet.
+type Playback struct {
 	mu     sync.Mutex
-	cond   *sync.Cond
-	size   int
-	cap    int
+	output *Output
+	stream *OutputStream
+	jitter *JitterBuffer
+	gain   float64
+	muted  bool
+
+	canceller atomic.Pointer[EchoCanceller]
+}
+
+func NewPlayback() *Playback {
+	return &Playback{gain: 1}
+}
+
+func (p *Playback) attach(output *Output, stream *OutputStream, jitter *JitterBuffer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.output = output
+	p.stream = stream
+	p.jitter = jitter
+	stream.SetGain(p.gain)
+	stream.SetMuted(p.muted)
+	stream.OnRead(func(pcm []byte) {
+		if canceller := p.canceller.Load(); canceller != nil {
+			canceller.Reference(pcm, OutputChannels)
+		}
+	})
 }
 
-func NewAudioBuffer(fixedCap int) *AudioBuffer {
-	ab := &AudioBuffer{
-		buffer: make([]byte, 0, fixedCap),
-		size:   0,
-		cap:    fixedCap,
+func (p *Playback) state() (*Output, *OutputStream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.output, p.stream
+}
+
+// SetEchoReference makes the played audio the reference of the echo canceller.
+func (p *Playback) SetEchoReference(canceller *EchoCanceller) {
+	canceller.SetLatency(p.latency)
+	p.canceller.Store(canceller)
+}
+
+// latency returns how long the remote audio mixed now takes to be heard.
+func (p *Playback) latency() time.Duration {
+	output, _ := p.state()
+	if output == nil {
+		return 0
 	}
-	ab.cond = sync.NewCond(&ab.mu)
-	return ab
+	return output.Latency()
 }
 
-func (ab *AudioBuffer) Write(data []byte) (dropped int) {
-	ab.mu.Lock()
-	defer ab.mu.Unlock()
-	if ab.size+len(data) > ab.cap {
-		drop := ab.size + len(data) - ab.cap
-		ab.buffer = ab.buffer[drop:]
-		ab.size -= drop
-		dropped = drop
+// SetGain sets the linear gain of the remote audio, 1 by default.
+func (p *Playback) SetGain(gain float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gain = gain
+	if p.stream != nil {
+		p.stream.SetGain(gain)
 	}
-	ab.buffer = append(ab.buffer, data...)
-	ab.size += len(data)
-	ab.cond.Signal()
-	return dropped
 }
 
-func (ab *AudioBuffer) Read(p []byte) (n int, err error) {
-	ab.mu.Lock()
-	defer ab.mu.Unlock()
-	for ab.size == 0 {
-		ab.cond.Wait()
+func (p *Playback) SetMuted(muted bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.muted = muted
+	if p.stream != nil {
+		p.stream.SetMuted(muted)
 	}
-	n = copy(p, ab.buffer)
-	ab.buffer = ab.buffer[n:]
-	ab.size -= n
-	return n, nil
 }
 
-func StreamLocalAudio(ctx context.Context, logger shared.LoggerAdapter, track *webrtc.TrackLocalStaticSample, mediaTrack mediadevices.Track, frameDuration time.Duration) {
-	reader, err := mediaTrack.NewEncodedReader(track.Codec().MimeType)
+// Written returns the duration of remote audio queued for playback so far.
+// Audio discarded by Flush is not counted.
+func (p *Playback) Written() time.Duration {
+	_, stream := p.state()
+	if stream == nil {
+		return 0
+	}
+	return stream.Written()
+}
+
+// Played returns the duration of remote audio handed to the output device so far.
+func (p *Playback) Played() time.Duration {
+	output, stream := p.state()
+	if stream == nil {
+		return 0
+	}
+	return max(stream.Mixed()-output.Buffered(), 0)
+}
+
+// Flush discards the queued audio that was not mixed yet and returns its
+// duration. The output is shared, so audio already mixed is still played.
+// Packets waiting in the jitter buffer are discarded as well.
+func (p *Playback) Flush() time.Duration {
+	p.mu.Lock()
+	stream, jitter := p.stream, p.jitter
+	p.mu.Unlock()
+	if stream == nil {
+		return 0
+	}
+	jitter.Flush()
+	return stream.Flush()
+}
+
+// BufferStats returns the statistics of the buffer between the jitter buffer
+// and the output device.
+func (p *Playback) BufferStats() AudioBufferStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream == nil {
+		return AudioBufferStats{}
+	}
+	return p.stream.BufferStats()
+}
+
+// JitterStats returns the statistics of the remote audio jitter buffer.
+func (p *Playback) JitterStats() JitterStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jitter == nil {
+		return JitterStats{}
+	}
+	return p.jitter.Stats()
+}
+
+type MuteMode int
+
+const (
+	MuteModeDTX     MuteMode = iota // muted frames are replaced by empty Opus frames
+	MuteModeSilence                 // muted frames are replaced by encoded silence
+)
+
+// LocalAudioOptions are the optional settings of StreamLocalAudio.
+type LocalAudioOptions struct {
+	Gate     func() bool // frames captured while it returns false are not sent
+	Muted    func() bool // frames captured while it returns true are replaced
+	MuteMode MuteMode
+	VAD      *VADGate // only the frames it lets through are sent
+	Echo     *EchoProtection
+	Switch   *SourceSwitch // swaps the source while streaming
+	Sink     AudioSink     // receives the frames as sent, e.g. Recorder.User, left open
+}
+
+func (o LocalAudioOptions) needsPCM() bool {
+	return o.VAD != nil || (o.Echo != nil && o.Echo.needsPCM())
+}
+
+// StreamLocalAudio sends the audio source to the session. Frames of a
+// TrackSource are only decoded when an option has to process them.
+func StreamLocalAudio(ctx context.Context, logger shared.LoggerAdapter, track *webrtc.TrackLocalStaticSample, audioSource AudioSource, frameDuration time.Duration, opts LocalAudioOptions) {
+	mimeType := track.Codec().MimeType
+	source, err := newFrameSource(audioSource, mimeType, frameDuration)
 	if err != nil {
-		logger.Error("creating media track reader", err)
+		logger.Error("creating audio source", err)
 		return
 	}
+	defer func() { source.close() }()
+	var (
+		decoder *opus.Decoder
+		encoder *opus.Encoder
+		pcm     []int16
+		encoded []byte
+	)
+	if opts.needsPCM() {
+		decoder, err = opus.NewDecoder(micSampleRate, 1)
+		if err != nil {
+			logger.Error("creating Opus decoder", err)
+			return
+		}
+	}
+	if decoder != nil || opts.MuteMode == MuteModeSilence {
+		encoder, err = opus.NewEncoder(micSampleRate, 1, opus.AppVoIP)
+		if err != nil {
+			logger.Error("creating Opus encoder", err)
+			return
+		}
+		pcm = make([]int16, micSampleRate*120/1000) // longest Opus frame
+		encoded = make([]byte, 4000)
+	}
+	var (
+		sinkDecoder *opus.Decoder
+		sinkPCM     []int16
+	)
+	if opts.Sink != nil {
+		// The sent frames are decoded on their own, so the sink hears what the
+		// session hears
+		sinkDecoder, err = opus.NewDecoder(micSampleRate, 1)
+		if err != nil {
+			logger.Error("creating Opus decoder", err)
+			return
+		}
+		sinkPCM = make([]int16, micSampleRate*120/1000)
+	}
+	write := func(data []byte) {
+		err := track.WriteSample(media.Sample{
+			Data:     data,
+			Duration: frameDuration,
+		})
+		if err != nil {
+			logger.Error("failed to write sample to track", err)
+		}
+		if sinkDecoder == nil {
+			return
+		}
+		n, err := sinkDecoder.Decode(data, sinkPCM)
+		if err != nil {
+			logger.Error("decoding Opus", err)
+			return
+		}
+		err = opts.Sink.WriteFrame(AudioFrame{
+			Format: AudioFormat{SampleRate: micSampleRate, Channels: 1},
+			PCM:    sinkPCM[:n],
+			Opus:   data,
+		})
+		if err != nil {
+			logger.Error("writing to local audio sink", err)
+		}
+	}
+	open := func() bool {
+		return opts.Gate == nil || opts.Gate()
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 		}
-		buf, release, err := reader.Read()
+		next, err := opts.Switch.take(mimeType, frameDuration)
+		if err != nil {
+			logger.Error("swapping audio source", err)
+		} else if next != nil {
+			source.close()
+			source = next
+			logger.Info("audio source swapped")
+		}
+		frame, release, err := source.next()
 		if err != nil {
+			release()
+			if opts.Switch.pending() {
+				continue
+			}
 			if err == io.EOF {
-				release()
 				return
 			}
-			logger.Error("reading from media track", err)
+			logger.Error("reading from audio source", err)
+			continue
+		}
+		if len(frame) == 0 {
 			release()
 			continue
 		}
-		if buf.Samples == 0 {
+		if opts.Muted != nil && opts.Muted() {
+			if open() {
+				if opts.MuteMode == MuteModeSilence {
+					n := int(int64(micSampleRate) * int64(frameDuration) / int64(time.Second))
+					clear(pcm[:n])
+					if m, err := encoder.Encode(pcm[:n], encoded); err != nil {
+						logger.Error("encoding Opus", err)
+					} else {
+						write(encoded[:m])
+					}
+				} else {
+					// A TOC byte alone is an empty frame, decoded as silence
+					write([]byte{frame[0] & 0xFC})
+				}
+			}
 			release()
 			continue
 		}
-		err = track.WriteSample(media.Sample{
-			Data:     buf.Data[:],
-			Duration: frameDuration,
-		})
-		release()
+		if decoder == nil {
+			// Without a local VAD a flagged frame is sent as is
+			if drop, _ := opts.Echo.guard(); !drop && open() {
+				write(frame)
+			}
+			release()
+			continue
+		}
+		n, err := decoder.Decode(frame, pcm)
 		if err != nil {
-			logger.Error("failed to write sample to track", err)
+			logger.Error("decoding Opus", err)
+			release()
 			continue
 		}
+		var echo bool
+		if opts.Echo != nil {
+			modified, drop, flagged := opts.Echo.process(pcm[:n])
+			if drop {
+				release()
+				continue
+			}
+			if modified {
+				m, err := encoder.Encode(pcm[:n], encoded)
+				if err != nil {
+					logger.Error("encoding Opus", err)
+					release()
+					continue
+				}
+				frame = encoded[:m]
+			}
+			echo = flagged
+		}
+		frames, ended := [][]byte{frame}, func() {}
+		if opts.VAD != nil {
+			// The VAD sees every frame, so that it can open the gate itself
+			frames, ended = opts.VAD.process(pcm[:n], frame, frameDuration, echo)
+		}
+		if open() {
+			for _, f := range frames {
+				write(f)
+			}
+		}
+		// Ending the turn may close the gate, after the hangover is sent
+		ended()
+		release()
 	}
 }
 
-func PlayRemoteAudio(ctx context.Context, logger shared.LoggerAdapter, track *webrtc.TrackRemote, otoBufferMs, ringBufferSeconds int) {
-	var (
-		codec      = track.Codec()
-		sampleRate = int(codec.ClockRate)
-		channels   = int(codec.Channels)
-	)
-	logger.Info("playing remote audio",
-		zap.String("codec", codec.MimeType),
-		zap.Int("sampleRate", sampleRate),
-		zap.Int("channels", channels),
+// PlayRemoteAudio plays the remote track on the process-wide output, and on
+// the extra sinks if any. playback is optional and is used to observe and
+// control what is played. The extra sinks belong to the caller and are left
+// open, so that they can outlive the track, e.g. across reconnects.
+func PlayRemoteAudio(ctx context.Context, logger shared.LoggerAdapter, track *webrtc.TrackRemote, otoBufferMs, ringBufferSeconds int, playback *Playback, sinks ...AudioSink) {
+	format := AudioFormat{SampleRate: int(track.Codec().ClockRate), Channels: int(track.Codec().Channels)}
+	speaker, err := NewSpeakerSink(
+		format,
+		time.Duration(otoBufferMs)*time.Millisecond,
+		time.Duration(ringBufferSeconds)*time.Second,
 	)
-	decoder, err := opus.NewDecoder(sampleRate, channels)
 	if err != nil {
-		logger.Error("creating Opus decoder", err)
+		logger.Error("opening speaker sink", err)
 		return
 	}
+	jitter := NewJitterBuffer(format.SampleRate, jitterMinDepth, jitterMaxDepth)
+	if playback == nil {
+		playback = NewPlayback()
+	}
+	playback.attach(speaker.output, speaker.stream, jitter)
+	var sink AudioSink = speaker
+	if len(sinks) > 0 {
+		all := []AudioSink{speaker}
+		for _, s := range sinks {
+			all = append(all, borrowedSink{s})
+		}
+		sink = NewMultiSink(all...)
+	}
+	ReceiveRemoteAudio(ctx, logger, track, sink, jitter)
+}
 
-	otoCtx, ready, err := oto.NewContext(
-		&oto.NewContextOptions{
-			SampleRate:   sampleRate,
-			ChannelCount: channels,
-			Format:       oto.FormatSignedInt16LE,
-			BufferSize:   time.Duration(otoBufferMs) * time.Millisecond,
-		},
+// ReceiveRemoteAudio decodes the remote track and writes it to sink, which is
+// closed on return. Packets go through the jitter buffer, a default one if
+// nil; lost ones are recovered with Opus in-band FEC when possible and
+// concealed with PLC otherwise. When the track ends, the buffered packets are
+// played out before returning.
+func ReceiveRemoteAudio(ctx context.Context, logger shared.LoggerAdapter, track *webrtc.TrackRemote, sink AudioSink, jitter *JitterBuffer) {
+	var (
+		codec  = track.Codec()
+		format = AudioFormat{SampleRate: int(codec.ClockRate), Channels: int(codec.Channels)}
+	)
+	logger.Info("receiving remote audio",
+		zap.String("codec", codec.MimeType),
+		zap.Int("sampleRate", format.SampleRate),
+		zap.Int("channels", format.Channels),
 	)
+	defer func() {
+		if err := sink.Close(); err != nil {
+			logger.Error("closing audio sink", err)
+		}
+	}()
+	decoder, err := opus.NewDecoder(format.SampleRate, format.Channels)
 	if err != nil {
-		fmt.Printf("Oto context failed: %v\n", err)
+		logger.Error("creating Opus decoder", err)
 		return
 	}
-	audioBuffer := NewAudioBuffer(ringBufferSeconds * sampleRate * channels * 2)
-	pcm := make([]int16, int((float64(otoBufferMs)/1000)*float64(sampleRate))*channels)
+	if jitter == nil {
+		jitter = NewJitterBuffer(format.SampleRate, jitterMinDepth, jitterMaxDepth)
+	}
+	defer func() {
+		stats := jitter.Stats()
+		logger.Info("remote audio stats",
+			zap.Uint64("received", stats.Received),
+			zap.Uint64("late", stats.Late),
+			zap.Uint64("lost", stats.Lost),
+			zap.Uint64("recovered", stats.Recovered),
+			zap.Uint64("concealed", stats.Concealed),
+			zap.Uint64("reordered", stats.Reordered),
+			zap.Uint64("undecoded", stats.Undecoded),
+			zap.Duration("jitter", stats.Jitter),
+		)
+	}()
 
-	<-ready
-	player := otoCtx.NewPlayer(audioBuffer)
-	player.Play()
-	defer func() { _ = player.Close() }()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			rtp, _, err := track.ReadRTP()
+	// Receiving
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			packet, _, err := track.ReadRTP()
 			if err != nil {
 				if err != io.EOF {
 					logger.Error("reading RTP packet", err)
 				}
 				return
 			}
-			if len(rtp.Payload) == 0 {
+			if len(packet.Payload) == 0 {
 				logger.Error("empty RTP payload", nil)
 				continue
 			}
-			// Decode Opus to PCM 16-bit
-			n, err := decoder.Decode(rtp.Payload, pcm)
-			if err != nil {
-				logger.Error("decoding Opus", err)
-				continue
-			}
-			pcmSlice := pcm[:n*channels]
-			pcmBytes := make([]byte, len(pcmSlice)*2)
-			for i := range len(pcmSlice) {
-				binary.LittleEndian.PutUint16(pcmBytes[i*2:], uint16(pcmSlice[i]))
-			}
-			// Write to audioBuffer
-			dropped := audioBuffer.Write(pcmBytes)
-			if dropped > 0 {
-				logger.Warn("audio buffer dropped data", zap.Int("droppedBytes", dropped))
+			jitter.Push(packet, time.Now())
+		}
+	}()
+
+	// Playing out, on deadlines so that wake-up delays do not add up
+	pcm := make([]int16, format.SampleRate*120/1000*format.Channels) // longest Opus frame
+	deadline := time.Now().Add(jitter.FrameDuration())
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	ended := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			// The buffered packets are the tail of the last response
+			done, ended = nil, true
+			jitter.End()
+			continue
+		case <-timer.C:
+		}
+		frameDuration := jitter.FrameDuration()
+		deadline = deadline.Add(frameDuration)
+		if now := time.Now(); deadline.Before(now.Add(-jitterMaxDepth)) {
+			// Far behind, e.g. after the process was suspended
+			deadline = now.Add(frameDuration)
+		}
+		timer.Reset(time.Until(deadline))
+		packet, next, ready := jitter.Pop()
+		if !ready {
+			if ended {
+				return
 			}
+			continue
+		}
+		var (
+			n       int
+			payload []byte
+		)
+		switch {
+		case packet != nil:
+			payload = packet.Payload
+			n, err = decoder.Decode(payload, pcm)
+		case next != nil:
+			n = int(int64(format.SampleRate) * int64(frameDuration) / int64(time.Second))
+			err = decoder.DecodeFEC(next.Payload, pcm[:n*format.Channels])
+			jitter.Concealed(true)
+		default:
+			n = int(int64(format.SampleRate) * int64(frameDuration) / int64(time.Second))
+			err = decoder.DecodePLC(pcm[:n*format.Channels])
+			jitter.Concealed(false)
+		}
+		if err != nil {
+			logger.Error("decoding Opus", err)
+			jitter.DecodeFailed()
+			continue
+		}
+		err = sink.WriteFrame(AudioFrame{
+			Format: format,
+			PCM:    pcm[:n*format.Channels],
+			Opus:   payload,
+		})
+		if err != nil {
+			logger.Error("writing to audio sink", err)
 		}
 	}
 }
@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type AudioBufferStats struct {
+	Underruns    uint64 // times the buffer ran dry while audio was expected
+	Overruns     uint64 // writes that dropped buffered audio
+	Dropped      uint64 // bytes dropped by overruns
+	Silence      uint64 // bytes of silence read on underrun
+	Latency      time.Duration
+	MaxLatency   time.Duration
+	Fill         float64 // from 0 to 1
+	BufferedSize int     // bytes
+}
+
+// AudioBuffer is a ring buffer of interleaved 16-bit PCM. Reads and writes
+// are aligned on frames, so a sample is never split. When the buffer is full
+// the oldest frames are dropped.
+type AudioBuffer struct {
+	format    AudioFormat
+	frameSize int
+
+	mu      sync.Mutex
+	data    []byte
+	start   int
+	size    int
+	partial []byte // written bytes short of a frame
+	closed  bool
+	silence bool
+	playing bool // data was written since the last underrun
+	wake    chan struct{}
+	stats   AudioBufferStats
+}
+
+// NewAudioBuffer creates a buffer holding up to capacity of audio.
+func NewAudioBuffer(format AudioFormat, capacity time.Duration) *AudioBuffer {
+	frameSize := max(format.Channels, 1) * 2
+	frames := max(int(int64(format.SampleRate)*int64(capacity)/int64(time.Second)), 1)
+	return &AudioBuffer{
+		format:    format,
+		frameSize: frameSize,
+		data:      make([]byte, frames*frameSize),
+		partial:   make([]byte, 0, frameSize),
+		wake:      make(chan struct{}),
+	}
+}
+
+// SetSilenceOnUnderrun makes reads return at once, completed with silence
+// when not enough audio is buffered, as needed by real-time playback.
+func (ab *AudioBuffer) SetSilenceOnUnderrun(silence bool) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.silence = silence
+	ab.signal()
+}
+
+// signal wakes up the waiting readers, ab.mu must be held.
+func (ab *AudioBuffer) signal() {
+	close(ab.wake)
+	ab.wake = make(chan struct{})
+}
+
+// Write queues data and returns the number of bytes dropped, either buffered
+// audio that was overwritten or data written after Close.
+func (ab *AudioBuffer) Write(data []byte) (dropped int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if ab.closed {
+		return len(data)
+	}
+	size := ab.size
+	if len(ab.partial) > 0 {
+		n := min(ab.frameSize-len(ab.partial), len(data))
+		ab.partial = append(ab.partial, data[:n]...)
+		data = data[n:]
+		if len(ab.partial) == ab.frameSize {
+			dropped += ab.write(ab.partial)
+			ab.partial = ab.partial[:0]
+		}
+	}
+	whole := len(data) - len(data)%ab.frameSize
+	dropped += ab.write(data[:whole])
+	ab.partial = append(ab.partial, data[whole:]...)
+	if ab.size != size || dropped > 0 {
+		ab.playing = true
+		ab.signal()
+	}
+	return dropped
+}
+
+// write queues whole frames, ab.mu must be held.
+func (ab *AudioBuffer) write(data []byte) (dropped int) {
+	if len(data) == 0 {
+		return 0
+	}
+	if len(data) > len(ab.data) {
+		dropped += len(data) - len(ab.data)
+		data = data[len(data)-len(ab.data):]
+	}
+	if over := ab.size + len(data) - len(ab.data); over > 0 {
+		ab.start = (ab.start + over) % len(ab.data)
+		ab.size -= over
+		dropped += over
+	}
+	if dropped > 0 {
+		ab.stats.Overruns++
+		ab.stats.Dropped += uint64(dropped)
+	}
+	end := (ab.start + ab.size) % len(ab.data)
+	n := copy(ab.data[end:], data)
+	copy(ab.data, data[n:])
+	ab.size += len(data)
+	ab.stats.MaxLatency = max(ab.stats.MaxLatency, ab.latency())
+	return dropped
+}
+
+// read copies whole frames to p, ab.mu must be held.
+func (ab *AudioBuffer) read(p []byte) int {
+	n := min(len(p), ab.size)
+	n -= n % ab.frameSize
+	copied := copy(p[:n], ab.data[ab.start:])
+	copy(p[copied:n], ab.data)
+	ab.start = (ab.start + n) % len(ab.data)
+	ab.size -= n
+	return n
+}
+
+// underrun records that the buffer ran dry, once per dry spell. ab.mu must be
+// held.
+func (ab *AudioBuffer) underrun() {
+	if ab.playing {
+		ab.playing = false
+		ab.stats.Underruns++
+	}
+}
+
+// padSilence completes p with silence after n bytes, ab.mu must be held.
+func (ab *AudioBuffer) padSilence(p []byte, n int) int {
+	end := len(p) - len(p)%ab.frameSize
+	if n >= end {
+		return n
+	}
+	clear(p[n:end])
+	ab.underrun()
+	ab.stats.Silence += uint64(end - n)
+	return end
+}
+
+// Flush drops the buffered audio and returns its size in bytes.
+func (ab *AudioBuffer) Flush() (dropped int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	dropped = ab.size
+	ab.start = 0
+	ab.size = 0
+	ab.partial = ab.partial[:0]
+	ab.playing = false
+	return dropped
+}
+
+// TryRead reads what is available without waiting, whatever the silence
+// setting.
+func (ab *AudioBuffer) TryRead(p []byte) (n int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	n = ab.read(p)
+	if n < len(p)-len(p)%ab.frameSize {
+		ab.underrun()
+	}
+	return n
+}
+
+// Read waits for audio, see ReadContext.
+func (ab *AudioBuffer) Read(p []byte) (n int, err error) {
+	return ab.ReadContext(context.Background(), p)
+}
+
+// ReadContext waits until audio is buffered and reads it. Once the buffer is
+// closed, the remaining audio is read and io.EOF is returned. With silence on
+// underrun it does not wait and fills p with silence if needed.
+func (ab *AudioBuffer) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	if len(p) < ab.frameSize {
+		return 0, nil
+	}
+	for {
+		ab.mu.Lock()
+		if ab.size > 0 {
+			n = ab.read(p)
+			if ab.silence && !ab.closed {
+				n = ab.padSilence(p, n)
+			}
+			ab.mu.Unlock()
+			return n, nil
+		}
+		if ab.closed {
+			ab.mu.Unlock()
+			return 0, io.EOF
+		}
+		if ab.silence {
+			n = ab.padSilence(p, ab.read(p))
+			ab.mu.Unlock()
+			return n, nil
+		}
+		ab.underrun()
+		wake := ab.wake
+		ab.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Close wakes up the waiting readers, the buffered audio can still be read.
+func (ab *AudioBuffer) Close() error {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if !ab.closed {
+		ab.closed = true
+		ab.signal()
+	}
+	return nil
+}
+
+// Buffered returns the number of bytes that can be read.
+func (ab *AudioBuffer) Buffered() int {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.size
+}
+
+// Fill returns the buffered part of the capacity, from 0 to 1.
+func (ab *AudioBuffer) Fill() float64 {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return float64(ab.size) / float64(len(ab.data))
+}
+
+// Latency returns the duration of the buffered audio.
+func (ab *AudioBuffer) Latency() time.Duration {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.latency()
+}
+
+func (ab *AudioBuffer) latency() time.Duration {
+	return bytesDuration(int64(ab.size), int64(ab.format.SampleRate*ab.frameSize))
+}
+
+func (ab *AudioBuffer) Stats() AudioBufferStats {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	stats := ab.stats
+	stats.Latency = ab.latency()
+	stats.Fill = float64(ab.size) / float64(len(ab.data))
+	stats.BufferedSize = ab.size
+	return stats
+}
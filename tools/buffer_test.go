@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAudioBuffer(t *testing.T) {
+	stereo := AudioFormat{SampleRate: 1000, Channels: 2} // 4 bytes per frame
+
+	t.Run("keeps samples whole", func(t *testing.T) {
+		ab := NewAudioBuffer(stereo, 3*time.Millisecond) // 12 bytes
+		if dropped := ab.Write([]byte{1, 2, 3, 4, 5, 6}); dropped != 0 {
+			t.Errorf("Expected nothing dropped, got %d", dropped)
+		}
+		if n := ab.Buffered(); n != 4 {
+			t.Errorf("Expected the partial frame to be held back, got %d bytes", n)
+		}
+		ab.Write([]byte{7, 8})
+		if dropped := ab.Write([]byte{9, 10, 11, 12, 13, 14, 15, 16}); dropped != 4 {
+			t.Errorf("Expected the oldest frame to be dropped, got %d bytes", dropped)
+		}
+		p := make([]byte, 7)
+		if n := ab.TryRead(p); n != 4 || p[0] != 5 {
+			t.Errorf("Expected one whole frame starting at 5, got %d bytes %v", n, p[:n])
+		}
+		stats := ab.Stats()
+		if stats.Overruns != 1 || stats.Dropped != 4 || stats.BufferedSize != 8 {
+			t.Errorf("Expected one overrun of 4 bytes and 8 bytes left, got %+v", stats)
+		}
+		if stats.MaxLatency != 3*time.Millisecond || stats.Latency != 2*time.Millisecond {
+			t.Errorf("Expected a latency of 2ms at most 3ms, got %+v", stats)
+		}
+	})
+
+	t.Run("close ends reads", func(t *testing.T) {
+		ab := NewAudioBuffer(stereo, time.Second)
+		ab.Write([]byte{1, 2, 3, 4})
+		done := make(chan error)
+		go func() {
+			p := make([]byte, 8)
+			if _, err := ab.Read(p); err != nil {
+				done <- err
+				return
+			}
+			_, err := ab.Read(p)
+			done <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		_ = ab.Close()
+		select {
+		case err := <-done:
+			if err != io.EOF {
+				t.Errorf("Expected io.EOF, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected Close to wake up the reader")
+		}
+		if dropped := ab.Write([]byte{1, 2, 3, 4}); dropped != 4 {
+			t.Errorf("Expected writes after Close to be dropped, got %d", dropped)
+		}
+	})
+
+	t.Run("reads are cancellable", func(t *testing.T) {
+		ab := NewAudioBuffer(stereo, time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := ab.ReadContext(ctx, make([]byte, 4)); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline to be exceeded, got %v", err)
+		}
+	})
+
+	t.Run("silence on underrun", func(t *testing.T) {
+		ab := NewAudioBuffer(stereo, time.Second)
+		ab.SetSilenceOnUnderrun(true)
+		ab.Write([]byte{1, 1, 1, 1})
+		p := []byte{9, 9, 9, 9, 9, 9, 9, 9, 9}
+		n, err := ab.Read(p)
+		if err != nil || n != 8 || p[0] != 1 || p[4] != 0 || p[8] != 9 {
+			t.Errorf("Expected the frame followed by silence, got %d bytes %v (%v)", n, p, err)
+		}
+		ab.Read(p)
+		stats := ab.Stats()
+		if stats.Underruns != 1 || stats.Silence != 12 {
+			t.Errorf("Expected one underrun and 12 bytes of silence, got %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/pion/mediadevices"
+)
+
+type AudioDevice struct {
+	ID    string
+	Label string
+}
+
+// ListInputDevices lists the audio capture devices of the registered
+// mediadevices drivers, e.g. github.com/pion/mediadevices/pkg/driver/microphone.
+func ListInputDevices() []AudioDevice {
+	var devices []AudioDevice
+	for _, info := range mediadevices.EnumerateDevices() {
+		if info.Kind != mediadevices.AudioInput {
+			continue
+		}
+		devices = append(devices, AudioDevice{ID: info.DeviceID, Label: info.Label})
+	}
+	return devices
+}
+
+// FindInputDevice finds a capture device by ID or label. A label may also be
+// matched partially and case-insensitively, as long as only one device matches.
+func FindInputDevice(idOrLabel string) (AudioDevice, error) {
+	return findDevice(ListInputDevices(), idOrLabel)
+}
+
+func findDevice(devices []AudioDevice, idOrLabel string) (AudioDevice, error) {
+	for _, d := range devices {
+		if d.ID == idOrLabel {
+			return d, nil
+		}
+	}
+	for _, d := range devices {
+		if d.Label == idOrLabel {
+			return d, nil
+		}
+	}
+	var matches []AudioDevice
+	for _, d := range devices {
+		if strings.Contains(strings.ToLower(d.Label), strings.ToLower(idOrLabel)) {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return AudioDevice{}, fmt.Errorf("%w: %q", shared.ErrDeviceNotFound, idOrLabel)
+	case 1:
+		return matches[0], nil
+	default:
+		return AudioDevice{}, fmt.Errorf("%w: %q matches %d devices", shared.ErrAmbiguousDevice, idOrLabel, len(matches))
+	}
+}
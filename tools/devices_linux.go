@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SelectOutputDevice selects where PlayRemoteAudio plays, it must be called
+// before playback starts. On Linux the output goes through PulseAudio or
+// PipeWire, name is the sink name as listed by `pactl list short sinks`.
+//
+// The sink is selected with the PULSE_SINK environment variable, so it applies
+// to the whole process: every audio output it opens and the child processes it
+// starts afterwards. It cannot differ between two outputs of the process.
+func SelectOutputDevice(name string) error {
+	if name == "" {
+		return errors.New("device name is required")
+	}
+	if err := os.Setenv("PULSE_SINK", name); err != nil {
+		return fmt.Errorf("setting PULSE_SINK: %w", err)
+	}
+	return nil
+}
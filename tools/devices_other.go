@@ -0,0 +1,11 @@
+//go:build !linux
+
+package tools
+
+import "github.com/bridge-packages/go-openai-realtime/shared"
+
+// SelectOutputDevice is only supported on Linux, playback uses the default
+// output device elsewhere.
+func SelectOutputDevice(name string) error {
+	return shared.ErrOutputDeviceUnsupported
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+func TestFindDevice(t *testing.T) {
+	devices := []AudioDevice{
+		{ID: "alsa_input.usb-headset", Label: "USB Headset Mono"},
+		{ID: "alsa_input.pci-analog", Label: "Built-in Audio Analog Stereo"},
+		{ID: "alsa_input.pci-hdmi", Label: "Built-in Audio HDMI"},
+	}
+
+	t.Run("ByID", func(t *testing.T) {
+		d, err := findDevice(devices, "alsa_input.pci-hdmi")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if d != devices[2] {
+			t.Errorf("Expected %+v, got %+v", devices[2], d)
+		}
+	})
+
+	t.Run("ByLabel", func(t *testing.T) {
+		d, err := findDevice(devices, "Built-in Audio Analog Stereo")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if d != devices[1] {
+			t.Errorf("Expected %+v, got %+v", devices[1], d)
+		}
+	})
+
+	t.Run("PartialLabel", func(t *testing.T) {
+		d, err := findDevice(devices, "headset")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if d != devices[0] {
+			t.Errorf("Expected %+v, got %+v", devices[0], d)
+		}
+	})
+
+	t.Run("Ambiguous", func(t *testing.T) {
+		_, err := findDevice(devices, "built-in")
+		if !errors.Is(err, shared.ErrAmbiguousDevice) {
+			t.Errorf("Expected ErrAmbiguousDevice, got %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := findDevice(devices, "webcam")
+		if !errors.Is(err, shared.ErrDeviceNotFound) {
+			t.Errorf("Expected ErrDeviceNotFound, got %v", err)
+		}
+	})
+}
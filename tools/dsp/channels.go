@@ -0,0 +1,50 @@
+package dsp
+
+// ConvertChannels appends src with from channels to dst with to channels.
+// Downmixing to mono averages the channels, upmixing from mono copies it to
+// every channel. Between other layouts, channels are kept or dropped by
+// index and missing ones get the average.
+func ConvertChannels[T Sample](dst, src []T, from, to int) []T {
+	if from <= 0 || to <= 0 {
+		return dst
+	}
+	frames := len(src) / from
+	if from == to {
+		return append(dst, src[:frames*from]...)
+	}
+	dst = grow(dst, frames*to)
+	if from == 1 {
+		for _, v := range src[:frames] {
+			for range to {
+				dst = append(dst, v)
+			}
+		}
+		return dst
+	}
+	for f := range frames {
+		in := src[f*from : f*from+from]
+		var sum float64
+		for _, v := range in {
+			sum += float64(v)
+		}
+		avg := T(sum / float64(from))
+		for c := range to {
+			if to > 1 && from > 1 && c < from {
+				dst = append(dst, in[c])
+			} else {
+				dst = append(dst, avg)
+			}
+		}
+	}
+	return dst
+}
+
+// Downmix appends the mono mix of src to dst.
+func Downmix[T Sample](dst, src []T, channels int) []T {
+	return ConvertChannels(dst, src, channels, 1)
+}
+
+// Upmix appends mono src copied to every channel to dst.
+func Upmix[T Sample](dst, src []T, channels int) []T {
+	return ConvertChannels(dst, src, 1, channels)
+}
@@ -0,0 +1,75 @@
+package dsp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Int16ToFloat32 appends src scaled to [-1, 1) to dst.
+func Int16ToFloat32(dst []float32, src []int16) []float32 {
+	dst = grow(dst, len(src))
+	for _, v := range src {
+		dst = append(dst, float32(v)/32768)
+	}
+	return dst
+}
+
+// Float32ToInt16 appends src scaled to 16 bits to dst, out of range samples
+// are clipped.
+func Float32ToInt16(dst []int16, src []float32) []int16 {
+	dst = grow(dst, len(src))
+	for _, v := range src {
+		dst = append(dst, clip16(v*32768))
+	}
+	return dst
+}
+
+func clip16(v float32) int16 {
+	if v >= math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v <= math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+// Int16ToBytes appends src as little endian to dst.
+func Int16ToBytes(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src)*2)
+	for _, v := range src {
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(v))
+	}
+	return dst
+}
+
+// BytesToInt16 appends the little endian samples of src to dst, a trailing odd
+// byte is ignored.
+func BytesToInt16(dst []int16, src []byte) []int16 {
+	n := len(src) / 2
+	dst = grow(dst, n)
+	for i := range n {
+		dst = append(dst, int16(binary.LittleEndian.Uint16(src[i*2:])))
+	}
+	return dst
+}
+
+// Float32ToBytes appends src as little endian IEEE 754 to dst.
+func Float32ToBytes(dst []byte, src []float32) []byte {
+	dst = grow(dst, len(src)*4)
+	for _, v := range src {
+		dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(v))
+	}
+	return dst
+}
+
+// BytesToFloat32 appends the little endian IEEE 754 samples of src to dst,
+// trailing bytes are ignored.
+func BytesToFloat32(dst []float32, src []byte) []float32 {
+	n := len(src) / 4
+	dst = grow(dst, n)
+	for i := range n {
+		dst = append(dst, math.Float32frombits(binary.LittleEndian.Uint32(src[i*4:])))
+	}
+	return dst
+}
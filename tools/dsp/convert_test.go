@@ -0,0 +1,82 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	t.Run("int16 and float32", func(t *testing.T) {
+		pcm := []int16{0, 16384, -16384, math.MaxInt16, math.MinInt16}
+		f := Int16ToFloat32(nil, pcm)
+		if f[1] != 0.5 || f[4] != -1 {
+			t.Errorf("Expected samples scaled to [-1, 1), got %v", f)
+		}
+		back := Float32ToInt16(nil, f)
+		for i, v := range pcm {
+			if back[i] != v {
+				t.Errorf("Expected sample %d to be %d, got %d", i, v, back[i])
+			}
+		}
+		if clipped := Float32ToInt16(nil, []float32{2, -2}); clipped[0] != math.MaxInt16 || clipped[1] != math.MinInt16 {
+			t.Errorf("Expected samples to be clipped, got %v", clipped)
+		}
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		pcm := []int16{1, -2, 300}
+		b := Int16ToBytes(nil, pcm)
+		if len(b) != 6 || b[0] != 1 || b[2] != 0xFE || b[3] != 0xFF {
+			t.Errorf("Expected little endian bytes, got %v", b)
+		}
+		if back := BytesToInt16(nil, append(b, 7)); len(back) != 3 || back[1] != -2 || back[2] != 300 {
+			t.Errorf("Expected the samples back, got %v", back)
+		}
+		f := []float32{0.25, -1}
+		if back := BytesToFloat32(nil, Float32ToBytes(nil, f)); len(back) != 2 || back[0] != 0.25 || back[1] != -1 {
+			t.Errorf("Expected the float samples back, got %v", back)
+		}
+	})
+
+	t.Run("appends", func(t *testing.T) {
+		dst := make([]byte, 1, 16)
+		out := Int16ToBytes(dst, []int16{1})
+		if len(out) != 3 || &out[0] != &dst[0] {
+			t.Error("Expected the samples to be appended to dst in place")
+		}
+	})
+}
+
+func TestConvertChannels(t *testing.T) {
+	stereo := []int16{100, 300, -100, -300}
+	if mono := Downmix(nil, stereo, 2); len(mono) != 2 || mono[0] != 200 || mono[1] != -200 {
+		t.Errorf("Expected the average of the channels, got %v", mono)
+	}
+	if up := Upmix(nil, []int16{5, 6}, 2); len(up) != 4 || up[0] != 5 || up[1] != 5 || up[3] != 6 {
+		t.Errorf("Expected mono copied to both channels, got %v", up)
+	}
+	quad := ConvertChannels(nil, []float32{0.5, 0.25}, 2, 4)
+	if len(quad) != 4 || quad[0] != 0.5 || quad[1] != 0.25 || quad[2] != 0.375 {
+		t.Errorf("Expected the channels kept and the missing ones averaged, got %v", quad)
+	}
+}
+
+func BenchmarkInt16ToBytes(b *testing.B) {
+	in := make([]int16, 960)
+	var out []byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(in) * 2))
+	for b.Loop() {
+		out = Int16ToBytes(out[:0], in)
+	}
+}
+
+func BenchmarkConvertChannels(b *testing.B) {
+	in := make([]int16, 960)
+	var out []int16
+	b.ReportAllocs()
+	b.SetBytes(int64(len(in) * 2))
+	for b.Loop() {
+		out = ConvertChannels(out[:0], in, 1, 2)
+	}
+}
@@ -0,0 +1,21 @@
+// Package dsp provides audio conversions: sample-rate conversion, channel
+// mixing, sample format conversion and G.711 codecs.
+//
+// Functions append to a destination slice and return it, like append, so the
+// same buffers can be reused across calls without allocating. Samples are
+// interleaved when there are several channels.
+package dsp
+
+// Sample is a PCM sample type.
+type Sample interface {
+	~int16 | ~float32
+}
+
+func grow[T any](dst []T, n int) []T {
+	if cap(dst)-len(dst) < n {
+		grown := make([]T, len(dst), len(dst)+n)
+		copy(grown, dst)
+		return grown
+	}
+	return dst
+}
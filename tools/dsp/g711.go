@@ -0,0 +1,132 @@
+package dsp
+
+// G.711 μ-law and A-law, the 8 kHz codecs of telephony. Decoding goes through
+// tables, encoding follows the segment search of the reference implementation.
+
+var (
+	mulawTable [256]int16
+	alawTable  [256]int16
+)
+
+func init() {
+	for i := range 256 {
+		mulawTable[i] = decodeMulaw(byte(i))
+		alawTable[i] = decodeAlaw(byte(i))
+	}
+}
+
+const (
+	mulawBias = 0x84
+	mulawClip = 32635
+)
+
+// EncodeMulaw encodes one sample to μ-law.
+func EncodeMulaw(v int16) byte {
+	s := int(v)
+	sign := 0
+	if s < 0 {
+		s = -s
+		sign = 0x80
+	}
+	s = min(s, mulawClip) + mulawBias
+	exponent := 7
+	for mask := 0x4000; s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (s >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func decodeMulaw(b byte) int16 {
+	b = ^b
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b & 0x0F)
+	s := ((mantissa<<3)+mulawBias)<<exponent - mulawBias
+	if b&0x80 != 0 {
+		s = -s
+	}
+	return int16(s)
+}
+
+// DecodeMulaw decodes one μ-law sample.
+func DecodeMulaw(b byte) int16 {
+	return mulawTable[b]
+}
+
+// EncodeAlaw encodes one sample to A-law.
+func EncodeAlaw(v int16) byte {
+	s := int(v) >> 3 // A-law works on 13 bits
+	sign := 0x80
+	if s < 0 {
+		s = -s - 1
+		sign = 0
+	}
+	var b int
+	if s < 32 {
+		b = s >> 1
+	} else {
+		exponent := 1
+		for s >= 64<<(exponent-1) && exponent < 7 {
+			exponent++
+		}
+		b = exponent<<4 | (s>>exponent)&0x0F
+	}
+	return byte(sign|b) ^ 0x55
+}
+
+func decodeAlaw(b byte) int16 {
+	b ^= 0x55
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b & 0x0F)
+	var s int
+	if exponent == 0 {
+		s = mantissa<<4 + 8
+	} else {
+		s = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+	if b&0x80 == 0 {
+		s = -s
+	}
+	return int16(s)
+}
+
+// DecodeAlaw decodes one A-law sample.
+func DecodeAlaw(b byte) int16 {
+	return alawTable[b]
+}
+
+// MulawEncode appends src encoded to μ-law to dst.
+func MulawEncode(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src))
+	for _, v := range src {
+		dst = append(dst, EncodeMulaw(v))
+	}
+	return dst
+}
+
+// MulawDecode appends the decoded μ-law samples of src to dst.
+func MulawDecode(dst []int16, src []byte) []int16 {
+	dst = grow(dst, len(src))
+	for _, b := range src {
+		dst = append(dst, mulawTable[b])
+	}
+	return dst
+}
+
+// AlawEncode appends src encoded to A-law to dst.
+func AlawEncode(dst []byte, src []int16) []byte {
+	dst = grow(dst, len(src))
+	for _, v := range src {
+		dst = append(dst, EncodeAlaw(v))
+	}
+	return dst
+}
+
+// AlawDecode appends the decoded A-law samples of src to dst.
+func AlawDecode(dst []int16, src []byte) []int16 {
+	dst = grow(dst, len(src))
+	for _, b := range src {
+		dst = append(dst, alawTable[b])
+	}
+	return dst
+}
@@ -0,0 +1,87 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestG711(t *testing.T) {
+	t.Run("known values", func(t *testing.T) {
+		if b := EncodeMulaw(0); b != 0xFF {
+			t.Errorf("Expected μ-law silence to be 0xFF, got %#x", b)
+		}
+		if b := EncodeAlaw(0); b != 0xD5 {
+			t.Errorf("Expected A-law silence to be 0xD5, got %#x", b)
+		}
+		if v := DecodeMulaw(0x80); v != 32124 {
+			t.Errorf("Expected μ-law 0x80 to be 32124, got %d", v)
+		}
+		if v := DecodeAlaw(0xAA); v != 32256 {
+			t.Errorf("Expected A-law 0xAA to be 32256, got %d", v)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		codecs := map[string]struct {
+			encode func(int16) byte
+			decode func(byte) int16
+		}{
+			"μ-law": {EncodeMulaw, DecodeMulaw},
+			"A-law": {EncodeAlaw, DecodeAlaw},
+		}
+		for name, codec := range codecs {
+			for v := math.MinInt16; v <= math.MaxInt16; v += 7 {
+				got := int(codec.decode(codec.encode(int16(v))))
+				// Logarithmic quantization, the step grows with the level
+				if diff := abs(got - v); diff > max(abs(v)/16, 64) {
+					t.Fatalf("Expected %s to round trip %d, got %d", name, v, got)
+				}
+			}
+			for b := range 256 {
+				if again := codec.encode(codec.decode(byte(b))); codec.decode(again) != codec.decode(byte(b)) {
+					t.Fatalf("Expected %s code %#x to be stable, got %#x", name, b, again)
+				}
+			}
+		}
+	})
+
+	t.Run("slices", func(t *testing.T) {
+		pcm := []int16{0, 1000, -1000}
+		if got := MulawDecode(nil, MulawEncode(nil, pcm)); len(got) != 3 || got[0] != 0 {
+			t.Errorf("Expected 3 μ-law samples, got %v", got)
+		}
+		if got := AlawDecode(nil, AlawEncode(nil, pcm)); len(got) != 3 || got[1] <= 0 || got[2] >= 0 {
+			t.Errorf("Expected 3 A-law samples, got %v", got)
+		}
+	})
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func BenchmarkMulawEncode(b *testing.B) {
+	in := make([]int16, 160) // 20 ms at 8 kHz
+	for i := range in {
+		in[i] = int16(i * 200)
+	}
+	var out []byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(in) * 2))
+	for b.Loop() {
+		out = MulawEncode(out[:0], in)
+	}
+}
+
+func BenchmarkMulawDecode(b *testing.B) {
+	in := make([]byte, 160)
+	var out []int16
+	b.ReportAllocs()
+	b.SetBytes(int64(len(in)))
+	for b.Loop() {
+		out = MulawDecode(out[:0], in)
+	}
+}
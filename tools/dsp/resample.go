@@ -0,0 +1,185 @@
+package dsp
+
+import "math"
+
+// Quality trades the resampler passband and stopband for CPU.
+type Quality int
+
+const (
+	QualityLow    Quality = iota // 8 taps per side, for speech on constrained devices
+	QualityMedium                // 16 taps per side
+	QualityHigh                  // 32 taps per side, for music
+)
+
+func (q Quality) params() (taps int, beta float64) {
+	switch q {
+	case QualityLow:
+		return 8, 5
+	case QualityHigh:
+		return 32, 9
+	default:
+		return 16, 7
+	}
+}
+
+// resamplerPhases is the number of precomputed filter phases, coefficients in
+// between are interpolated linearly.
+const resamplerPhases = 128
+
+// Resampler converts interleaved audio between arbitrary sample rates with a
+// Kaiser windowed sinc filter. It is streaming: chunks of any size can be
+// processed, the filter state is kept across calls. Output is delayed by
+// Latency, Flush returns the delayed samples at the end of a stream.
+type Resampler struct {
+	channels int
+	step     float64 // input frames per output frame
+	half     int     // filter taps per side
+	table    []float32
+	buf      []float32 // input frames not consumed yet, starting half frames before pos
+	pos      float64   // position of the next output frame in buf
+	scratch  []float32
+	out      []float32
+}
+
+// NewResampler creates a resampler from one sample rate to another.
+func NewResampler(from, to, channels int, quality Quality) *Resampler {
+	r := &Resampler{
+		channels: max(channels, 1),
+		step:     float64(from) / float64(to),
+	}
+	if from == to {
+		return r
+	}
+	taps, beta := quality.params()
+	cutoff := 1.0
+	if r.step > 1 {
+		// Downsampling, the filter also removes what the new rate can not carry
+		cutoff = 1 / r.step
+		taps = int(math.Ceil(float64(taps) * r.step))
+	}
+	cutoff *= 0.97
+	r.half = taps
+	width := 2 * taps
+	r.table = make([]float32, (resamplerPhases+1)*width)
+	for p := range resamplerPhases + 1 {
+		frac := float64(p) / resamplerPhases
+		for k := range width {
+			x := float64(k-taps+1) - frac
+			r.table[p*width+k] = float32(cutoff * sinc(cutoff*x) * kaiser(x/float64(taps), beta))
+		}
+	}
+	r.Reset()
+	return r
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+func kaiser(x, beta float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// Reset drops the filter state, e.g. before a new stream.
+func (r *Resampler) Reset() {
+	if r.table == nil {
+		return
+	}
+	// Start with silence so that the first output frame is the first input frame
+	r.buf = append(r.buf[:0], make([]float32, (r.half-1)*r.channels)...)
+	r.pos = float64(r.half - 1)
+}
+
+// Latency returns the delay added by the filter, in input frames.
+func (r *Resampler) Latency() int {
+	return r.half
+}
+
+// Process appends src resampled to dst.
+func (r *Resampler) Process(dst, src []float32) []float32 {
+	if r.table == nil {
+		return append(dst, src[:len(src)/r.channels*r.channels]...)
+	}
+	r.buf = append(r.buf, src[:len(src)/r.channels*r.channels]...)
+	return r.drain(dst)
+}
+
+// Flush appends the samples still held by the filter to dst and resets it.
+func (r *Resampler) Flush(dst []float32) []float32 {
+	if r.table == nil {
+		return dst
+	}
+	r.buf = append(r.buf, make([]float32, r.half*r.channels)...)
+	dst = r.drain(dst)
+	r.Reset()
+	return dst
+}
+
+func (r *Resampler) drain(dst []float32) []float32 {
+	ch := r.channels
+	width := 2 * r.half
+	frames := len(r.buf) / ch
+	if n := int(math.Ceil((float64(frames-r.half) - r.pos) / r.step)); n > 0 {
+		dst = grow(dst, n*ch)
+	}
+	for {
+		i := int(r.pos)
+		if i+r.half >= frames {
+			break
+		}
+		fp := (r.pos - float64(i)) * resamplerPhases
+		p := int(fp)
+		t := float32(fp - float64(p))
+		lo := r.table[p*width : p*width+width]
+		hi := r.table[(p+1)*width : (p+1)*width+width]
+		in := r.buf[(i-r.half+1)*ch : (i+r.half+1)*ch]
+		for c := range ch {
+			var acc float32
+			for k := range width {
+				acc += in[k*ch+c] * (lo[k] + (hi[k]-lo[k])*t)
+			}
+			dst = append(dst, acc)
+		}
+		r.pos += r.step
+	}
+	// Keep the frames the next output frames still need
+	if drop := int(r.pos) - r.half + 1; drop > 0 {
+		drop = min(drop, frames)
+		r.buf = r.buf[:copy(r.buf, r.buf[drop*ch:])]
+		r.pos -= float64(drop)
+	}
+	return dst
+}
+
+// ProcessInt16 appends src resampled to dst.
+func (r *Resampler) ProcessInt16(dst, src []int16) []int16 {
+	if r.table == nil {
+		return append(dst, src[:len(src)/r.channels*r.channels]...)
+	}
+	r.scratch = Int16ToFloat32(r.scratch[:0], src)
+	r.out = r.Process(r.out[:0], r.scratch)
+	return Float32ToInt16(dst, r.out)
+}
+
+// FlushInt16 appends the samples still held by the filter to dst and resets
+// it.
+func (r *Resampler) FlushInt16(dst []int16) []int16 {
+	r.out = r.Flush(r.out[:0])
+	return Float32ToInt16(dst, r.out)
+}
@@ -0,0 +1,106 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(n, rate int, freq float64) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+func rms(x []float32) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
+
+func TestResampler(t *testing.T) {
+	t.Run("converts the length", func(t *testing.T) {
+		for _, rates := range [][2]int{{24000, 48000}, {48000, 24000}, {8000, 48000}, {48000, 8000}, {44100, 48000}} {
+			r := NewResampler(rates[0], rates[1], 1, QualityMedium)
+			out := r.Process(nil, sine(rates[0], rates[0], 440))
+			out = r.Flush(out)
+			if len(out) < rates[1]-1 || len(out) > rates[1]+1 {
+				t.Errorf("Expected about %d samples from %d to %d Hz, got %d", rates[1], rates[0], rates[1], len(out))
+			}
+		}
+	})
+
+	t.Run("keeps the passband", func(t *testing.T) {
+		r := NewResampler(24000, 48000, 1, QualityMedium)
+		out := r.Process(nil, sine(24000, 24000, 1000))
+		// Skip the filter warm-up
+		want := sine(len(out), 48000, 1000)
+		var maxErr float64
+		for i := 200; i < len(out)-200; i++ {
+			maxErr = max(maxErr, math.Abs(float64(out[i]-want[i])))
+		}
+		if maxErr > 0.01 {
+			t.Errorf("Expected the 1 kHz tone to be preserved, max error %.4f", maxErr)
+		}
+	})
+
+	t.Run("removes what the lower rate can not carry", func(t *testing.T) {
+		r := NewResampler(48000, 8000, 1, QualityMedium)
+		out := r.Process(nil, sine(48000, 48000, 6000))
+		if level := rms(out[100:]); level > 0.01 {
+			t.Errorf("Expected the 6 kHz tone to be filtered out, got an RMS of %.4f", level)
+		}
+	})
+
+	t.Run("streams in chunks", func(t *testing.T) {
+		in := sine(4800, 48000, 440)
+		whole := NewResampler(48000, 16000, 2, QualityLow).Process(nil, ConvertChannels(nil, in, 1, 2))
+		r := NewResampler(48000, 16000, 2, QualityLow)
+		var chunked []float32
+		stereo := ConvertChannels(nil, in, 1, 2)
+		for i := 0; i < len(stereo); i += 2 * 37 {
+			chunked = r.Process(chunked, stereo[i:min(i+2*37, len(stereo))])
+		}
+		if len(chunked) != len(whole) {
+			t.Fatalf("Expected %d samples, got %d", len(whole), len(chunked))
+		}
+		for i := range whole {
+			if math.Abs(float64(whole[i]-chunked[i])) > 1e-6 {
+				t.Fatalf("Expected sample %d to be %f, got %f", i, whole[i], chunked[i])
+			}
+		}
+	})
+
+	t.Run("passes through the same rate", func(t *testing.T) {
+		r := NewResampler(48000, 48000, 1, QualityHigh)
+		if out := r.ProcessInt16(nil, []int16{1, 2, 3}); len(out) != 3 || out[2] != 3 {
+			t.Errorf("Expected the samples unchanged, got %v", out)
+		}
+	})
+}
+
+func BenchmarkResampler(b *testing.B) {
+	for _, bc := range []struct {
+		name     string
+		from, to int
+	}{
+		{"24k-48k", 24000, 48000},
+		{"48k-24k", 48000, 24000},
+		{"8k-48k", 8000, 48000},
+		{"48k-8k", 48000, 8000},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := NewResampler(bc.from, bc.to, 1, QualityMedium)
+			in := make([]int16, bc.from/50) // 20 ms
+			var out []int16
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in) * 2))
+			for b.Loop() {
+				out = r.ProcessInt16(out[:0], in)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EchoMode int
+
+const (
+	EchoModeMute EchoMode = iota // mic frames are dropped
+	EchoModeDuck                 // mic frames are attenuated
+	EchoModeFlag                 // mic frames are sent, but never count as speech for the local VAD
+)
+
+// EchoProtection keeps the assistant voice played on the speakers from being
+// sent back through the microphone, which would make the model interrupt
+// itself. active tells whether the assistant may be audible, see
+// realtime.EchoGuard.
+type EchoProtection struct {
+	mode      EchoMode
+	active    func() bool
+	duckGain  float64
+	canceller *EchoCanceller // optional
+}
+
+func NewEchoProtection(mode EchoMode, active func() bool, canceller *EchoCanceller) *EchoProtection {
+	return &EchoProtection{
+		mode:      mode,
+		active:    active,
+		duckGain:  0.1,
+		canceller: canceller,
+	}
+}
+
+// SetDuckGain sets the gain applied to mic frames in duck mode, 0.1 by default.
+func (e *EchoProtection) SetDuckGain(gain float64) {
+	e.duckGain = min(max(gain, 0), 1)
+}
+
+// needsPCM reports whether mic frames have to be decoded.
+func (e *EchoProtection) needsPCM() bool {
+	return e.canceller != nil || e.mode == EchoModeDuck
+}
+
+// process applies the protection to the decoded mic frame in place. modified
+// is true when the frame has to be encoded again.
+func (e *EchoProtection) process(pcm []int16) (modified, drop, flagged bool) {
+	if e.canceller != nil {
+		e.canceller.Cancel(pcm)
+		modified = true
+	}
+	if e.mode == EchoModeDuck && e.audible() {
+		for i, s := range pcm {
+			pcm[i] = int16(float64(s) * e.duckGain)
+		}
+		return true, false, false
+	}
+	drop, flagged = e.guard()
+	return modified, drop, flagged
+}
+
+// guard applies the mute and flag modes, which do not need the decoded frame.
+// A nil protection lets every frame through.
+func (e *EchoProtection) guard() (drop, flagged bool) {
+	if e == nil || e.mode == EchoModeDuck || !e.audible() {
+		return false, false
+	}
+	return e.mode == EchoModeMute, e.mode == EchoModeFlag
+}
+
+func (e *EchoProtection) audible() bool {
+	return e.active != nil && e.active()
+}
+
+// EchoCanceller is a simple acoustic echo canceller, a normalized LMS filter
+// that estimates the echo of the played audio in the microphone and removes
+// it. Both signals must be at the microphone sample rate.
+type EchoCanceller struct {
+	step    float64
+	latency atomic.Pointer[func() time.Duration]
+
+	mu        sync.Mutex
+	weights   []float64
+	history   []float64 // the last taps reference samples twice, see Cancel
+	pos       int       // of the most recent reference sample in history
+	energy    float64   // of the last taps reference samples
+	reference []int16   // played but not yet matched with the microphone
+}
+
+// NewEchoCanceller creates a canceller whose filter covers taps samples of
+// echo path after the output latency, see SetLatency, e.g. 512 taps are about
+// 10 ms at the microphone sample rate. The cost per sample grows with taps,
+// longer echoes are better left to the tail of EchoProtection.
+func NewEchoCanceller(taps int) *EchoCanceller {
+	return &EchoCanceller{
+		step:    0.3,
+		weights: make([]float64, taps),
+		history: make([]float64, 2*taps),
+	}
+}
+
+// SetLatency sets how long the audio handed to the output takes to be heard,
+// e.g. Output.Latency. The reference is aligned with the microphone on it.
+func (c *EchoCanceller) SetLatency(latency func() time.Duration) {
+	c.latency.Store(&latency)
+}
+
+// Reference feeds the audio handed to the output device, as interleaved 16-bit
+// little endian PCM. Silence has to be fed too, the reference follows the
+// timeline of the output.
+func (c *EchoCanceller) Reference(pcm []byte, channels int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frame := channels * 2
+	for i := 0; i+frame <= len(pcm); i += frame {
+		var sum int
+		for ch := range channels {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[i+ch*2:])))
+		}
+		c.reference = append(c.reference, int16(sum/channels))
+	}
+	// Keep at most one second, the microphone is far behind otherwise
+	if over := len(c.reference) - micSampleRate; over > 0 {
+		c.reference = c.reference[over:]
+	}
+}
+
+// align moves the start of the reference to the sample heard when the first
+// of n microphone samples was captured, assuming they just were and the last
+// pending samples of the reference are not heard yet. Small misalignments are
+// left to the filter, moving the reference makes it converge again.
+func (c *EchoCanceller) align(n int, pending time.Duration) {
+	if len(c.reference) == 0 {
+		return
+	}
+	start := len(c.reference) - int(pending*micSampleRate/time.Second) - n
+	if tolerance := len(c.weights) / 4; start >= -tolerance && start <= tolerance {
+		return
+	}
+	if start > 0 {
+		c.reference = c.reference[min(start, len(c.reference)):]
+	} else {
+		c.reference = append(make([]int16, -start, len(c.reference)-start), c.reference...)
+	}
+}
+
+// Cancel removes the estimated echo from the microphone frame in place.
+func (c *EchoCanceller) Cancel(mic []int16) {
+	// Measured before locking, the output feeds the reference with its own
+	// locks held
+	var pending time.Duration
+	if latency := c.latency.Load(); latency != nil && *latency != nil {
+		pending = max((*latency)(), 0)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.align(len(mic), pending)
+	taps := len(c.weights)
+	for i, s := range mic {
+		var x float64
+		if len(c.reference) > 0 {
+			x = float64(c.reference[0]) / 32768
+			c.reference = c.reference[1:]
+		}
+		// history is a ring buffer written twice, so that the last taps
+		// samples are always contiguous, most recent first
+		c.pos = (c.pos + taps - 1) % taps
+		last := c.history[c.pos]
+		c.energy += x*x - last*last
+		c.history[c.pos], c.history[c.pos+taps] = x, x
+		window := c.history[c.pos : c.pos+taps]
+		var echo float64
+		for k, w := range c.weights {
+			echo += w * window[k]
+		}
+		e := float64(s)/32768 - echo
+		if c.energy > 1e-6 {
+			g := c.step * e / (c.energy + 1e-3)
+			for k := range c.weights {
+				c.weights[k] += g * window[k]
+			}
+		}
+		mic[i] = int16(min(max(e*32768, -32768), 32767))
+	}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestEchoCanceller(t *testing.T) {
+	const frame = 960
+	// cancel plays 50 frames of noise heard after latency, and returns the
+	// level of the echo before cancelling and in the last microphone frame
+	cancel := func(latency time.Duration) (before, after float64) {
+		c := NewEchoCanceller(64)
+		c.SetLatency(func() time.Duration { return latency })
+		delay := int(latency*micSampleRate/time.Second) + 10
+		// Noise, a tone would be cancelled at any delay
+		rng := rand.New(rand.NewPCG(1, 2))
+		far := make([]int16, 50*frame)
+		for i := range far {
+			far[i] = int16(rng.NormFloat64() * 0.2 * 32767)
+		}
+		before = -120
+		for f := range 50 {
+			played := far[f*frame : (f+1)*frame]
+			ref := make([]byte, len(played)*2)
+			for i, s := range played {
+				binary.LittleEndian.PutUint16(ref[i*2:], uint16(s))
+			}
+			c.Reference(ref, 1)
+			// The echo is the far signal, attenuated and delayed by 10
+			// samples after the output latency
+			mic := make([]int16, frame)
+			for i := range mic {
+				if j := f*frame + i - delay; j >= 0 {
+					mic[i] = int16(float64(far[j]) * 0.4)
+				}
+			}
+			before = max(before, levelDB(mic))
+			c.Cancel(mic)
+			if f == 49 {
+				after = levelDB(mic)
+			}
+		}
+		return before, after
+	}
+
+	t.Run("Direct", func(t *testing.T) {
+		if before, after := cancel(0); after > before-20 {
+			t.Errorf("Expected the echo to be reduced by 20 dB, got %.1f dB to %.1f dB", before, after)
+		}
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		// Far longer than the filter, only the alignment can catch it
+		if before, after := cancel(200 * time.Millisecond); after > before-20 {
+			t.Errorf("Expected the echo to be reduced by 20 dB, got %.1f dB to %.1f dB", before, after)
+		}
+	})
+}
+
+func TestEchoProtection(t *testing.T) {
+	active := true
+	e := NewEchoProtection(EchoModeDuck, func() bool { return active }, nil)
+	pcm := []int16{1000, -1000}
+	modified, drop, _ := e.process(pcm)
+	if !modified || drop || pcm[0] != 100 {
+		t.Errorf("Expected the frame to be ducked, got %v", pcm)
+	}
+	active = false
+	pcm = []int16{1000, -1000}
+	if modified, _, _ := e.process(pcm); modified || pcm[0] != 1000 {
+		t.Errorf("Expected the frame to be untouched, got %v", pcm)
+	}
+	e = NewEchoProtection(EchoModeMute, func() bool { return true }, nil)
+	if _, drop, _ := e.process(pcm); !drop {
+		t.Error("Expected the frame to be dropped")
+	}
+}
+
+func TestStreamLocalAudioEcho(t *testing.T) {
+	const frame = 20 * time.Millisecond
+	stream := func(mode EchoMode) int {
+		// The assistant speaks over the third and fourth frames
+		calls := 0
+		active := func() bool {
+			calls++
+			return calls == 3 || calls == 4
+		}
+		track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", "test")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		sink := &countingSink{}
+		StreamLocalAudio(context.Background(), shared.NewStdLogger(), track, NewToneSource(440, 0.3, 6*frame), frame, LocalAudioOptions{
+			Echo: NewEchoProtection(mode, active, nil),
+			Sink: sink,
+		})
+		if sink.closed {
+			t.Error("Expected the sink of the caller to be left open")
+		}
+		return sink.frames
+	}
+
+	t.Run("Mute", func(t *testing.T) {
+		if sent := stream(EchoModeMute); sent != 4 {
+			t.Errorf("Expected the frames played over to be dropped, got %d of 6 frames", sent)
+		}
+	})
+
+	t.Run("Flag", func(t *testing.T) {
+		if sent := stream(EchoModeFlag); sent != 6 {
+			t.Errorf("Expected every frame to be sent, got %d of 6 frames", sent)
+		}
+	})
+}
+
+type countingSink struct {
+	frames int
+	closed bool
+}
+
+func (s *countingSink) WriteFrame(AudioFrame) error {
+	s.frames++
+	return nil
+}
+
+func (s *countingSink) Close() error {
+	s.closed = true
+	return nil
+}
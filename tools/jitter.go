@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+type JitterStats struct {
+	Received   uint64
+	Late       uint64 // arrived after their play-out time
+	Duplicates uint64
+	Reordered  uint64 // arrived after a packet with a higher sequence number
+	Lost       uint64 // missing at their play-out time
+	Recovered  uint64 // lost packets recovered with in-band FEC
+	Concealed  uint64 // lost packets concealed with PLC
+	Skipped    uint64 // dropped to bring the buffer back to its target depth
+	Undecoded  uint64 // packets the decoder failed on
+	Jitter     time.Duration
+	Depth      time.Duration // target depth
+}
+
+// JitterBuffer reorders Opus RTP packets by sequence number and hands them out
+// at play-out time. Its depth follows the interarrival jitter (RFC 3550)
+// between a minimum and a maximum. The frame duration is read from the
+// packets, timestamps jump over DTX gaps and between responses.
+type JitterBuffer struct {
+	clockRate int
+	minDepth  time.Duration
+	maxDepth  time.Duration
+
+	mu           sync.Mutex
+	packets      map[uint16]*rtp.Packet
+	nextSeq      uint16
+	highestSeq   uint16
+	started      bool // nextSeq is known
+	playing      bool // the initial depth was reached
+	ended        bool // no more packets, the buffered ones are played out
+	frameSamples uint32
+	epoch        time.Time
+	transit      float64
+	jitter       float64 // in timestamp units
+	stats        JitterStats
+}
+
+func NewJitterBuffer(clockRate int, minDepth, maxDepth time.Duration) *JitterBuffer {
+	return &JitterBuffer{
+		clockRate:    clockRate,
+		minDepth:     minDepth,
+		maxDepth:     max(minDepth, maxDepth),
+		packets:      map[uint16]*rtp.Packet{},
+		frameSamples: uint32(clockRate / 50), // 20 ms until measured
+	}
+}
+
+// opusDuration returns the duration of an Opus packet from its TOC byte (RFC
+// 6716 section 3.1), 0 if it is not valid.
+func opusDuration(payload []byte) time.Duration {
+	if len(payload) == 0 {
+		return 0
+	}
+	toc := payload[0]
+	config := toc >> 3
+	var frame time.Duration
+	switch {
+	case config < 12: // SILK
+		frame = [...]time.Duration{10, 20, 40, 60}[config%4] * time.Millisecond
+	case config < 16: // hybrid
+		frame = [...]time.Duration{10, 20}[config%2] * time.Millisecond
+	default: // CELT
+		frame = 2500 * time.Microsecond << (config % 4)
+	}
+	frames := 1
+	switch toc & 3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(payload) < 2 {
+			return 0
+		}
+		frames = int(payload[1] & 0x3f)
+	}
+	if d := time.Duration(frames) * frame; d <= 120*time.Millisecond {
+		return d
+	}
+	return 0
+}
+
+// seqBefore reports whether a comes before b, with wrap-around.
+func seqBefore(a, b uint16) bool {
+	return a != b && b-a < 0x8000
+}
+
+func (j *JitterBuffer) Push(packet *rtp.Packet, arrival time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	seq := packet.SequenceNumber
+	j.stats.Received++
+	j.updateJitter(packet.Timestamp, arrival)
+	if !j.started {
+		j.started = true
+		j.nextSeq = seq
+		j.highestSeq = seq
+	}
+	if seqBefore(seq, j.nextSeq) {
+		j.stats.Late++
+		return
+	}
+	if _, ok := j.packets[seq]; ok {
+		j.stats.Duplicates++
+		return
+	}
+	if seqBefore(seq, j.highestSeq) {
+		j.stats.Reordered++
+	} else {
+		if d := opusDuration(packet.Payload); d > 0 {
+			j.frameSamples = uint32(int64(d) * int64(j.clockRate) / int64(time.Second))
+		}
+		j.highestSeq = seq
+	}
+	j.packets[seq] = packet
+}
+
+func (j *JitterBuffer) updateJitter(timestamp uint32, arrival time.Time) {
+	if j.epoch.IsZero() {
+		j.epoch = arrival
+	}
+	transit := arrival.Sub(j.epoch).Seconds()*float64(j.clockRate) - float64(timestamp)
+	if j.stats.Received > 1 {
+		d := transit - j.transit
+		if d < 0 {
+			d = -d
+		}
+		j.jitter += (d - j.jitter) / 16
+	}
+	j.transit = transit
+}
+
+func (j *JitterBuffer) frameDuration() time.Duration {
+	return time.Duration(j.frameSamples) * time.Second / time.Duration(j.clockRate)
+}
+
+func (j *JitterBuffer) targetDepth() time.Duration {
+	jitter := time.Duration(j.jitter * float64(time.Second) / float64(j.clockRate))
+	return min(max(j.minDepth, 3*jitter), j.maxDepth)
+}
+
+// FrameDuration returns the measured duration of a packet.
+func (j *JitterBuffer) FrameDuration() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.frameDuration()
+}
+
+// Pop is called once per frame duration. When ready is false nothing has to
+// be played: the buffer is filling up. Otherwise packet is the packet to play,
+// or nil if it was lost, in which case next is the following packet when it is
+// already buffered, so that the loss can be recovered from its FEC data.
+func (j *JitterBuffer) Pop() (packet, next *rtp.Packet, ready bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.packets) == 0 {
+		// The stream paused or stalled, fill up again before playing
+		j.playing = false
+		j.started = false
+		return nil, nil, false
+	}
+	buffered := time.Duration(len(j.packets)) * j.frameDuration()
+	target := j.targetDepth()
+	if !j.playing {
+		if buffered < target && !j.ended {
+			return nil, nil, false
+		}
+		j.playing = true
+	}
+	for buffered > 2*target+j.frameDuration() {
+		// Too much latency built up, e.g. after a burst
+		if _, ok := j.packets[j.nextSeq]; ok {
+			delete(j.packets, j.nextSeq)
+			buffered -= j.frameDuration()
+		}
+		j.nextSeq++
+		j.stats.Skipped++
+	}
+	packet, ok := j.packets[j.nextSeq]
+	if ok {
+		delete(j.packets, j.nextSeq)
+	} else {
+		j.stats.Lost++
+		next = j.packets[j.nextSeq+1]
+	}
+	j.nextSeq++
+	return packet, next, true
+}
+
+// Concealed records how a lost packet was concealed.
+func (j *JitterBuffer) Concealed(fec bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if fec {
+		j.stats.Recovered++
+	} else {
+		j.stats.Concealed++
+	}
+}
+
+// DecodeFailed counts a packet the decoder failed on.
+func (j *JitterBuffer) DecodeFailed() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stats.Undecoded++
+}
+
+// End marks the end of the stream, the buffered packets are played out
+// without waiting for the buffer to fill up.
+func (j *JitterBuffer) End() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.ended = true
+}
+
+// Flush drops the buffered packets, e.g. on barge-in.
+func (j *JitterBuffer) Flush() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	clear(j.packets)
+	j.playing = false
+	j.started = false
+}
+
+func (j *JitterBuffer) Stats() JitterStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stats := j.stats
+	stats.Jitter = time.Duration(j.jitter * float64(time.Second) / float64(j.clockRate))
+	stats.Depth = j.targetDepth()
+	return stats
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// A 20 ms CELT frame, the TOC byte is enough for the buffer
+const opus20ms = 0xf8
+
+func packet(seq uint16) *rtp.Packet {
+	return &rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: seq, Timestamp: uint32(seq) * 960},
+		Payload: []byte{opus20ms, byte(seq)},
+	}
+}
+
+func TestJitterBuffer(t *testing.T) {
+	j := NewJitterBuffer(48000, 40*time.Millisecond, 200*time.Millisecond)
+	start := time.Now()
+	push := func(seq uint16) {
+		j.Push(packet(seq), start.Add(time.Duration(seq)*20*time.Millisecond))
+	}
+
+	push(65534)
+	if _, _, ready := j.Pop(); ready {
+		t.Fatal("Expected the buffer to fill up first")
+	}
+	// 65535 arrives after 0 and 2 is lost
+	push(0)
+	push(65535)
+	push(1)
+	push(3)
+	var played []int
+	for range 5 {
+		p, next, ready := j.Pop()
+		switch {
+		case !ready:
+			played = append(played, -1)
+		case p == nil:
+			if next == nil || next.SequenceNumber != 3 {
+				t.Errorf("Expected the next packet for FEC, got %v", next)
+			}
+			played = append(played, -2)
+		default:
+			played = append(played, int(p.SequenceNumber))
+		}
+	}
+	want := []int{65534, 65535, 0, 1, -2}
+	for i := range want {
+		if played[i] != want[i] {
+			t.Fatalf("Expected play-out %v, got %v", want, played)
+		}
+	}
+	push(2)
+	stats := j.Stats()
+	if stats.Lost != 1 || stats.Late != 1 || stats.Reordered != 1 {
+		t.Errorf("Expected 1 lost, 1 late and 1 reordered packet, got %+v", stats)
+	}
+	if p, _, _ := j.Pop(); p == nil || p.SequenceNumber != 3 {
+		t.Errorf("Expected packet 3, got %v", p)
+	}
+}
+
+func TestJitterBufferFrameDuration(t *testing.T) {
+	j := NewJitterBuffer(48000, 40*time.Millisecond, 200*time.Millisecond)
+	start := time.Now()
+	j.Push(packet(0), start)
+	j.Push(packet(1), start.Add(20*time.Millisecond))
+	// The next response starts 2 s later on the timestamp clock
+	p := packet(2)
+	p.Timestamp += 2 * 48000
+	j.Push(p, start.Add(2*time.Second))
+	if d := j.FrameDuration(); d != 20*time.Millisecond {
+		t.Errorf("Expected the timestamp jump to be ignored, got %v", d)
+	}
+
+	for _, c := range []struct {
+		toc      []byte
+		expected time.Duration
+	}{
+		{[]byte{0x18}, 60 * time.Millisecond},       // SILK 60 ms
+		{[]byte{0xf1}, 20 * time.Millisecond},       // 2 CELT frames of 10 ms
+		{[]byte{0xeb, 0x06}, 30 * time.Millisecond}, // 6 CELT frames of 5 ms
+	} {
+		if d := opusDuration(c.toc); d != c.expected {
+			t.Errorf("Expected %x to last %v, got %v", c.toc, c.expected, d)
+		}
+	}
+	if d := opusDuration([]byte{0xfb, 0x3f}); d != 0 {
+		t.Errorf("Expected packets over 120 ms to be invalid, got %v", d)
+	}
+}
+
+func TestJitterBufferEnd(t *testing.T) {
+	j := NewJitterBuffer(48000, 40*time.Millisecond, 200*time.Millisecond)
+	j.Push(packet(0), time.Now())
+	if _, _, ready := j.Pop(); ready {
+		t.Fatal("Expected the buffer to fill up first")
+	}
+	j.End()
+	if p, _, ready := j.Pop(); !ready || p == nil || p.SequenceNumber != 0 {
+		t.Errorf("Expected the tail to be played out, got %v", p)
+	}
+	if _, _, ready := j.Pop(); ready {
+		t.Error("Expected nothing more to play")
+	}
+}
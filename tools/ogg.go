@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+const (
+	oggHeaderBOS = 0x02
+	oggHeaderEOS = 0x04
+	// The lookahead of libopus at 48 kHz, decoders drop it from the start
+	oggOpusPreSkip = 312
+	oggOpusVendor  = "go-openai-realtime"
+)
+
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggOpusWriter writes an Ogg Opus stream (RFC 7845) with one packet per page.
+// The last page is held back, so that close can mark it as the end of the
+// stream.
+type oggOpusWriter struct {
+	w       io.Writer
+	serial  uint32
+	page    uint32 // sequence number of the held back page
+	granule uint64 // at 48 kHz, including the pre-skip
+	held    []byte
+}
+
+func newOggOpusWriter(w io.Writer, sampleRate, channels int) (*oggOpusWriter, error) {
+	o := &oggOpusWriter{w: w, serial: rand.Uint32(), granule: oggOpusPreSkip}
+	head := []byte("OpusHead")
+	head = append(head, 1, byte(channels))
+	head = binary.LittleEndian.AppendUint16(head, oggOpusPreSkip)
+	head = binary.LittleEndian.AppendUint32(head, uint32(sampleRate))
+	head = binary.LittleEndian.AppendUint16(head, 0) // output gain
+	head = append(head, 0)                           // mono or stereo, no mapping table
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(oggOpusVendor)))
+	tags = append(tags, oggOpusVendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, 0) // no user comments
+	// The headers are alone on their pages, with granule 0
+	for i, header := range [][]byte{head, tags} {
+		var flags byte
+		if i == 0 {
+			flags = oggHeaderBOS
+		}
+		if err := o.hold(header, 0, flags); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// writePacket writes an Opus packet of samples at 48 kHz.
+func (o *oggOpusWriter) writePacket(packet []byte, samples int) error {
+	o.granule += uint64(samples)
+	return o.hold(packet, o.granule, 0)
+}
+
+// close writes the held back page as the last one.
+func (o *oggOpusWriter) close() error {
+	if o.held == nil {
+		return nil
+	}
+	o.held[5] |= oggHeaderEOS
+	err := o.flush()
+	o.held = nil
+	return err
+}
+
+// hold writes the page held back so far and holds back this one.
+func (o *oggOpusWriter) hold(packet []byte, granule uint64, flags byte) error {
+	var err error
+	if o.held != nil {
+		err = o.flush()
+		o.page++
+	}
+	segments := len(packet)/255 + 1
+	page := make([]byte, 0, 27+segments+len(packet))
+	page = append(page, "OggS"...)
+	page = append(page, 0, flags)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = binary.LittleEndian.AppendUint32(page, o.serial)
+	page = binary.LittleEndian.AppendUint32(page, o.page)
+	page = binary.LittleEndian.AppendUint32(page, 0) // CRC, set on flush
+	page = append(page, byte(segments))
+	for range segments - 1 {
+		page = append(page, 255)
+	}
+	page = append(page, byte(len(packet)%255))
+	o.held = append(page, packet...)
+	return err
+}
+
+func (o *oggOpusWriter) flush() error {
+	page := o.held
+	binary.LittleEndian.PutUint32(page[22:], 0)
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(page[22:], crc)
+	if _, err := o.w.Write(page); err != nil {
+		return fmt.Errorf("writing Ogg page: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+	"github.com/ebitengine/oto/v3"
+)
+
+// Format of the process-wide audio output, streams are converted to it.
+const (
+	OutputSampleRate = 48000
+	OutputChannels   = 2
+
+	outputBytesPerSecond = OutputSampleRate * OutputChannels * 2
+)
+
+var (
+	outputMu sync.Mutex
+	output   *Output
+	// newOtoContext is replaced in tests, there is no device to open
+	newOtoContext = oto.NewContext
+)
+
+// Output is the process-wide audio output. oto only allows one context per
+// process, so every session plays through the same one and its streams are
+// mixed together.
+type Output struct {
+	mixer
+	player *oto.Player
+	device time.Duration // buffer size of the device
+}
+
+// OpenOutput returns the process-wide output, it is opened on the first
+// successful call. bufferSize is the device buffer size, later calls do not
+// change it. A failed open is retried on the next call, unless oto refuses to
+// create another context.
+func OpenOutput(bufferSize time.Duration) (*Output, error) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if output != nil {
+		return output, nil
+	}
+	ctx, ready, err := newOtoContext(&oto.NewContextOptions{
+		SampleRate:   OutputSampleRate,
+		ChannelCount: OutputChannels,
+		Format:       oto.FormatSignedInt16LE,
+		BufferSize:   bufferSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating oto context: %w", err)
+	}
+	<-ready
+	o := &Output{device: bufferSize}
+	o.player = ctx.NewPlayer(&o.mixer)
+	// Keep the player buffer short, audio in it can not be flushed per stream
+	o.player.SetBufferSize(int(int64(outputBytesPerSecond)*int64(bufferSize)/int64(time.Second)) &^ 3)
+	o.player.Play()
+	output = o
+	return output, nil
+}
+
+// Buffered returns the duration of audio mixed but not played yet.
+func (o *Output) Buffered() time.Duration {
+	return bytesDuration(int64(o.player.BufferedSize()), outputBytesPerSecond)
+}
+
+// NewStream adds a stream of interleaved 16-bit PCM in the given format. Up
+// to bufferSize of audio is queued, older audio is dropped beyond that.
+func (o *Output) NewStream(sampleRate, channels int, bufferSize time.Duration) *OutputStream {
+	return o.newStream(sampleRate, channels, bufferSize)
+}
+
+// Latency returns how long the audio mixed now takes to be heard, through the
+// player and device buffers.
+func (o *Output) Latency() time.Duration {
+	return o.Buffered() + o.device
+}
+
+func bytesDuration(bytes, bytesPerSecond int64) time.Duration {
+	if bytesPerSecond == 0 || bytes <= 0 {
+		return 0
+	}
+	return time.Duration(bytes) * time.Second / time.Duration(bytesPerSecond)
+}
+
+// mixer is the io.Reader played by the output. It never blocks, missing audio
+// is played as silence.
+type mixer struct {
+	mu      sync.Mutex
+	streams []*OutputStream
+	mix     []int32
+	chunk   []byte
+}
+
+func (m *mixer) newStream(sampleRate, channels int, bufferSize time.Duration) *OutputStream {
+	s := &OutputStream{
+		mixer:     m,
+		buffer:    NewAudioBuffer(AudioFormat{SampleRate: OutputSampleRate, Channels: OutputChannels}, bufferSize),
+		channels:  channels,
+		resampler: dsp.NewResampler(sampleRate, OutputSampleRate, OutputChannels, dsp.QualityMedium),
+	}
+	s.gain.Store(math.Float64bits(1))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streams = append(m.streams, s)
+	return s
+}
+
+func (m *mixer) remove(s *OutputStream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, v := range m.streams {
+		if v == s {
+			m.streams = append(m.streams[:i], m.streams[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *mixer) Read(p []byte) (int, error) {
+	n := len(p) &^ 3 // whole frames only
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cap(m.mix) < n/2 {
+		m.mix = make([]int32, n/2)
+		m.chunk = make([]byte, n)
+	}
+	mix := m.mix[:n/2]
+	clear(mix)
+	for _, s := range m.streams {
+		read := s.buffer.TryRead(m.chunk[:n])
+		// What the stream adds to the mix, silence while it is empty or muted
+		muted := s.muted.Load()
+		if muted {
+			clear(m.chunk[:n])
+		} else {
+			clear(m.chunk[read:n])
+		}
+		s.consumed(read, m.chunk[:n])
+		if muted {
+			continue
+		}
+		gain := math.Float64frombits(s.gain.Load())
+		for i := 0; i+1 < read; i += 2 {
+			mix[i/2] += int32(float64(int16(binary.LittleEndian.Uint16(m.chunk[i:]))) * gain)
+		}
+	}
+	for i, v := range mix {
+		binary.LittleEndian.PutUint16(p[i*2:], uint16(int16(min(max(v, math.MinInt16), math.MaxInt16))))
+	}
+	return n, nil
+}
+
+// OutputStream is one source of the output, e.g. the remote audio of a session.
+type OutputStream struct {
+	mixer     *mixer
+	buffer    *AudioBuffer
+	channels  int
+	resampler *dsp.Resampler
+
+	writeMu   sync.Mutex
+	converted []int16
+	resampled []int16
+	data      []byte
+
+	gain    atomic.Uint64 // float64 bits
+	muted   atomic.Bool
+	onRead  atomic.Pointer[func([]byte)]
+	written atomic.Int64 // bytes in the output format
+	read    atomic.Int64
+}
+
+// Write queues interleaved PCM in the stream format and returns the number of
+// queued output bytes dropped because the stream buffer was full.
+func (s *OutputStream) Write(pcm []int16) (dropped int) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.converted = dsp.ConvertChannels(s.converted[:0], pcm, s.channels, OutputChannels)
+	s.resampled = s.resampler.ProcessInt16(s.resampled[:0], s.converted)
+	s.data = dsp.Int16ToBytes(s.data[:0], s.resampled)
+	dropped = s.buffer.Write(s.data)
+	s.written.Add(int64(len(s.data) - dropped))
+	return dropped
+}
+
+// Flush drops the queued audio and returns its duration.
+func (s *OutputStream) Flush() time.Duration {
+	dropped := int64(s.buffer.Flush())
+	s.written.Add(-dropped)
+	return bytesDuration(dropped, outputBytesPerSecond)
+}
+
+// Written returns the duration of audio queued so far, flushed audio excluded.
+func (s *OutputStream) Written() time.Duration {
+	return bytesDuration(s.written.Load(), outputBytesPerSecond)
+}
+
+// Mixed returns the duration of audio taken by the mixer so far.
+func (s *OutputStream) Mixed() time.Duration {
+	return bytesDuration(s.read.Load(), outputBytesPerSecond)
+}
+
+// SetGain sets the linear gain of the stream, 1 by default.
+func (s *OutputStream) SetGain(gain float64) {
+	s.gain.Store(math.Float64bits(max(gain, 0)))
+}
+
+func (s *OutputStream) SetMuted(muted bool) {
+	s.muted.Store(muted)
+}
+
+// OnRead sets a function called with what the stream adds to each read of the
+// mixer, in the output format: its audio, or silence while it is empty or
+// muted. It follows the timeline of the output, e.g. for an echo reference.
+func (s *OutputStream) OnRead(fn func(pcm []byte)) {
+	s.onRead.Store(&fn)
+}
+
+// BufferStats returns the statistics of the stream buffer, its underruns are
+// gaps in the playback.
+func (s *OutputStream) BufferStats() AudioBufferStats {
+	return s.buffer.Stats()
+}
+
+// Close removes the stream from the output.
+func (s *OutputStream) Close() {
+	s.mixer.remove(s)
+	s.buffer.Flush()
+	_ = s.buffer.Close()
+}
+
+func (s *OutputStream) consumed(read int, mixed []byte) {
+	s.read.Add(int64(read))
+	if fn := s.onRead.Load(); fn != nil && *fn != nil && len(mixed) > 0 {
+		(*fn)(mixed)
+	}
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebitengine/oto/v3"
+)
+
+func TestMixer(t *testing.T) {
+	m := &mixer{}
+	a := m.newStream(OutputSampleRate, 1, time.Second)
+	b := m.newStream(OutputSampleRate, 2, time.Second)
+	a.Write([]int16{1000, 1000})
+	b.Write([]int16{500, -500, 500, -500})
+	b.SetGain(2)
+
+	out := make([]byte, 12)
+	if n, _ := m.Read(out); n != 12 {
+		t.Fatalf("Expected 12 bytes, got %d", n)
+	}
+	want := []int16{2000, 0, 2000, 0, 0, 0}
+	for i, w := range want {
+		if got := int16(binary.LittleEndian.Uint16(out[i*2:])); got != w {
+			t.Errorf("Expected sample %d to be %d, got %d", i, w, got)
+		}
+	}
+	if a.Mixed() != a.Written() {
+		t.Errorf("Expected the stream to be fully mixed, got %v of %v", a.Mixed(), a.Written())
+	}
+
+	a.Write([]int16{30000})
+	b.Write([]int16{30000, 30000})
+	b.SetGain(1)
+	if _, _ = m.Read(out[:4]); int16(binary.LittleEndian.Uint16(out)) != 32767 {
+		t.Errorf("Expected the mix to be clipped, got %d", int16(binary.LittleEndian.Uint16(out)))
+	}
+
+	a.Close()
+	b.SetMuted(true)
+	b.Write([]int16{1000, 1000})
+	if _, _ = m.Read(out[:4]); binary.LittleEndian.Uint16(out) != 0 {
+		t.Error("Expected a muted stream to be silent")
+	}
+
+	// The stream follows the timeline of the output, silence included
+	var heard []byte
+	b.OnRead(func(pcm []byte) { heard = append(heard, pcm...) })
+	b.SetMuted(false)
+	mixed := b.Mixed()
+	_, _ = m.Read(out)
+	if len(heard) != len(out) || b.Mixed() != mixed {
+		t.Errorf("Expected %d bytes of silence, got %d bytes and %v mixed", len(out), len(heard), b.Mixed()-mixed)
+	}
+	for _, v := range heard {
+		if v != 0 {
+			t.Fatalf("Expected silence, got %v", heard)
+		}
+	}
+}
+
+func TestOpenOutputRetries(t *testing.T) {
+	defer func(f func(*oto.NewContextOptions) (*oto.Context, chan struct{}, error)) { newOtoContext = f }(newOtoContext)
+	attempts := 0
+	newOtoContext = func(*oto.NewContextOptions) (*oto.Context, chan struct{}, error) {
+		attempts++
+		return nil, nil, fmt.Errorf("no device %d", attempts)
+	}
+	for i := range 2 {
+		if _, err := OpenOutput(100 * time.Millisecond); err == nil || !strings.Contains(err.Error(), fmt.Sprintf("no device %d", i+1)) {
+			t.Errorf("Expected attempt %d to fail on its own, got %v", i+1, err)
+		}
+	}
+	if attempts != 2 {
+		t.Errorf("Expected the failed open to be retried, got %d attempts", attempts)
+	}
+}
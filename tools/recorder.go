@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+)
+
+type RecordingFormat int
+
+const (
+	RecordingOgg RecordingFormat = iota // the Opus frames as sent and received, only silences are encoded
+	RecordingWAV
+)
+
+const (
+	mixSampleRate = 48000
+	// Gaps shorter than this, e.g. network jitter, are not filled with silence
+	recordGapTolerance = 60 * time.Millisecond
+	// A side of the mix waits this long for the other one, which is then
+	// considered silent
+	recordMaxLag = 500 * time.Millisecond
+)
+
+type RecorderOptions struct {
+	Format RecordingFormat
+	// Mix also records a stereo WAV file with the user on the left and the
+	// assistant on the right.
+	Mix bool
+}
+
+// RecordingInfo is written to the JSON sidecar of a recording.
+type RecordingInfo struct {
+	StartedAt time.Time         `json:"started_at"`
+	EndedAt   time.Time         `json:"ended_at"`
+	CallID    string            `json:"call_id,omitempty"`
+	SessionID string            `json:"session_id,omitempty"`
+	Files     map[string]string `json:"files"`
+	Events    []RecordingEvent  `json:"events"`
+}
+
+type RecordingEvent struct {
+	OffsetMs   int64          `json:"offset_ms"`
+	Type       string         `json:"type"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Recorder records both directions of a session: User is a sink for the
+// frames sent, see LocalAudioOptions, Assistant one for the frames received,
+// see PlayRemoteAudio. Silences, e.g. while the microphone is gated, are
+// recorded so that both sides stay aligned on the wall clock.
+type Recorder struct {
+	dir  string
+	name string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	info      RecordingInfo
+	user      *recordSide
+	assistant *recordSide
+	mix       *stereoMix
+	closed    bool
+}
+
+// NewRecorder creates the files of the recording in dir, named after name or
+// the start time when it is empty.
+func NewRecorder(dir, name string, opts RecorderOptions) (*Recorder, error) {
+	return newRecorder(dir, name, opts, time.Now)
+}
+
+func newRecorder(dir, name string, opts RecorderOptions, now func() time.Time) (*Recorder, error) {
+	start := now()
+	if name == "" {
+		name = start.Format("20060102-150405")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating recording directory: %w", err)
+	}
+	r := &Recorder{
+		dir:  dir,
+		name: name,
+		now:  now,
+		info: RecordingInfo{
+			StartedAt: start,
+			Files:     map[string]string{},
+			Events:    []RecordingEvent{},
+		},
+	}
+	create := func(side string) (AudioSink, error) {
+		var (
+			file string
+			sink AudioSink
+			err  error
+		)
+		switch opts.Format {
+		case RecordingWAV:
+			file = fmt.Sprintf("%s-%s.wav", name, side)
+			sink, err = CreateWAVSink(filepath.Join(dir, file))
+		default:
+			file = fmt.Sprintf("%s-%s.ogg", name, side)
+			sink, err = CreateOggOpusSink(filepath.Join(dir, file))
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.info.Files[side] = file
+		return sink, nil
+	}
+	user, err := create("user")
+	if err != nil {
+		return nil, err
+	}
+	assistant, err := create("assistant")
+	if err != nil {
+		return nil, errors.Join(err, user.Close())
+	}
+	if opts.Mix {
+		file := name + "-mix.wav"
+		sink, err := CreateWAVSink(filepath.Join(dir, file))
+		if err != nil {
+			return nil, errors.Join(err, user.Close(), assistant.Close())
+		}
+		r.info.Files["mix"] = file
+		r.mix = &stereoMix{sink: sink}
+	}
+	r.user = &recordSide{recorder: r, file: user, channel: 0}
+	r.assistant = &recordSide{recorder: r, file: assistant, channel: 1}
+	r.user.other, r.assistant.other = r.assistant, r.user
+	return r, nil
+}
+
+// User returns the sink of the user side.
+func (r *Recorder) User() AudioSink {
+	return r.user
+}
+
+// Assistant returns the sink of the assistant side.
+func (r *Recorder) Assistant() AudioSink {
+	return r.assistant
+}
+
+func (r *Recorder) SetCallID(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.CallID = id
+}
+
+func (r *Recorder) SetSessionID(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info.SessionID = id
+}
+
+// Event records an event at the current offset of the recording.
+func (r *Recorder) Event(eventType string, attributes map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.info.Events = append(r.info.Events, RecordingEvent{
+		OffsetMs:   r.now().Sub(r.info.StartedAt).Milliseconds(),
+		Type:       eventType,
+		Attributes: attributes,
+	})
+}
+
+// Close finishes the files and writes the JSON sidecar.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.info.EndedAt = r.now()
+	var errs []error
+	if r.mix != nil {
+		errs = append(errs, r.mix.close())
+	}
+	errs = append(errs, r.user.close(), r.assistant.close())
+	data, err := json.MarshalIndent(r.info, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.dir, r.name+".json"), data, 0o644)
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("writing recording sidecar: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// recordSide is one direction of the recording.
+type recordSide struct {
+	recorder *Recorder
+	other    *recordSide
+	file     AudioSink
+	channel  int // in the mix
+	written  time.Duration
+	closed   bool
+}
+
+func (s *recordSide) WriteFrame(frame AudioFrame) error {
+	r := s.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.closed || r.closed {
+		return nil
+	}
+	dur := frame.Format.Duration(frame.PCM)
+	// The frame ends now, what is missing before it is silence
+	start := r.now().Sub(r.info.StartedAt) - dur
+	var errs []error
+	if gap := start - s.written; gap > recordGapTolerance {
+		silence := make([]int16, int(int64(gap)*int64(frame.Format.SampleRate)/int64(time.Second))*frame.Format.Channels)
+		errs = append(errs, s.file.WriteFrame(AudioFrame{Format: frame.Format, PCM: silence}))
+		s.written += frame.Format.Duration(silence)
+	}
+	errs = append(errs, s.file.WriteFrame(frame))
+	s.written += dur
+	if mix := r.mix; mix != nil {
+		mix.padTo(s.channel, start)
+		errs = append(errs, mix.write(s.channel, frame))
+		mix.padTo(s.other.channel, mix.position(s.channel)-recordMaxLag)
+		errs = append(errs, mix.flush(min(len(mix.pending[0]), len(mix.pending[1]))))
+	}
+	return errors.Join(errs...)
+}
+
+// Close finishes the file of the side, which is closed with the recorder
+// otherwise.
+func (s *recordSide) Close() error {
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+	return s.close()
+}
+
+func (s *recordSide) close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
+
+// stereoMix interleaves the two sides at mixSampleRate, each channel waits
+// for the other one.
+type stereoMix struct {
+	sink       *WAVSink
+	pending    [2][]int16
+	flushed    int // samples per channel
+	resamplers [2]*dsp.Resampler
+	rates      [2]int
+	mono       []int16
+	resampled  []int16
+	out        []int16
+}
+
+func (m *stereoMix) position(channel int) time.Duration {
+	return time.Duration(m.flushed+len(m.pending[channel])) * time.Second / mixSampleRate
+}
+
+// padTo appends silence to the channel up to offset when it is too far behind.
+func (m *stereoMix) padTo(channel int, offset time.Duration) {
+	if gap := offset - m.position(channel); gap > recordGapTolerance {
+		n := int(int64(gap) * mixSampleRate / int64(time.Second))
+		m.pending[channel] = append(m.pending[channel], make([]int16, n)...)
+	}
+}
+
+func (m *stereoMix) write(channel int, frame AudioFrame) error {
+	if frame.Format.Channels <= 0 {
+		return fmt.Errorf("invalid frame format %+v", frame.Format)
+	}
+	if m.resamplers[channel] == nil || m.rates[channel] != frame.Format.SampleRate {
+		m.resamplers[channel] = dsp.NewResampler(frame.Format.SampleRate, mixSampleRate, 1, dsp.QualityMedium)
+		m.rates[channel] = frame.Format.SampleRate
+	}
+	m.mono = dsp.Downmix(m.mono[:0], frame.PCM, frame.Format.Channels)
+	m.resampled = m.resamplers[channel].ProcessInt16(m.resampled[:0], m.mono)
+	m.pending[channel] = append(m.pending[channel], m.resampled...)
+	return nil
+}
+
+// flush writes n stereo samples, missing ones are silent.
+func (m *stereoMix) flush(n int) error {
+	if n == 0 {
+		return nil
+	}
+	m.out = m.out[:0]
+	for i := range n {
+		for c := range 2 {
+			var v int16
+			if i < len(m.pending[c]) {
+				v = m.pending[c][i]
+			}
+			m.out = append(m.out, v)
+		}
+	}
+	for c := range 2 {
+		m.pending[c] = m.pending[c][:copy(m.pending[c], m.pending[c][min(n, len(m.pending[c])):])]
+	}
+	m.flushed += n
+	return m.sink.WriteFrame(AudioFrame{
+		Format: AudioFormat{SampleRate: mixSampleRate, Channels: 2},
+		PCM:    m.out,
+	})
+}
+
+func (m *stereoMix) close() error {
+	err := m.flush(max(len(m.pending[0]), len(m.pending[1])))
+	return errors.Join(err, m.sink.Close())
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	r, err := newRecorder(dir, "call", RecorderOptions{Format: RecordingWAV, Mix: true}, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	r.SetCallID("rtc_1")
+	r.SetSessionID("sess_1")
+
+	mono := AudioFormat{SampleRate: 48000, Channels: 1}
+	stereo := AudioFormat{SampleRate: 48000, Channels: 2}
+	// The user speaks for 20 ms, the assistant answers 1 s later
+	clock = clock.Add(20 * time.Millisecond)
+	if err := r.User().WriteFrame(AudioFrame{Format: mono, PCM: tone(960, 0.3)}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clock = clock.Add(time.Second)
+	r.Event("response.created", map[string]any{"event_id": "event_1"})
+	clock = clock.Add(20 * time.Millisecond)
+	if err := r.Assistant().WriteFrame(AudioFrame{Format: stereo, PCM: make([]int16, 1920)}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := r.Assistant().Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	wavDuration := func(file string) time.Duration {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil || len(data) < 44 {
+			t.Fatalf("Expected a WAV file, got %v", err)
+		}
+		rate := binary.LittleEndian.Uint32(data[24:])
+		align := binary.LittleEndian.Uint16(data[32:])
+		size := binary.LittleEndian.Uint32(data[40:])
+		return time.Duration(size/uint32(align)) * time.Second / time.Duration(rate)
+	}
+	t.Run("sides are aligned on the wall clock", func(t *testing.T) {
+		if d := wavDuration("call-user.wav"); d != 20*time.Millisecond {
+			t.Errorf("Expected 20ms of user audio, got %v", d)
+		}
+		if d := wavDuration("call-assistant.wav"); d < 1030*time.Millisecond || d > 1050*time.Millisecond {
+			t.Errorf("Expected the assistant audio to start after 1s of silence, got %v", d)
+		}
+		if d := wavDuration("call-mix.wav"); d < 1030*time.Millisecond || d > 1050*time.Millisecond {
+			t.Errorf("Expected the mix to cover both sides, got %v", d)
+		}
+	})
+
+	t.Run("sidecar", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "call.json"))
+		if err != nil {
+			t.Fatalf("Expected a sidecar, got %v", err)
+		}
+		var info RecordingInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			t.Fatalf("Expected valid JSON, got %v", err)
+		}
+		if info.CallID != "rtc_1" || info.SessionID != "sess_1" {
+			t.Errorf("Expected the call and session IDs, got %+v", info)
+		}
+		if len(info.Events) != 1 || info.Events[0].OffsetMs != 1020 || info.Events[0].Type != "response.created" {
+			t.Errorf("Expected the event at 1020ms, got %+v", info.Events)
+		}
+		if info.Files["mix"] != "call-mix.wav" || info.EndedAt.Sub(info.StartedAt) != 1040*time.Millisecond {
+			t.Errorf("Expected the files and the duration, got %+v", info)
+		}
+	})
+}
+
+func TestRecorderOgg(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	r, err := newRecorder(dir, "call", RecorderOptions{Format: RecordingOgg}, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	packets, err := EncodeOpusFrames(NewToneSource(440, 0.3, 20*time.Millisecond), 20*time.Millisecond)
+	if err != nil || len(packets) != 1 {
+		t.Fatalf("Expected an Opus packet, got %v", err)
+	}
+
+	mono := AudioFormat{SampleRate: 48000, Channels: 1}
+	stereo := AudioFormat{SampleRate: 48000, Channels: 2}
+	// The user speaks after 500 ms, the assistant answers 1 s after the start
+	clock = clock.Add(520 * time.Millisecond)
+	if err := r.User().WriteFrame(AudioFrame{Format: mono, PCM: tone(960, 0.3), Opus: packets[0]}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clock = clock.Add(500 * time.Millisecond)
+	if err := r.Assistant().WriteFrame(AudioFrame{Format: stereo, PCM: make([]int16, 1920), Opus: packets[0]}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// duration reads the granule position of the last page
+	duration := func(file string) time.Duration {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("Expected an Ogg file, got %v", err)
+		}
+		pages := readOggPages(t, data)
+		// Silences are packets too, the granule never jumps
+		granule := uint64(oggOpusPreSkip)
+		for i, p := range pages[2:] {
+			if step := p.granule - granule; step == 0 || step > 960 {
+				t.Fatalf("Expected a packet of at most 20ms on page %d of %s, got %d samples", i+2, file, step)
+			}
+			granule = p.granule
+		}
+		last := pages[len(pages)-1]
+		if last.flags&oggHeaderEOS == 0 {
+			t.Errorf("Expected the last page of %s to end the stream", file)
+		}
+		return time.Duration(last.granule-oggOpusPreSkip) * time.Second / 48000
+	}
+	if d := duration("call-user.ogg"); d != 520*time.Millisecond {
+		t.Errorf("Expected the user audio to start after 500ms of silence, got %v", d)
+	}
+	if d := duration("call-assistant.ogg"); d != 1020*time.Millisecond {
+		t.Errorf("Expected the assistant audio to start after 1s of silence, got %v", d)
+	}
+}
@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+)
+
+// AudioFormat describes interleaved 16-bit PCM.
+type AudioFormat struct {
+	SampleRate int
+	Channels   int
+}
+
+// Samples returns the number of samples per channel in pcm.
+func (f AudioFormat) Samples(pcm []int16) int {
+	if f.Channels == 0 {
+		return 0
+	}
+	return len(pcm) / f.Channels
+}
+
+func (f AudioFormat) Duration(pcm []int16) time.Duration {
+	if f.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(f.Samples(pcm)) * time.Second / time.Duration(f.SampleRate)
+}
+
+// AudioFrame is a decoded frame of remote audio. PCM and Opus are only valid
+// during the WriteFrame call.
+type AudioFrame struct {
+	Format AudioFormat
+	PCM    []int16
+	Opus   []byte // the received packet, nil when the frame was concealed
+}
+
+// AudioSink consumes remote audio, see ReceiveRemoteAudio.
+type AudioSink interface {
+	WriteFrame(frame AudioFrame) error
+	Close() error
+}
+
+// SinkFunc adapts a function to an AudioSink.
+type SinkFunc func(frame AudioFrame) error
+
+func (f SinkFunc) WriteFrame(frame AudioFrame) error {
+	return f(frame)
+}
+
+func (f SinkFunc) Close() error {
+	return nil
+}
+
+// ChannelSink hands copies of the frames to a channel. Frames are dropped
+// when the channel is full, so a slow consumer never stalls playback.
+type ChannelSink struct {
+	frames  chan AudioFrame
+	dropped atomic.Uint64
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewChannelSink(size int) *ChannelSink {
+	return &ChannelSink{frames: make(chan AudioFrame, size)}
+}
+
+// Frames is closed when the sink is closed.
+func (s *ChannelSink) Frames() <-chan AudioFrame {
+	return s.frames
+}
+
+func (s *ChannelSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// WriteFrame returns shared.ErrSinkClosed once the sink is closed, e.g. when
+// the playout is still finishing.
+func (s *ChannelSink) WriteFrame(frame AudioFrame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return shared.ErrSinkClosed
+	}
+	frame.PCM = append([]int16(nil), frame.PCM...)
+	if frame.Opus != nil {
+		frame.Opus = append([]byte(nil), frame.Opus...)
+	}
+	select {
+	case s.frames <- frame:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+func (s *ChannelSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.frames)
+	}
+	return nil
+}
+
+// MultiSink writes every frame to several sinks.
+type MultiSink struct {
+	sinks []AudioSink
+}
+
+func NewMultiSink(sinks ...AudioSink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// WriteFrame writes to all the sinks even if some fail.
+func (m *MultiSink) WriteFrame(frame AudioFrame) error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.WriteFrame(frame); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiSink) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// borrowedSink leaves the sink open on Close, it belongs to the caller.
+type borrowedSink struct {
+	AudioSink
+}
+
+func (borrowedSink) Close() error {
+	return nil
+}
+
+// WriterSink writes raw 16-bit little endian PCM to an io.Writer. The writer
+// is closed with the sink when it is an io.Closer.
+type WriterSink struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) WriteFrame(frame AudioFrame) error {
+	s.buf = dsp.Int16ToBytes(s.buf[:0], frame.PCM)
+	if _, err := s.w.Write(s.buf); err != nil {
+		return fmt.Errorf("writing PCM: %w", err)
+	}
+	return nil
+}
+
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SpeakerSink plays frames on the process-wide output.
+type SpeakerSink struct {
+	output *Output
+	stream *OutputStream
+	format AudioFormat
+}
+
+// NewSpeakerSink opens the output, see OpenOutput, and adds a stream of the
+// given format to it. Up to queue of audio is buffered.
+func NewSpeakerSink(format AudioFormat, deviceBuffer, queue time.Duration) (*SpeakerSink, error) {
+	output, err := OpenOutput(deviceBuffer)
+	if err != nil {
+		return nil, err
+	}
+	return &SpeakerSink{
+		output: output,
+		stream: output.NewStream(format.SampleRate, format.Channels, queue),
+		format: format,
+	}, nil
+}
+
+func (s *SpeakerSink) Stream() *OutputStream {
+	return s.stream
+}
+
+func (s *SpeakerSink) WriteFrame(frame AudioFrame) error {
+	if frame.Format != s.format {
+		return fmt.Errorf("unexpected format %+v, the speaker stream is %+v", frame.Format, s.format)
+	}
+	s.stream.Write(frame.PCM)
+	return nil
+}
+
+func (s *SpeakerSink) Close() error {
+	s.stream.Close()
+	return nil
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+	"github.com/hraban/opus"
+)
+
+// WAVSink writes frames to a WAV file. The format is taken from the first
+// frame, the header sizes are written on Close.
+type WAVSink struct {
+	w      io.WriteSeeker
+	format AudioFormat
+	size   uint32
+	buf    []byte
+}
+
+func NewWAVSink(w io.WriteSeeker) *WAVSink {
+	return &WAVSink{w: w}
+}
+
+// CreateWAVSink creates the file at path.
+func CreateWAVSink(path string) (*WAVSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating WAV file: %w", err)
+	}
+	return NewWAVSink(f), nil
+}
+
+func (s *WAVSink) WriteFrame(frame AudioFrame) error {
+	if s.format == (AudioFormat{}) {
+		s.format = frame.Format
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if frame.Format != s.format {
+		return fmt.Errorf("unexpected format %+v, the WAV file is %+v", frame.Format, s.format)
+	}
+	s.buf = dsp.Int16ToBytes(s.buf[:0], frame.PCM)
+	if _, err := s.w.Write(s.buf); err != nil {
+		return fmt.Errorf("writing WAV data: %w", err)
+	}
+	s.size += uint32(len(s.buf))
+	return nil
+}
+
+func (s *WAVSink) writeHeader() error {
+	var (
+		channels   = uint16(s.format.Channels)
+		sampleRate = uint32(s.format.SampleRate)
+		blockAlign = channels * 2
+	)
+	h := make([]byte, 0, 44)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, 36+s.size)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)
+	h = binary.LittleEndian.AppendUint16(h, 1) // PCM
+	h = binary.LittleEndian.AppendUint16(h, channels)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate*uint32(blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, blockAlign)
+	h = binary.LittleEndian.AppendUint16(h, 16)
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, s.size)
+	if _, err := s.w.Write(h); err != nil {
+		return fmt.Errorf("writing WAV header: %w", err)
+	}
+	return nil
+}
+
+func (s *WAVSink) Close() error {
+	var err error
+	if s.format != (AudioFormat{}) {
+		if _, err = s.w.Seek(0, io.SeekStart); err == nil {
+			err = s.writeHeader()
+		}
+	}
+	if c, ok := s.w.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// OggOpusSink writes the received Opus packets to an Ogg file without
+// decoding them again. Frames without a packet, e.g. gaps of a recording or
+// concealed frames, are encoded so that the file keeps the wall clock. The
+// last page is marked as the end of the stream on Close.
+type OggOpusSink struct {
+	w       io.Writer
+	ogg     *oggOpusWriter
+	encoder *opus.Encoder
+	pcm     []int16 // not encoded yet, shorter than an Opus frame
+	encoded []byte
+}
+
+func NewOggOpusSink(w io.Writer) *OggOpusSink {
+	return &OggOpusSink{w: w}
+}
+
+// CreateOggOpusSink creates the file at path.
+func CreateOggOpusSink(path string) (*OggOpusSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating Ogg file: %w", err)
+	}
+	return NewOggOpusSink(f), nil
+}
+
+func (s *OggOpusSink) WriteFrame(frame AudioFrame) error {
+	if frame.Format.SampleRate <= 0 || frame.Format.Channels <= 0 {
+		return fmt.Errorf("invalid frame format %+v", frame.Format)
+	}
+	if s.ogg == nil {
+		ogg, err := newOggOpusWriter(s.w, frame.Format.SampleRate, frame.Format.Channels)
+		if err != nil {
+			return fmt.Errorf("creating Ogg writer: %w", err)
+		}
+		s.ogg = ogg
+	}
+	if frame.Opus == nil {
+		return s.encode(frame)
+	}
+	// Granule positions are always counted at 48 kHz in Ogg Opus
+	return s.ogg.writePacket(frame.Opus, frame.Format.Samples(frame.PCM)*48000/frame.Format.SampleRate)
+}
+
+// encode writes the PCM of the frame as Opus packets of 20 ms down to 2.5 ms,
+// the rest is kept for the next frame.
+func (s *OggOpusSink) encode(frame AudioFrame) error {
+	if s.encoder == nil {
+		encoder, err := opus.NewEncoder(frame.Format.SampleRate, frame.Format.Channels, opus.AppVoIP)
+		if err != nil {
+			return fmt.Errorf("creating Opus encoder: %w", err)
+		}
+		s.encoder = encoder
+		s.encoded = make([]byte, 4000)
+	}
+	s.pcm = append(s.pcm, frame.PCM...)
+	channels := frame.Format.Channels
+	unit := frame.Format.SampleRate / 400 // 2.5 ms
+	pcm := s.pcm
+	for _, n := range []int{8 * unit, 4 * unit, 2 * unit, unit} {
+		for len(pcm) >= n*channels {
+			m, err := s.encoder.Encode(pcm[:n*channels], s.encoded)
+			if err != nil {
+				return fmt.Errorf("encoding Opus: %w", err)
+			}
+			if err := s.ogg.writePacket(s.encoded[:m], n*48000/frame.Format.SampleRate); err != nil {
+				return err
+			}
+			pcm = pcm[n*channels:]
+		}
+	}
+	s.pcm = s.pcm[:copy(s.pcm, pcm)]
+	return nil
+}
+
+// Close writes the last page and closes the underlying writer when it is an
+// io.Closer.
+func (s *OggOpusSink) Close() error {
+	var err error
+	if s.ogg != nil {
+		err = s.ogg.close()
+	}
+	if c, ok := s.w.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
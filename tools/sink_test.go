@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+func TestSinks(t *testing.T) {
+	format := AudioFormat{SampleRate: 48000, Channels: 2}
+	frame := AudioFrame{Format: format, PCM: []int16{1, -1, 2, -2}, Opus: []byte{0xFC, 0xFF, 0xFE}}
+
+	t.Run("WAV", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.wav")
+		sink, err := CreateWAVSink(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for range 3 {
+			if err := sink.WriteFrame(frame); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(data) != 44+24 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+			t.Fatalf("Expected a WAV file with 24 bytes of data, got %d bytes", len(data))
+		}
+		if size := binary.LittleEndian.Uint32(data[40:]); size != 24 {
+			t.Errorf("Expected a data size of 24, got %d", size)
+		}
+		if rate := binary.LittleEndian.Uint32(data[24:]); rate != 48000 {
+			t.Errorf("Expected a sample rate of 48000, got %d", rate)
+		}
+	})
+
+	t.Run("OggOpus", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		sink := NewOggOpusSink(out)
+		if err := sink.WriteFrame(frame); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !bytes.HasPrefix(out.Bytes(), []byte("OggS")) || !bytes.Contains(out.Bytes(), []byte("OpusHead")) {
+			t.Error("Expected an Ogg Opus stream")
+		}
+		for range 2 {
+			if err := sink.WriteFrame(frame); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		pages := readOggPages(t, out.Bytes())
+		if len(pages) != 5 {
+			t.Fatalf("Expected 2 header and 3 audio pages, got %d", len(pages))
+		}
+		for i, p := range pages {
+			if bos := p.flags&oggHeaderBOS != 0; bos != (i == 0) {
+				t.Errorf("Expected only the first page to begin the stream, page %d does: %v", i, bos)
+			}
+			if eos := p.flags&oggHeaderEOS != 0; eos != (i == len(pages)-1) {
+				t.Errorf("Expected only the last page to end the stream, page %d does: %v", i, eos)
+			}
+		}
+		if last := pages[len(pages)-1]; last.granule != oggOpusPreSkip+6 || !bytes.Equal(last.packet, frame.Opus) {
+			t.Errorf("Expected the last packet at granule %d, got %+v", oggOpusPreSkip+6, last)
+		}
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		raw := new(bytes.Buffer)
+		ch := NewChannelSink(1)
+		sink := NewMultiSink(NewWriterSink(raw), ch)
+		for range 2 {
+			if err := sink.WriteFrame(frame); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if raw.Len() != 16 {
+			t.Errorf("Expected 16 bytes of PCM, got %d", raw.Len())
+		}
+		if ch.Dropped() != 1 {
+			t.Errorf("Expected 1 dropped frame, got %d", ch.Dropped())
+		}
+		got := <-ch.Frames()
+		if len(got.PCM) != 4 || got.PCM[3] != -2 {
+			t.Errorf("Expected a copy of the frame, got %v", got.PCM)
+		}
+		if _, ok := <-ch.Frames(); ok {
+			t.Error("Expected the channel to be closed")
+		}
+		if err := ch.WriteFrame(frame); !errors.Is(err, shared.ErrSinkClosed) {
+			t.Errorf("Expected ErrSinkClosed, got %v", err)
+		}
+	})
+}
+
+type oggPage struct {
+	flags   byte
+	granule uint64
+	packet  []byte
+}
+
+// readOggPages parses a stream of single packet pages and checks their CRC.
+func readOggPages(t *testing.T, data []byte) []oggPage {
+	t.Helper()
+	var pages []oggPage
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" {
+			t.Fatalf("Expected an Ogg page, got %d bytes", len(data))
+		}
+		segments := int(data[26])
+		size := 0
+		for _, l := range data[27 : 27+segments] {
+			size += int(l)
+		}
+		end := 27 + segments + size
+		page := bytes.Clone(data[:end])
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		var want uint32
+		for _, b := range page {
+			want = want<<8 ^ oggCRCTable[byte(want>>24)^b]
+		}
+		if crc != want {
+			t.Fatalf("Expected CRC %08x for page %d, got %08x", want, len(pages), crc)
+		}
+		pages = append(pages, oggPage{
+			flags:   data[5],
+			granule: binary.LittleEndian.Uint64(data[6:]),
+			packet:  page[27+segments:],
+		})
+		data = data[end:]
+	}
+	return pages
+}
@@ -0,0 +1,446 @@
+package tools
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+	"github.com/hraban/opus"
+	"github.com/pion/mediadevices"
+	"github.com/pion/webrtc/v4"
+)
+
+// AudioSource produces the audio sent to the session by StreamLocalAudio,
+// which takes care of the sample-rate conversion, the Opus encoding and the
+// pacing to real time.
+type AudioSource interface {
+	Format() AudioFormat
+	// ReadPCM reads interleaved samples into pcm like io.Reader, io.EOF ends
+	// the source.
+	ReadPCM(pcm []int16) (int, error)
+	Close() error
+}
+
+// encodedSource is implemented by sources that produce Opus frames already,
+// which are then sent as they are.
+type encodedSource interface {
+	frames(mimeType string) (frameSource, error)
+}
+
+// frameSource yields encoded Opus frames for StreamLocalAudio. release must be
+// called once the frame is not used anymore, even on error.
+type frameSource interface {
+	next() (frame []byte, release func(), err error)
+	close()
+}
+
+func newFrameSource(source AudioSource, mimeType string, frameDuration time.Duration) (frameSource, error) {
+	if s, ok := source.(encodedSource); ok {
+		return s.frames(mimeType)
+	}
+	return newEncodingSource(source, frameDuration)
+}
+
+// encodingSource converts a source to mono at micSampleRate, encodes it and
+// paces it to real time. Live sources are not slowed down, they block anyway.
+type encodingSource struct {
+	source        AudioSource
+	format        AudioFormat
+	resampler     *dsp.Resampler
+	encoder       *opus.Encoder
+	frameDuration time.Duration
+	frameSamples  int
+	in            []int16
+	mono          []int16
+	pending       []int16
+	out           []byte
+	paced         bool
+	deadline      time.Time
+}
+
+func newEncodingSource(source AudioSource, frameDuration time.Duration) (*encodingSource, error) {
+	format := source.Format()
+	if format.SampleRate <= 0 || format.Channels <= 0 {
+		return nil, fmt.Errorf("invalid source format %+v", format)
+	}
+	encoder, err := opus.NewEncoder(micSampleRate, 1, opus.AppVoIP)
+	if err != nil {
+		return nil, fmt.Errorf("creating Opus encoder: %w", err)
+	}
+	return &encodingSource{
+		source:        source,
+		format:        format,
+		resampler:     dsp.NewResampler(format.SampleRate, micSampleRate, 1, dsp.QualityMedium),
+		encoder:       encoder,
+		frameDuration: frameDuration,
+		frameSamples:  int(int64(micSampleRate) * int64(frameDuration) / int64(time.Second)),
+		in:            make([]int16, int(int64(format.SampleRate)*int64(frameDuration)/int64(time.Second))*format.Channels),
+		out:           make([]byte, 4000),
+		paced:         true,
+	}, nil
+}
+
+func (s *encodingSource) next() ([]byte, func(), error) {
+	release := func() {}
+	for len(s.pending) < s.frameSamples {
+		n, err := s.source.ReadPCM(s.in)
+		if n > 0 {
+			s.mono = dsp.Downmix(s.mono[:0], s.in[:n], s.format.Channels)
+			s.pending = s.resampler.ProcessInt16(s.pending, s.mono)
+		}
+		if err != nil {
+			return nil, release, err
+		}
+	}
+	n, err := s.encoder.Encode(s.pending[:s.frameSamples], s.out)
+	s.pending = append(s.pending[:0], s.pending[s.frameSamples:]...)
+	if err != nil {
+		return nil, release, fmt.Errorf("encoding Opus: %w", err)
+	}
+	if !s.paced {
+		return s.out[:n], release, nil
+	}
+	// Sources faster than real time, e.g. files, are slowed down
+	now := time.Now()
+	if s.deadline.IsZero() || now.Sub(s.deadline) > s.frameDuration {
+		s.deadline = now
+	} else if wait := s.deadline.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+	s.deadline = s.deadline.Add(s.frameDuration)
+	return s.out[:n], release, nil
+}
+
+func (s *encodingSource) close() {
+	_ = s.source.Close()
+}
+
+// EncodeOpusFrames encodes a finite source to mono Opus frames as fast as
+// possible, e.g. to script the assistant audio of a fake server. The last
+// partial frame is dropped and the source is closed.
+func EncodeOpusFrames(source AudioSource, frameDuration time.Duration) ([][]byte, error) {
+	s, err := newEncodingSource(source, frameDuration)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+	s.paced = false
+	var frames [][]byte
+	for {
+		frame, _, err := s.next()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, append([]byte(nil), frame...))
+	}
+}
+
+// TrackSource is a mediadevices track, e.g. a microphone from GetUserMedia.
+// Its Opus frames are sent as they are, ReadPCM decodes them for other uses.
+// Closing the source does not close the track.
+type TrackSource struct {
+	track mediadevices.Track
+
+	mu       sync.Mutex
+	reader   mediadevices.EncodedReadCloser
+	decoder  *opus.Decoder
+	decoded  []int16
+	leftover []int16
+}
+
+func NewTrackSource(track mediadevices.Track) *TrackSource {
+	return &TrackSource{track: track}
+}
+
+// Format is the format ReadPCM decodes to.
+func (s *TrackSource) Format() AudioFormat {
+	return AudioFormat{SampleRate: micSampleRate, Channels: 1}
+}
+
+func (s *TrackSource) ReadPCM(pcm []int16) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reader == nil {
+		reader, err := s.track.NewEncodedReader(webrtc.MimeTypeOpus)
+		if err != nil {
+			return 0, fmt.Errorf("creating media track reader: %w", err)
+		}
+		decoder, err := opus.NewDecoder(micSampleRate, 1)
+		if err != nil {
+			_ = reader.Close()
+			return 0, fmt.Errorf("creating Opus decoder: %w", err)
+		}
+		s.reader = reader
+		s.decoder = decoder
+		s.decoded = make([]int16, micSampleRate*120/1000) // longest Opus frame
+	}
+	for len(s.leftover) == 0 {
+		buf, release, err := s.reader.Read()
+		if err != nil {
+			release()
+			return 0, err
+		}
+		n, err := s.decoder.Decode(buf.Data, s.decoded)
+		release()
+		if err != nil {
+			return 0, fmt.Errorf("decoding Opus: %w", err)
+		}
+		s.leftover = s.decoded[:n]
+	}
+	n := copy(pcm, s.leftover)
+	s.leftover = s.leftover[n:]
+	return n, nil
+}
+
+func (s *TrackSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reader == nil {
+		return nil
+	}
+	err := s.reader.Close()
+	s.reader = nil
+	return err
+}
+
+func (s *TrackSource) frames(mimeType string) (frameSource, error) {
+	reader, err := s.track.NewEncodedReader(mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("creating media track reader: %w", err)
+	}
+	return &trackFrames{reader: reader}, nil
+}
+
+type trackFrames struct {
+	reader mediadevices.EncodedReadCloser
+}
+
+func (s *trackFrames) next() ([]byte, func(), error) {
+	buf, release, err := s.reader.Read()
+	if err != nil {
+		return nil, release, err
+	}
+	if buf.Samples == 0 {
+		return nil, release, nil
+	}
+	return buf.Data, release, nil
+}
+
+func (s *trackFrames) close() {
+	_ = s.reader.Close()
+}
+
+// PCMSource reads 16-bit little endian PCM from an io.Reader. The reader is
+// closed with the source when it is an io.Closer.
+type PCMSource struct {
+	r      io.Reader
+	format AudioFormat
+	buf    []byte
+}
+
+func NewPCMSource(r io.Reader, format AudioFormat) *PCMSource {
+	return &PCMSource{r: r, format: format}
+}
+
+func (s *PCMSource) Format() AudioFormat {
+	return s.format
+}
+
+func (s *PCMSource) ReadPCM(pcm []int16) (int, error) {
+	if cap(s.buf) < len(pcm)*2 {
+		s.buf = make([]byte, len(pcm)*2)
+	}
+	n, err := io.ReadFull(s.r, s.buf[:len(pcm)*2])
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	dsp.BytesToInt16(pcm[:0], s.buf[:n])
+	if n == 0 && err == nil {
+		err = io.EOF
+	}
+	return n / 2, err
+}
+
+func (s *PCMSource) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewWAVSource reads a 16-bit PCM WAV stream.
+func NewWAVSource(r io.Reader) (*PCMSource, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("reading WAV header: %w", err)
+	}
+	if string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	var format AudioFormat
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("reading WAV chunk: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[:4]) {
+		case "fmt ":
+			fmtChunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil || size < 16 {
+				return nil, fmt.Errorf("reading WAV format: %w", errors.Join(err, io.ErrUnexpectedEOF))
+			}
+			if tag := binary.LittleEndian.Uint16(fmtChunk); tag != 1 {
+				return nil, fmt.Errorf("unsupported WAV encoding %d, only PCM is supported", tag)
+			}
+			if bits := binary.LittleEndian.Uint16(fmtChunk[14:]); bits != 16 {
+				return nil, fmt.Errorf("unsupported WAV sample size %d, only 16 bits is supported", bits)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+		case "data":
+			if format.SampleRate == 0 {
+				return nil, errors.New("WAV data before format")
+			}
+			return NewPCMSource(io.LimitReader(r, size), format), nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("skipping WAV chunk: %w", err)
+			}
+		}
+	}
+}
+
+// OpenWAVSource opens the WAV file at path.
+func OpenWAVSource(path string) (*PCMSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening WAV file: %w", err)
+	}
+	s, err := NewWAVSource(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	s.r = struct {
+		io.Reader
+		io.Closer
+	}{s.r, f}
+	return s, nil
+}
+
+// ToneSource generates a sine tone, mono at micSampleRate.
+type ToneSource struct {
+	frequency float64
+	amplitude float64 // 0 to 1
+	remaining int     // samples, negative for no end
+	phase     float64
+}
+
+// NewToneSource generates a tone for duration, or forever if it is 0.
+func NewToneSource(frequency, amplitude float64, duration time.Duration) *ToneSource {
+	remaining := -1
+	if duration > 0 {
+		remaining = int(int64(micSampleRate) * int64(duration) / int64(time.Second))
+	}
+	return &ToneSource{
+		frequency: frequency,
+		amplitude: min(max(amplitude, 0), 1),
+		remaining: remaining,
+	}
+}
+
+// NewSilenceSource generates silence for duration, or forever if it is 0.
+func NewSilenceSource(duration time.Duration) *ToneSource {
+	return NewToneSource(0, 0, duration)
+}
+
+func (s *ToneSource) Format() AudioFormat {
+	return AudioFormat{SampleRate: micSampleRate, Channels: 1}
+}
+
+func (s *ToneSource) ReadPCM(pcm []int16) (int, error) {
+	if s.remaining == 0 {
+		return 0, io.EOF
+	}
+	n := len(pcm)
+	if s.remaining > 0 {
+		n = min(n, s.remaining)
+		s.remaining -= n
+	}
+	step := 2 * math.Pi * s.frequency / micSampleRate
+	for i := range n {
+		pcm[i] = int16(s.amplitude * 32767 * math.Sin(s.phase))
+		s.phase = math.Mod(s.phase+step, 2*math.Pi)
+	}
+	return n, nil
+}
+
+func (s *ToneSource) Close() error {
+	return nil
+}
+
+// SourceSwitch swaps the source of a running StreamLocalAudio, e.g. when the
+// user changes headsets. The WebRTC track stays the same, so there is no
+// renegotiation.
+type SourceSwitch struct {
+	mu   sync.Mutex
+	next AudioSource
+}
+
+func NewSourceSwitch() *SourceSwitch {
+	return &SourceSwitch{}
+}
+
+// Set makes source the source. The previous source is closed by
+// StreamLocalAudio.
+func (s *SourceSwitch) Set(source AudioSource) error {
+	if source == nil {
+		return errors.New("source is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = source
+	return nil
+}
+
+// SetTrack makes the media track the source, the previous track is left to
+// the caller.
+func (s *SourceSwitch) SetTrack(track mediadevices.Track) error {
+	if track == nil {
+		return errors.New("track is required")
+	}
+	return s.Set(NewTrackSource(track))
+}
+
+func (s *SourceSwitch) pending() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next != nil
+}
+
+func (s *SourceSwitch) take(mimeType string, frameDuration time.Duration) (frameSource, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	next := s.next
+	s.next = nil
+	s.mu.Unlock()
+	if next == nil {
+		return nil, nil
+	}
+	return newFrameSource(next, mimeType, frameDuration)
+}
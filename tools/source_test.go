@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+)
+
+func wavBytes(format AudioFormat, pcm []int16) []byte {
+	data := dsp.Int16ToBytes(nil, pcm)
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+len(data)))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, uint16(format.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(format.SampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(format.SampleRate*format.Channels*2))
+	b = binary.LittleEndian.AppendUint16(b, uint16(format.Channels*2))
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestSourceSwitch(t *testing.T) {
+	const frame = 20 * time.Millisecond
+	s := NewSourceSwitch()
+	if s.pending() {
+		t.Fatal("Expected no pending source")
+	}
+	raw := bytes.NewReader(dsp.Int16ToBytes(nil, tone(3*960, 0.3)))
+	if err := s.Set(NewPCMSource(raw, AudioFormat{SampleRate: 48000, Channels: 1})); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	source, err := s.take("audio/opus", frame)
+	if err != nil || source == nil {
+		t.Fatalf("Expected a PCM source, got %v", err)
+	}
+	if s.pending() {
+		t.Error("Expected the source to be taken")
+	}
+	start := time.Now()
+	for range 3 {
+		data, release, err := source.next()
+		release()
+		if err != nil || len(data) == 0 {
+			t.Fatalf("Expected an encoded frame, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*frame {
+		t.Errorf("Expected the source to be paced to real time, took %v", elapsed)
+	}
+	if _, _, err := source.next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestWAVSource(t *testing.T) {
+	t.Run("reads the format and the samples", func(t *testing.T) {
+		format := AudioFormat{SampleRate: 16000, Channels: 2}
+		pcm := []int16{1, -1, 2, -2, 3, -3}
+		s, err := NewWAVSource(bytes.NewReader(wavBytes(format, pcm)))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if s.Format() != format {
+			t.Errorf("Expected format %+v, got %+v", format, s.Format())
+		}
+		got := make([]int16, 8)
+		n, err := s.ReadPCM(got)
+		if err != nil || n != len(pcm) {
+			t.Fatalf("Expected %d samples, got %d (%v)", len(pcm), n, err)
+		}
+		for i, v := range pcm {
+			if got[i] != v {
+				t.Errorf("Expected sample %d to be %d, got %d", i, v, got[i])
+			}
+		}
+		if _, err := s.ReadPCM(got); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	})
+
+	t.Run("rejects other files", func(t *testing.T) {
+		if _, err := NewWAVSource(bytes.NewReader([]byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"))); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestToneSource(t *testing.T) {
+	s := NewToneSource(440, 0.5, 10*time.Millisecond)
+	pcm := make([]int16, 1000)
+	n, err := s.ReadPCM(pcm)
+	if err != nil || n != 480 {
+		t.Fatalf("Expected 480 samples, got %d (%v)", n, err)
+	}
+	if level := levelDB(pcm[:n]); level < -10 || level > -6 {
+		t.Errorf("Expected a level around -9 dBFS, got %.1f", level)
+	}
+	if _, err := s.ReadPCM(pcm); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	silence := NewSilenceSource(0)
+	n, _ = silence.ReadPCM(pcm)
+	for _, v := range pcm[:n] {
+		if v != 0 {
+			t.Fatal("Expected silence")
+		}
+	}
+}
+
+func TestEncodingSourceResamples(t *testing.T) {
+	// 60 ms of 16 kHz stereo make three 20 ms frames at 48 kHz mono
+	format := AudioFormat{SampleRate: 16000, Channels: 2}
+	pcm := dsp.Upmix(nil, tone(3*320, 0.3), 2)
+	s, err := newEncodingSource(NewPCMSource(bytes.NewReader(dsp.Int16ToBytes(nil, pcm)), format), 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	frames := 0
+	for {
+		_, release, err := s.next()
+		release()
+		if err != nil {
+			break
+		}
+		frames++
+	}
+	// The resampler holds back its latency
+	if frames < 2 || frames > 3 {
+		t.Errorf("Expected 2 or 3 frames, got %d", frames)
+	}
+}
+
+func TestEncodeOpusFrames(t *testing.T) {
+	start := time.Now()
+	frames, err := EncodeOpusFrames(NewToneSource(440, 0.3, time.Second), 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(frames) < 49 || len(frames) > 50 {
+		t.Errorf("Expected about 50 frames, got %d", len(frames))
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected no pacing, took %v", elapsed)
+	}
+}
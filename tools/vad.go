@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// VAD tells whether a frame of 16-bit PCM contains speech.
+type VAD interface {
+	IsSpeech(pcm []int16) bool
+}
+
+// EnergyVAD is a baseline detector based on frame energy and zero-crossing
+// rate. The noise floor is tracked on silent frames, so the threshold adapts
+// to the background noise of the microphone.
+type EnergyVAD struct {
+	MinLevel     float64 // dBFS, frames below it are always silent
+	NoiseMargin  float64 // dB above the noise floor for a frame to be speech
+	FricativeZCR float64 // zero-crossing rate of unvoiced consonants
+
+	noiseFloor float64
+	primed     bool
+}
+
+func NewEnergyVAD() *EnergyVAD {
+	return &EnergyVAD{
+		MinLevel:     -50,
+		NoiseMargin:  12,
+		FricativeZCR: 0.25,
+	}
+}
+
+func (v *EnergyVAD) IsSpeech(pcm []int16) bool {
+	if len(pcm) == 0 {
+		return false
+	}
+	level := levelDB(pcm)
+	if !v.primed {
+		v.noiseFloor = level
+		v.primed = true
+	}
+	threshold := max(v.MinLevel, v.noiseFloor+v.NoiseMargin)
+	speech := level > threshold
+	// Unvoiced consonants are quiet but cross zero often
+	if !speech && level > threshold-6 && level > v.MinLevel && zeroCrossingRate(pcm) > v.FricativeZCR {
+		speech = true
+	}
+	if !speech {
+		// Follow a quieter background at once, a louder one slowly
+		if level < v.noiseFloor {
+			v.noiseFloor = level
+		} else {
+			v.noiseFloor += (level - v.noiseFloor) * 0.05
+		}
+	}
+	return speech
+}
+
+// levelDB returns the RMS level of the frame in dBFS.
+func levelDB(pcm []int16) float64 {
+	var sum float64
+	for _, s := range pcm {
+		f := float64(s) / 32768
+		sum += f * f
+	}
+	rms := math.Sqrt(sum / float64(len(pcm)))
+	if rms < 1e-6 {
+		return -120
+	}
+	return 20 * math.Log10(rms)
+}
+
+func zeroCrossingRate(pcm []int16) float64 {
+	if len(pcm) < 2 {
+		return 0
+	}
+	crossings := 0
+	for i := 1; i < len(pcm); i++ {
+		if (pcm[i-1] >= 0) != (pcm[i] >= 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(pcm)-1)
+}
+
+type VADMode int
+
+const (
+	VADModeSuppress VADMode = iota // silent frames are not sent
+	VADModeDTX                     // silent frames are replaced by empty Opus frames
+)
+
+type vadFrame struct {
+	data     []byte
+	duration time.Duration
+}
+
+// VADGate decides which encoded frames are sent based on a VAD. Frames just
+// before speech starts are kept (pre-roll) so the first syllable is not cut,
+// and speech only ends after some silence (hangover). In DTX mode the silence
+// was already sent, so there is no pre-roll.
+type VADGate struct {
+	vad      VAD
+	mode     VADMode
+	preRoll  time.Duration
+	hangover time.Duration
+
+	mu            sync.Mutex
+	speaking      bool
+	silence       time.Duration
+	pending       []vadFrame
+	pendingLen    time.Duration
+	onSpeechStart func()
+	onSpeechEnd   func()
+}
+
+func NewVADGate(vad VAD, mode VADMode, preRoll, hangover time.Duration) *VADGate {
+	if vad == nil {
+		vad = NewEnergyVAD()
+	}
+	return &VADGate{
+		vad:      vad,
+		mode:     mode,
+		preRoll:  preRoll,
+		hangover: hangover,
+	}
+}
+
+// OnSpeech sets callbacks run when speech starts and ends, e.g. to drive turn
+// detection on the client. They are called from the streaming goroutine, start
+// before the frames of the new state are returned, end once the last hangover
+// frame was sent by StreamLocalAudio. Process calls end before returning.
+func (g *VADGate) OnSpeech(start, end func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onSpeechStart = start
+	g.onSpeechEnd = end
+}
+
+func (g *VADGate) Speaking() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.speaking
+}
+
+// Process takes the decoded and encoded versions of the same frame and
+// returns the encoded frames to send, in order.
+func (g *VADGate) Process(pcm []int16, frame []byte, duration time.Duration) [][]byte {
+	out, ended := g.process(pcm, frame, duration, false)
+	ended()
+	return out
+}
+
+// process treats echo frames as silence, the VAD still sees them. ended runs
+// the end callback if speech just ended, the caller sends the frames first so
+// that a gate closed by the callback does not drop the last hangover frame.
+func (g *VADGate) process(pcm []int16, frame []byte, duration time.Duration, echo bool) (out [][]byte, ended func()) {
+	g.mu.Lock()
+	speech := g.vad.IsSpeech(pcm) && !echo
+	var start, end func()
+	switch {
+	case speech && !g.speaking:
+		g.speaking = true
+		g.silence = 0
+		start = g.onSpeechStart
+		for _, f := range g.pending {
+			out = append(out, f.data)
+		}
+		g.pending = g.pending[:0]
+		g.pendingLen = 0
+		out = append(out, frame)
+	case speech:
+		g.silence = 0
+		out = append(out, frame)
+	case g.speaking:
+		g.silence += duration
+		out = append(out, frame)
+		if g.silence >= g.hangover {
+			g.speaking = false
+			end = g.onSpeechEnd
+		}
+	default:
+		g.keep(frame, duration)
+		if g.mode == VADModeDTX && len(frame) > 0 {
+			// A TOC byte alone is an empty frame, decoded as silence
+			out = append(out, []byte{frame[0] & 0xFC})
+		}
+	}
+	g.mu.Unlock()
+	if start != nil {
+		start()
+	}
+	return out, func() {
+		if end != nil {
+			end()
+		}
+	}
+}
+
+func (g *VADGate) keep(frame []byte, duration time.Duration) {
+	if g.preRoll <= 0 || g.mode == VADModeDTX {
+		return
+	}
+	g.pending = append(g.pending, vadFrame{data: append([]byte(nil), frame...), duration: duration})
+	g.pendingLen += duration
+	for len(g.pending) > 0 && g.pendingLen-g.pending[0].duration >= g.preRoll {
+		g.pendingLen -= g.pending[0].duration
+		g.pending = g.pending[1:]
+	}
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"github.com/bridge-packages/go-openai-realtime/tools/dsp"
+	"github.com/pion/webrtc/v4"
+)
+
+func tone(n int, amplitude float64) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(amplitude * 32767 * math.Sin(2*math.Pi*220*float64(i)/48000))
+	}
+	return pcm
+}
+
+type scriptedVAD struct {
+	speech []bool
+	i      int
+}
+
+func (v *scriptedVAD) IsSpeech([]int16) bool {
+	s := v.speech[v.i]
+	v.i++
+	return s
+}
+
+func TestEnergyVAD(t *testing.T) {
+	vad := NewEnergyVAD()
+	for range 10 {
+		if vad.IsSpeech(tone(960, 0.001)) {
+			t.Fatal("Expected background noise not to be speech")
+		}
+	}
+	if !vad.IsSpeech(tone(960, 0.3)) {
+		t.Error("Expected a loud tone to be speech")
+	}
+	if vad.IsSpeech(make([]int16, 960)) {
+		t.Error("Expected digital silence not to be speech")
+	}
+}
+
+func TestVADGate(t *testing.T) {
+	const frame = 20 * time.Millisecond
+
+	t.Run("PreRollAndHangover", func(t *testing.T) {
+		vad := &scriptedVAD{speech: []bool{false, false, false, true, false, false, false}}
+		g := NewVADGate(vad, VADModeSuppress, 2*frame, 2*frame)
+		starts, ends := 0, 0
+		g.OnSpeech(func() { starts++ }, func() { ends++ })
+		var sent []byte
+		for i := range vad.speech {
+			for _, f := range g.Process(nil, []byte{byte(i)}, frame) {
+				sent = append(sent, f[0])
+			}
+		}
+		want := []byte{1, 2, 3, 4, 5}
+		if string(sent) != string(want) {
+			t.Errorf("Expected frames %v, got %v", want, sent)
+		}
+		if starts != 1 || ends != 1 {
+			t.Errorf("Expected one start and one end, got %d and %d", starts, ends)
+		}
+		if g.Speaking() {
+			t.Error("Expected speech to have ended")
+		}
+	})
+
+	t.Run("DTX", func(t *testing.T) {
+		vad := &scriptedVAD{speech: []bool{false}}
+		g := NewVADGate(vad, VADModeDTX, 2*frame, 2*frame)
+		frames := g.Process(nil, []byte{0x7B, 0x01, 0x02}, frame)
+		if len(frames) != 1 || len(frames[0]) != 1 || frames[0][0] != 0x78 {
+			t.Errorf("Expected a single empty Opus frame, got %v", frames)
+		}
+	})
+
+	t.Run("GateClosedBySpeechEnd", func(t *testing.T) {
+		// The end callback closes the gate, as EndTurn does with the mic
+		vad := &scriptedVAD{speech: []bool{true, false, false}}
+		g := NewVADGate(vad, VADModeSuppress, 0, 2*frame)
+		var open atomic.Bool
+		open.Store(true)
+		g.OnSpeech(func() {}, func() { open.Store(false) })
+		track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", "test")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		raw := bytes.NewReader(dsp.Int16ToBytes(nil, tone(3*960, 0.3)))
+		sent := 0
+		StreamLocalAudio(context.Background(), shared.NewStdLogger(), track, NewPCMSource(raw, AudioFormat{SampleRate: 48000, Channels: 1}), frame, LocalAudioOptions{
+			Gate: open.Load,
+			VAD:  g,
+			Sink: SinkFunc(func(AudioFrame) error { sent++; return nil }),
+		})
+		if sent != 3 {
+			t.Errorf("Expected the speech and both hangover frames to be sent, got %d frames", sent)
+		}
+		if open.Load() {
+			t.Error("Expected the gate to be closed")
+		}
+	})
+}
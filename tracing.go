@@ -0,0 +1,236 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/pion/webrtc/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer of the clients, they use the global
+// tracer provider.
+const TracerName = "github.com/bridge-packages/go-openai-realtime"
+
+// Span names
+const (
+	SpanSession              = "realtime.session"
+	SpanNewClient            = "realtime.new_client"
+	SpanStart                = "realtime.start"
+	SpanCreateOffer          = "realtime.create_offer"
+	SpanCreateCall           = "realtime.create_call" // HTTP SDP exchange
+	SpanSetRemoteDescription = "realtime.set_remote_description"
+	SpanICE                  = "realtime.ice"
+	SpanResponse             = "realtime.response"
+	SpanToolCall             = "realtime.tool_call"
+)
+
+// Span attributes, following the OpenTelemetry GenAI conventions where they
+// apply.
+const (
+	AttrModel          = attribute.Key("gen_ai.request.model")
+	AttrVoice          = attribute.Key("openai.realtime.voice")
+	AttrCallId         = attribute.Key("openai.realtime.call_id")
+	AttrSessionId      = attribute.Key("openai.realtime.session_id")
+	AttrResponseId     = attribute.Key("gen_ai.response.id")
+	AttrResponseStatus = attribute.Key("openai.realtime.response.status")
+	AttrInputTokens    = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens   = attribute.Key("gen_ai.usage.output_tokens")
+	AttrToolType       = attribute.Key("gen_ai.tool.type") // "function" or "mcp"
+	AttrToolName       = attribute.Key("gen_ai.tool.name")
+	AttrToolCallId     = attribute.Key("gen_ai.tool.call.id")
+	AttrMCPServer      = attribute.Key("openai.realtime.mcp.server_label")
+	AttrICEState       = attribute.Key("openai.realtime.ice.state")
+)
+
+var errMCPCallFailed = errors.New("MCP call failed")
+
+// clientTracer keeps the spans of a client. The session span is the parent of
+// the others, it ends with the client.
+type clientTracer struct {
+	tracer  trace.Tracer
+	ctx     context.Context // holds the session span
+	session trace.Span
+
+	mu        sync.Mutex
+	ice       trace.Span
+	responses map[string]trace.Span
+	tools     map[string]trace.Span // by item ID
+	functions map[string]string     // item ID by call ID
+}
+
+func newClientTracer(ctx context.Context) *clientTracer {
+	t := &clientTracer{
+		tracer:    otel.GetTracerProvider().Tracer(TracerName),
+		responses: map[string]trace.Span{},
+		tools:     map[string]trace.Span{},
+		functions: map[string]string{},
+	}
+	t.ctx, t.session = t.tracer.Start(ctx, SpanSession)
+	return t
+}
+
+// start starts a child span of ctx, of the session when nil.
+func (t *clientTracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = t.ctx
+	}
+	return t.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startICE starts the ICE span, it ends once the connection is established or
+// failed.
+func (t *clientTracer) startICE(ctx context.Context) {
+	_, span := t.start(ctx, SpanICE)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ice = span
+}
+
+func (t *clientTracer) iceState(state webrtc.ICEConnectionState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ice == nil {
+		return
+	}
+	switch state {
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+	case webrtc.ICEConnectionStateFailed, webrtc.ICEConnectionStateClosed:
+		t.ice.SetStatus(codes.Error, "ICE connection "+state.String())
+	default:
+		return
+	}
+	t.ice.SetAttributes(AttrICEState.String(state.String()))
+	t.ice.End()
+	t.ice = nil
+}
+
+func (t *clientTracer) pipeEvent(event *ServerEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch p := event.Param.(type) {
+	case *ServerEventParamSessionCreated:
+		if id, ok := p.Session["id"].(string); ok {
+			t.session.SetAttributes(AttrSessionId.String(id))
+		}
+	case *ServerEventParamResponseCreated:
+		id, _ := p.Response["id"].(string)
+		_, t.responses[id] = t.start(nil, SpanResponse, AttrResponseId.String(id))
+	case *ServerEventParamResponseDone:
+		id, _ := p.Response["id"].(string)
+		span, ok := t.responses[id]
+		if !ok {
+			return
+		}
+		delete(t.responses, id)
+		status, _ := p.Response["status"].(string)
+		span.SetAttributes(AttrResponseStatus.String(status))
+		if usage, ok := p.Response["usage"].(map[string]any); ok {
+			if n, ok := asInt(usage["input_tokens"]); ok {
+				span.SetAttributes(AttrInputTokens.Int(n))
+			}
+			if n, ok := asInt(usage["output_tokens"]); ok {
+				span.SetAttributes(AttrOutputTokens.Int(n))
+			}
+		}
+		if status == "failed" {
+			span.SetStatus(codes.Error, "response failed")
+		}
+		span.End()
+	case *ServerEventParamResponseOutputItemAdded:
+		t.startTool(p.ResponseId, p.Item)
+	case *ServerEventParamResponseMCPCallFailed:
+		if span, ok := t.tools[p.ItemId]; ok {
+			span.RecordError(errMCPCallFailed)
+			span.SetStatus(codes.Error, errMCPCallFailed.Error())
+		}
+	case *ServerEventParamResponseOutputItemDone:
+		// A function call lasts until its output is sent, see sent
+		if p.Item["type"] == "mcp_call" {
+			id, _ := p.Item["id"].(string)
+			t.endTool(id)
+		}
+	}
+}
+
+// startTool starts the span of a function or MCP call item, t.mu must be held.
+func (t *clientTracer) startTool(responseId string, item map[string]any) {
+	id, _ := item["id"].(string)
+	name, _ := item["name"].(string)
+	attrs := []attribute.KeyValue{AttrToolName.String(name)}
+	switch item["type"] {
+	case "function_call":
+		callId, _ := item["call_id"].(string)
+		attrs = append(attrs, AttrToolType.String("function"), AttrToolCallId.String(callId))
+		t.functions[callId] = id
+	case "mcp_call":
+		label, _ := item["server_label"].(string)
+		attrs = append(attrs, AttrToolType.String("mcp"), AttrMCPServer.String(label))
+	default:
+		return
+	}
+	var ctx context.Context
+	if span, ok := t.responses[responseId]; ok {
+		ctx = trace.ContextWithSpan(t.ctx, span)
+	}
+	_, t.tools[id] = t.start(ctx, SpanToolCall, attrs...)
+}
+
+// endTool ends the span of a tool call, t.mu must be held.
+func (t *clientTracer) endTool(itemId string) {
+	if span, ok := t.tools[itemId]; ok {
+		delete(t.tools, itemId)
+		span.End()
+	}
+}
+
+// sent ends the span of a function call once its output is sent.
+func (t *clientTracer) sent(event *ClientEvent) {
+	p, ok := event.Param.(*ClientEventParamConversationItemCreate)
+	if !ok || p.Item["type"] != "function_call_output" {
+		return
+	}
+	callId, _ := p.Item["call_id"].(string)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.functions[callId]; ok {
+		delete(t.functions, callId)
+		t.endTool(id)
+	}
+}
+
+// end ends the spans left open and the session span, with cause unless the
+// client was closed.
+func (t *clientTracer) end(cause error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ice != nil {
+		t.ice.SetStatus(codes.Error, "session ended before the connection")
+		t.ice.End()
+		t.ice = nil
+	}
+	for id, span := range t.tools {
+		span.End()
+		delete(t.tools, id)
+	}
+	for id, span := range t.responses {
+		span.End()
+		delete(t.responses, id)
+	}
+	clear(t.functions)
+	if cause != nil && !errors.Is(cause, errClientClosed) {
+		t.session.SetStatus(codes.Error, cause.Error())
+	}
+	t.session.End()
+}
+
+// fail marks a span as failed and ends it.
+func fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
@@ -0,0 +1,184 @@
+package realtime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/openai/openai-go/v3/realtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	server := realtimetest.NewServer(realtimetest.Options{
+		Greeting: []*pkg.ServerEvent{
+			{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{"id": "sess_test"}}},
+		},
+		Replies: map[pkg.ClientEventType][]*pkg.ServerEvent{
+			pkg.ClientEventTypeResponseCreate: {
+				{Type: pkg.ServerEventTypeResponseCreated, Param: &pkg.ServerEventParamResponseCreated{Response: map[string]any{"id": "resp_1"}}},
+				{Type: pkg.ServerEventTypeResponseOutputItemAdded, Param: &pkg.ServerEventParamResponseOutputItemAdded{
+					ResponseId: "resp_1",
+					Item:       map[string]any{"id": "item_fn", "type": "function_call", "name": "get_weather", "call_id": "call_1"},
+				}},
+				{Type: pkg.ServerEventTypeResponseOutputItemAdded, Param: &pkg.ServerEventParamResponseOutputItemAdded{
+					ResponseId:  "resp_1",
+					OutputIndex: 1,
+					Item:        map[string]any{"id": "item_mcp", "type": "mcp_call", "name": "search", "server_label": "docs"},
+				}},
+				{Type: pkg.ServerEventTypeResponseMCPCallFailed, Param: &pkg.ServerEventParamResponseMCPCallFailed{OutputIndex: 1, ItemId: "item_mcp"}},
+				{Type: pkg.ServerEventTypeResponseOutputItemDone, Param: &pkg.ServerEventParamResponseOutputItemDone{
+					ResponseId:  "resp_1",
+					OutputIndex: 1,
+					Item:        map[string]any{"id": "item_mcp", "type": "mcp_call"},
+				}},
+				{Type: pkg.ServerEventTypeResponseDone, Param: &pkg.ServerEventParamResponseDone{Response: map[string]any{
+					"id":     "resp_1",
+					"status": "completed",
+					"usage":  map[string]any{"input_tokens": 12, "output_tokens": 34},
+				}}},
+			},
+		},
+	})
+	defer server.Close()
+
+	parentCtx, parent := provider.Tracer("test").Start(ctx, "business")
+	cfg := &realtime.RealtimeSessionCreateRequestParam{Model: "gpt-realtime"}
+	cfg.Audio.Output.Voice = "marin"
+	done := make(chan struct{})
+	c, _ := realtimetest.Connect(t, parentCtx, server, realtimetest.ConnectOptions{
+		Greeting: "Hello",
+		Config:   cfg,
+		Events: func(event *pkg.ServerEvent) {
+			if event.Type == pkg.ServerEventTypeResponseDone {
+				close(done)
+			}
+		},
+	})
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("Expected response.done")
+	}
+	if err := c.SendEvent(&pkg.ClientEvent{
+		Type:  pkg.ClientEventTypeConversationItemCreate,
+		Param: &pkg.ClientEventParamConversationItemCreate{Item: map[string]any{"type": "function_call_output", "call_id": "call_1", "output": "sunny"}},
+	}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parent.End()
+
+	spans := map[string]tracetest.SpanStub{}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, span := range exporter.GetSpans() {
+			name := span.Name
+			if name == pkg.SpanToolCall {
+				name += "/" + attrs(span)[pkg.AttrToolType].AsString()
+			}
+			spans[name] = span
+		}
+		if _, ok := spans[pkg.SpanSession]; ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the session span to end with the client")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	parentOf := func(name string) string {
+		id := spans[name].Parent.SpanID()
+		for other, span := range spans {
+			if span.SpanContext.SpanID() == id {
+				return other
+			}
+		}
+		if id == parent.SpanContext().SpanID() {
+			return "business"
+		}
+		return ""
+	}
+
+	t.Run("Hierarchy", func(t *testing.T) {
+		for name, expected := range map[string]string{
+			pkg.SpanSession:                "business",
+			pkg.SpanNewClient:              pkg.SpanSession,
+			pkg.SpanStart:                  pkg.SpanSession,
+			pkg.SpanCreateOffer:            pkg.SpanStart,
+			pkg.SpanCreateCall:             pkg.SpanStart,
+			pkg.SpanSetRemoteDescription:   pkg.SpanStart,
+			pkg.SpanICE:                    pkg.SpanStart,
+			pkg.SpanResponse:               pkg.SpanSession,
+			pkg.SpanToolCall + "/function": pkg.SpanResponse,
+			pkg.SpanToolCall + "/mcp":      pkg.SpanResponse,
+		} {
+			if _, ok := spans[name]; !ok {
+				t.Errorf("Expected a %s span", name)
+				continue
+			}
+			if got := parentOf(name); got != expected {
+				t.Errorf("Expected %s to be a child of %s, got %q", name, expected, got)
+			}
+			if spans[name].SpanContext.TraceID() != parent.SpanContext().TraceID() {
+				t.Errorf("Expected %s in the trace of the parent context", name)
+			}
+		}
+	})
+
+	t.Run("Attributes", func(t *testing.T) {
+		session := attrs(spans[pkg.SpanSession])
+		for key, expected := range map[attribute.Key]string{
+			pkg.AttrModel:     "gpt-realtime",
+			pkg.AttrVoice:     "marin",
+			pkg.AttrCallId:    c.CallID(),
+			pkg.AttrSessionId: "sess_test",
+		} {
+			if got := session[key].AsString(); got != expected {
+				t.Errorf("Expected %s to be %q, got %q", key, expected, got)
+			}
+		}
+		if code := attrs(spans[pkg.SpanCreateCall])["http.response.status_code"].AsInt64(); code != 201 {
+			t.Errorf("Expected the status code of the call, got %d", code)
+		}
+		response := attrs(spans[pkg.SpanResponse])
+		if response[pkg.AttrResponseId].AsString() != "resp_1" || response[pkg.AttrInputTokens].AsInt64() != 12 || response[pkg.AttrOutputTokens].AsInt64() != 34 {
+			t.Errorf("Expected the response ID and usage, got %v", response)
+		}
+		function := attrs(spans[pkg.SpanToolCall+"/function"])
+		if function[pkg.AttrToolName].AsString() != "get_weather" || function[pkg.AttrToolCallId].AsString() != "call_1" {
+			t.Errorf("Expected the function name and call ID, got %v", function)
+		}
+		if mcp := spans[pkg.SpanToolCall+"/mcp"]; mcp.Status.Code != codes.Error || attrs(mcp)[pkg.AttrMCPServer].AsString() != "docs" {
+			t.Errorf("Expected a failed MCP call on docs, got %+v", mcp)
+		}
+		if status := spans[pkg.SpanSession].Status; status.Code == codes.Error {
+			t.Errorf("Expected closing the client not to be an error, got %+v", status)
+		}
+	})
+}
+
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
@@ -0,0 +1,340 @@
+package realtime
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/bridge-packages/go-openai-realtime/shared"
+	"go.uber.org/zap"
+)
+
+// TokenUsage counts the tokens of a response.done event, the input counts
+// include the cached tokens.
+type TokenUsage struct {
+	InputText   int
+	InputAudio  int
+	InputImage  int
+	CachedText  int
+	CachedAudio int
+	CachedImage int
+	OutputText  int
+	OutputAudio int
+}
+
+func (u TokenUsage) Input() int  { return u.InputText + u.InputAudio + u.InputImage }
+func (u TokenUsage) Cached() int { return u.CachedText + u.CachedAudio + u.CachedImage }
+func (u TokenUsage) Output() int { return u.OutputText + u.OutputAudio }
+func (u TokenUsage) Total() int  { return u.Input() + u.Output() }
+
+func (u *TokenUsage) add(o TokenUsage) {
+	u.InputText += o.InputText
+	u.InputAudio += o.InputAudio
+	u.InputImage += o.InputImage
+	u.CachedText += o.CachedText
+	u.CachedAudio += o.CachedAudio
+	u.CachedImage += o.CachedImage
+	u.OutputText += o.OutputText
+	u.OutputAudio += o.OutputAudio
+}
+
+// tokenUsageOf reads the usage of a response, see ServerEventParamResponseDone.
+func tokenUsageOf(response map[string]any) TokenUsage {
+	var u TokenUsage
+	usage, _ := response["usage"].(map[string]any)
+	count := func(details map[string]any, key string) int {
+		n, _ := asInt(details[key])
+		return n
+	}
+	input, _ := usage["input_token_details"].(map[string]any)
+	u.InputText = count(input, "text_tokens")
+	u.InputAudio = count(input, "audio_tokens")
+	u.InputImage = count(input, "image_tokens")
+	if cached, ok := input["cached_tokens_details"].(map[string]any); ok {
+		u.CachedText = count(cached, "text_tokens")
+		u.CachedAudio = count(cached, "audio_tokens")
+		u.CachedImage = count(cached, "image_tokens")
+	} else if cached := count(input, "cached_tokens"); cached > 0 {
+		// Without the details the cached tokens are split in proportion to
+		// the input, the rounding goes to text
+		if total := u.Input(); total > 0 {
+			u.CachedAudio = cached * u.InputAudio / total
+			u.CachedImage = cached * u.InputImage / total
+		}
+		u.CachedText = cached - u.CachedAudio - u.CachedImage
+	}
+	output, _ := usage["output_token_details"].(map[string]any)
+	u.OutputText = count(output, "text_tokens")
+	u.OutputAudio = count(output, "audio_tokens")
+	return u
+}
+
+// Price is in dollars per million tokens.
+type Price struct {
+	InputText   float64
+	InputAudio  float64
+	InputImage  float64
+	CachedText  float64
+	CachedAudio float64
+	CachedImage float64
+	OutputText  float64
+	OutputAudio float64
+}
+
+// Cost returns the cost of usage in dollars.
+// Cached tokens beyond the input of their modality are not taken off it.
+func (p Price) Cost(u TokenUsage) float64 {
+	micro := float64(max(u.InputText-u.CachedText, 0))*p.InputText +
+		float64(max(u.InputAudio-u.CachedAudio, 0))*p.InputAudio +
+		float64(max(u.InputImage-u.CachedImage, 0))*p.InputImage +
+		float64(u.CachedText)*p.CachedText +
+		float64(u.CachedAudio)*p.CachedAudio +
+		float64(u.CachedImage)*p.CachedImage +
+		float64(u.OutputText)*p.OutputText +
+		float64(u.OutputAudio)*p.OutputAudio
+	return micro / 1e6
+}
+
+// PriceTable maps models to their price, snapshots like
+// gpt-realtime-2025-08-28 use the longest matching prefix.
+type PriceTable map[string]Price
+
+// DefaultPrices are the published prices when this package was written, set
+// the current ones for accurate costs.
+var DefaultPrices = PriceTable{
+	"gpt-realtime": {
+		InputText: 4, InputAudio: 32, InputImage: 5,
+		CachedText: 0.4, CachedAudio: 0.4, CachedImage: 0.5,
+		OutputText: 16, OutputAudio: 64,
+	},
+	"gpt-realtime-mini": {
+		InputText: 0.6, InputAudio: 10, InputImage: 0.8,
+		CachedText: 0.06, CachedAudio: 0.3, CachedImage: 0.08,
+		OutputText: 2.4, OutputAudio: 20,
+	},
+	"gpt-4o-realtime-preview": {
+		InputText: 5, InputAudio: 40,
+		CachedText: 2.5, CachedAudio: 2.5,
+		OutputText: 20, OutputAudio: 80,
+	},
+	"gpt-4o-mini-realtime-preview": {
+		InputText: 0.6, InputAudio: 10,
+		CachedText: 0.3, CachedAudio: 0.3,
+		OutputText: 2.4, OutputAudio: 20,
+	},
+}
+
+// Price returns the price of a model, false if it is unknown.
+func (t PriceTable) Price(model string) (Price, bool) {
+	var price Price
+	matched := ""
+	for prefix, p := range t {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			price, matched = p, prefix
+		}
+	}
+	return price, matched != ""
+}
+
+// Budget caps the usage of a session, zero caps are disabled.
+type Budget struct {
+	MaxCost     float64 // dollars
+	MaxTokens   int
+	WarnAt      float64 // fraction of the caps, e.g. 0.8, no warning if zero
+	OutputLimit int     // max_output_tokens once a cap is reached, the session is closed if zero
+}
+
+type BudgetState int
+
+const (
+	BudgetOk BudgetState = iota
+	BudgetWarning
+	BudgetExceeded
+)
+
+func (s BudgetState) String() string {
+	switch s {
+	case BudgetOk:
+		return "ok"
+	case BudgetWarning:
+		return "warning"
+	case BudgetExceeded:
+		return "exceeded"
+	}
+	return "unknown"
+}
+
+// ResponseUsage is the usage of a response, Cost is zero for unknown models.
+type ResponseUsage struct {
+	ResponseId string
+	Model      string
+	Usage      TokenUsage
+	Cost       float64
+}
+
+// UsageAccountant sums the token usage of a session from response.done
+// events and enforces a budget on the client.
+type UsageAccountant struct {
+	logger   shared.LoggerAdapter
+	client   *Client // nil to only account
+	prices   PriceTable
+	budget   Budget
+	onBudget func(state BudgetState, usage TokenUsage, cost float64)
+
+	mu        sync.Mutex
+	model     string
+	usage     TokenUsage
+	cost      float64
+	responses []ResponseUsage
+	state     BudgetState
+	unpriced  bool // an unknown model was already logged
+}
+
+// NewUsageAccountant makes an accountant, client and onBudget are optional.
+// onBudget is called once when the budget reaches the warning and once when
+// it is exceeded, before the client is limited or closed. The prices default
+// to DefaultPrices.
+func NewUsageAccountant(
+	logger shared.LoggerAdapter,
+	client *Client,
+	prices PriceTable,
+	budget Budget,
+	onBudget func(state BudgetState, usage TokenUsage, cost float64),
+) (*UsageAccountant, error) {
+	if logger == nil {
+		return nil, shared.ErrNoLogger
+	}
+	if budget.WarnAt < 0 || budget.WarnAt >= 1 {
+		return nil, errors.New("budget warning must be between 0 and 1")
+	}
+	if budget.MaxCost < 0 || budget.MaxTokens < 0 || budget.OutputLimit < 0 {
+		return nil, errors.New("budget caps must not be negative")
+	}
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	return &UsageAccountant{
+		logger:   logger,
+		client:   client,
+		prices:   prices,
+		budget:   budget,
+		onBudget: onBudget,
+	}, nil
+}
+
+func (a *UsageAccountant) PipeEvent(event *ServerEvent) {
+	a.mu.Lock()
+	switch p := event.Param.(type) {
+	case *ServerEventParamSessionCreated:
+		a.setModel(p.Session)
+	case *ServerEventParamSessionUpdated:
+		a.setModel(p.Session)
+	case *ServerEventParamResponseDone:
+		id, _ := p.Response["id"].(string)
+		response := ResponseUsage{ResponseId: id, Model: a.model, Usage: tokenUsageOf(p.Response)}
+		if price, ok := a.prices.Price(a.model); ok {
+			response.Cost = price.Cost(response.Usage)
+		} else if !a.unpriced {
+			a.unpriced = true
+			a.logger.Warn("no price for the model, its cost is not counted", zap.String("model", a.model))
+		}
+		a.responses = append(a.responses, response)
+		a.usage.add(response.Usage)
+		a.cost += response.Cost
+		state := a.budgetState()
+		if state <= a.state {
+			break
+		}
+		a.state = state
+		usage, cost := a.usage, a.cost
+		a.mu.Unlock()
+		a.reached(state, usage, cost)
+		return
+	}
+	a.mu.Unlock()
+}
+
+// setModel keeps the model of the session, a.mu must be held.
+func (a *UsageAccountant) setModel(session map[string]any) {
+	if model, ok := session["model"].(string); ok && model != "" {
+		a.model = model
+	}
+}
+
+// budgetState compares the usage to the caps, a.mu must be held.
+func (a *UsageAccountant) budgetState() BudgetState {
+	used := 0.0
+	if a.budget.MaxCost > 0 {
+		used = a.cost / a.budget.MaxCost
+	}
+	if a.budget.MaxTokens > 0 {
+		used = max(used, float64(a.usage.Total())/float64(a.budget.MaxTokens))
+	}
+	switch {
+	case used >= 1:
+		return BudgetExceeded
+	case a.budget.WarnAt > 0 && used >= a.budget.WarnAt:
+		return BudgetWarning
+	}
+	return BudgetOk
+}
+
+// reached reports a new budget state and enforces it, without holding a.mu.
+func (a *UsageAccountant) reached(state BudgetState, usage TokenUsage, cost float64) {
+	fields := []zap.Field{
+		zap.Stringer("state", state),
+		zap.Int("tokens", usage.Total()),
+		zap.Float64("cost", cost),
+	}
+	if state == BudgetWarning {
+		a.logger.Warn("session budget nearly reached", fields...)
+	} else {
+		a.logger.Warn("session budget exceeded", fields...)
+	}
+	if a.onBudget != nil {
+		a.onBudget(state, usage, cost)
+	}
+	if state != BudgetExceeded || a.client == nil {
+		return
+	}
+	if a.budget.OutputLimit > 0 {
+		err := a.client.SendEvent(&ClientEvent{
+			Type: ClientEventTypeSessionUpdate,
+			Param: &ClientEventParamSessionUpdate{Session: map[string]any{
+				"type":              "realtime",
+				"max_output_tokens": a.budget.OutputLimit,
+			}},
+		})
+		if err != nil {
+			a.logger.Error("limiting output tokens", err)
+		}
+		return
+	}
+	// Closing from the event dispatch would wait for itself
+	go func() {
+		if err := a.client.Close(); err != nil {
+			a.logger.Error("closing client over budget", err)
+		}
+	}()
+}
+
+// Usage returns the usage and cost of the session so far.
+func (a *UsageAccountant) Usage() (TokenUsage, float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usage, a.cost
+}
+
+// Responses returns the usage of each response so far.
+func (a *UsageAccountant) Responses() []ResponseUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ResponseUsage(nil), a.responses...)
+}
+
+// BudgetState returns the state of the budget.
+func (a *UsageAccountant) BudgetState() BudgetState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state
+}
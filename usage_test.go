@@ -0,0 +1,167 @@
+package realtime_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	pkg "github.com/bridge-packages/go-openai-realtime"
+	"github.com/bridge-packages/go-openai-realtime/realtimetest"
+	"github.com/bridge-packages/go-openai-realtime/shared"
+)
+
+func responseDone(id string, inputText, inputAudio, cachedText, outputAudio int) *pkg.ServerEvent {
+	return &pkg.ServerEvent{Type: pkg.ServerEventTypeResponseDone, Param: &pkg.ServerEventParamResponseDone{Response: map[string]any{
+		"id": id,
+		"usage": map[string]any{
+			"input_tokens":  inputText + inputAudio,
+			"output_tokens": outputAudio,
+			"input_token_details": map[string]any{
+				"text_tokens":           inputText,
+				"audio_tokens":          inputAudio,
+				"cached_tokens":         cachedText,
+				"cached_tokens_details": map[string]any{"text_tokens": cachedText},
+			},
+			"output_token_details": map[string]any{"audio_tokens": outputAudio},
+		},
+	}}}
+}
+
+func TestUsageAccountant(t *testing.T) {
+	t.Run("Accounting", func(t *testing.T) {
+		var states []pkg.BudgetState
+		a, err := pkg.NewUsageAccountant(shared.NewStdLogger(), nil, nil, pkg.Budget{MaxTokens: 3000, WarnAt: 0.5}, func(state pkg.BudgetState, usage pkg.TokenUsage, cost float64) {
+			states = append(states, state)
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		a.PipeEvent(&pkg.ServerEvent{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{"model": "gpt-realtime-2025-08-28"}}})
+		a.PipeEvent(responseDone("resp_1", 1000, 0, 500, 0))
+		if len(states) != 0 {
+			t.Errorf("Expected no budget state yet, got %v", states)
+		}
+		a.PipeEvent(responseDone("resp_2", 0, 1000, 0, 500))
+		if len(states) != 1 || states[0] != pkg.BudgetWarning {
+			t.Errorf("Expected a warning, got %v", states)
+		}
+		a.PipeEvent(responseDone("resp_3", 0, 0, 0, 500))
+		a.PipeEvent(responseDone("resp_4", 0, 0, 0, 500))
+		if len(states) != 2 || states[1] != pkg.BudgetExceeded || a.BudgetState() != pkg.BudgetExceeded {
+			t.Errorf("Expected the budget to be exceeded once, got %v", states)
+		}
+
+		responses := a.Responses()
+		if len(responses) != 4 || responses[0].ResponseId != "resp_1" || responses[0].Model != "gpt-realtime-2025-08-28" {
+			t.Fatalf("Expected the four responses, got %+v", responses)
+		}
+		// 500 text tokens at $4, 500 cached at $0.40
+		if cost := responses[0].Cost; math.Abs(cost-0.0022) > 1e-9 {
+			t.Errorf("Expected a cost of $0.0022, got %v", cost)
+		}
+		usage, cost := a.Usage()
+		if usage.Input() != 2000 || usage.Cached() != 500 || usage.Output() != 1500 || usage.Total() != 3500 {
+			t.Errorf("Expected the session totals, got %+v", usage)
+		}
+		// 1000 audio tokens at $32, 1500 output audio at $64
+		if expected := 0.0022 + 0.032 + 0.096; math.Abs(cost-expected) > 1e-9 {
+			t.Errorf("Expected a cost of $%v, got %v", expected, cost)
+		}
+	})
+
+	t.Run("CachedWithoutDetails", func(t *testing.T) {
+		a, err := pkg.NewUsageAccountant(shared.NewStdLogger(), nil, nil, pkg.Budget{}, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		a.PipeEvent(&pkg.ServerEvent{Type: pkg.ServerEventTypeSessionCreated, Param: &pkg.ServerEventParamSessionCreated{Session: map[string]any{"model": "gpt-realtime"}}})
+		a.PipeEvent(&pkg.ServerEvent{Type: pkg.ServerEventTypeResponseDone, Param: &pkg.ServerEventParamResponseDone{Response: map[string]any{
+			"id": "resp_1",
+			"usage": map[string]any{
+				"input_token_details": map[string]any{"text_tokens": 100, "audio_tokens": 300, "cached_tokens": 200},
+			},
+		}}})
+		responses := a.Responses()
+		if len(responses) != 1 {
+			t.Fatalf("Expected one response, got %+v", responses)
+		}
+		if u := responses[0].Usage; u.CachedText != 50 || u.CachedAudio != 150 {
+			t.Errorf("Expected the cached tokens to be split like the input, got %+v", u)
+		}
+		// 50 text tokens at $4, 150 audio at $32, 200 cached at $0.40
+		if cost := responses[0].Cost; math.Abs(cost-0.00508) > 1e-9 {
+			t.Errorf("Expected a cost of $0.00508, got %v", cost)
+		}
+		// More cached than input tokens never makes a negative cost
+		if cost := pkg.DefaultPrices["gpt-realtime"].Cost(pkg.TokenUsage{InputText: 10, CachedText: 20}); math.Abs(cost-0.000008) > 1e-12 {
+			t.Errorf("Expected only the cached tokens to be billed, got %v", cost)
+		}
+	})
+
+	t.Run("Prices", func(t *testing.T) {
+		if price, ok := pkg.DefaultPrices.Price("gpt-realtime-mini-2025-10-06"); !ok || price != pkg.DefaultPrices["gpt-realtime-mini"] {
+			t.Errorf("Expected the longest prefix to match, got %+v", price)
+		}
+		if _, ok := pkg.DefaultPrices.Price("whisper-1"); ok {
+			t.Error("Expected an unknown model")
+		}
+		if _, err := pkg.NewUsageAccountant(shared.NewStdLogger(), nil, nil, pkg.Budget{WarnAt: 1.5}, nil); err == nil {
+			t.Error("Expected an invalid warning fraction to fail")
+		}
+	})
+
+	for _, limit := range []int{0, 256} {
+		name := "Close"
+		if limit > 0 {
+			name = "LimitOutput"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+			server := realtimetest.NewServer(realtimetest.Options{
+				Replies: map[pkg.ClientEventType][]*pkg.ServerEvent{
+					pkg.ClientEventTypeResponseCreate: {responseDone("resp_1", 0, 0, 0, 2000)},
+				},
+			})
+			defer server.Close()
+			var a *pkg.UsageAccountant
+			c, call := realtimetest.Connect(t, ctx, server, realtimetest.ConnectOptions{
+				Greeting: "Hello",
+				Events:   func(event *pkg.ServerEvent) { a.PipeEvent(event) },
+				Setup: func(c *pkg.Client) (err error) {
+					a, err = pkg.NewUsageAccountant(shared.NewStdLogger(), c, nil, pkg.Budget{MaxTokens: 1000, OutputLimit: limit}, nil)
+					return err
+				},
+			})
+
+			if limit == 0 {
+				select {
+				case <-c.Done():
+				case <-ctx.Done():
+					t.Fatal("Expected the client to be closed over budget")
+				}
+				return
+			}
+			msg, err := call.WaitMessage(ctx, pkg.ClientEventTypeSessionUpdate)
+			if err != nil {
+				t.Fatalf("Expected session.update, got %v", err)
+			}
+			if msg.Event == nil {
+				t.Fatalf("Expected a parsed session.update, got %s", msg.Raw)
+			}
+			update, ok := msg.Event.Param.(*pkg.ClientEventParamSessionUpdate)
+			if !ok {
+				t.Fatalf("Expected a parsed session.update, got %s", msg.Raw)
+			}
+			if n, _ := update.Session["max_output_tokens"].(float64); int(n) != limit {
+				t.Errorf("Expected max_output_tokens to be %d, got %s", limit, msg.Raw)
+			}
+			select {
+			case <-c.Done():
+				t.Error("Expected the session to go on with limited output")
+			default:
+			}
+		})
+	}
+}